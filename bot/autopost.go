@@ -0,0 +1,553 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+const MessageAutopostUsage = `Usage:
+
+/autopost on [source ids] - publish new posts from current or given sources, e.g. /autopost on 4 5
+
+/autopost off - stop publishing
+
+/autopost template text - change post template with {title}, {author}, {preview}, {link} and {source} placeholders. HTML tags are allowed
+
+/autopost quiet 23:00-08:00 [timezone] - queue new posts during quiet hours, /autopost quiet off to disable`
+
+// DefaultAutopostTemplate is template of published posts if channel doesn't have its own.
+const DefaultAutopostTemplate = "📝 <b>{title}</b>\n{author}\n\n{preview}\n\n{link}"
+
+// autopostChannelsKey is a key of hash with settings of autopost channels by chat id.
+const autopostChannelsKey = "autopost:channels"
+
+// sampleAutopostPost is rendered with template when it's changed to check that Telegram accepts the result.
+var sampleAutopostPost = models.Post{Title: "Title", URL: "https://lesswrong.ru", Author: "Author"}
+
+// blankLines matches lines left empty by missing template values.
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+type (
+	// AutopostChannel is a chat which new posts from sources are published to automatically.
+	AutopostChannel struct {
+		ChatID   int64           `json:"chat_id"`
+		Sources  []models.Source `json:"sources"`
+		Template string          `json:"template,omitempty"`
+		// QuietHours is time range in format "HH:MM-HH:MM [timezone]" when posts are queued instead of published.
+		QuietHours string `json:"quiet_hours,omitempty"`
+	}
+
+	// QuietHours is daily time range in timezone. Range may cross midnight.
+	QuietHours struct {
+		Start    int
+		End      int
+		Timezone string
+	}
+
+	// queuedPost is a post waiting for the end of quiet hours.
+	queuedPost struct {
+		Source models.Source `json:"source"`
+		Post   models.Post   `json:"post"`
+	}
+)
+
+// ParseQuietHours parses quiet hours in format "HH:MM-HH:MM [timezone]", e.g. "23:00-08:00 Europe/Moscow". Default timezone is UTC.
+func ParseQuietHours(value string) (QuietHours, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours: %s", value)
+	}
+
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return QuietHours{}, fmt.Errorf("invalid quiet hours: %s", fields[0])
+	}
+
+	quiet := QuietHours{Timezone: "UTC"}
+
+	var err error
+
+	if quiet.Start, err = parseClock(start); err != nil {
+		return QuietHours{}, err
+	}
+
+	if quiet.End, err = parseClock(end); err != nil {
+		return QuietHours{}, err
+	}
+
+	if len(fields) > 1 {
+		quiet.Timezone = fields[1]
+
+		if _, err := time.LoadLocation(quiet.Timezone); err != nil {
+			return QuietHours{}, fmt.Errorf("invalid timezone: %s", quiet.Timezone)
+		}
+	}
+
+	return quiet, nil
+}
+
+// parseClock parses time in format HH:MM to minutes since midnight.
+func parseClock(value string) (int, error) {
+	hour, minute, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time: %s", value)
+	}
+
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("invalid hour: %s", hour)
+	}
+
+	m, err := strconv.Atoi(minute)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid minute: %s", minute)
+	}
+
+	return h*60 + m, nil
+}
+
+// Contains returns true if time is within quiet hours.
+func (q QuietHours) Contains(t time.Time) bool {
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	t = t.In(loc)
+	minute := t.Hour()*60 + t.Minute()
+
+	if q.Start <= q.End {
+		return minute >= q.Start && minute < q.End
+	}
+
+	return minute >= q.Start || minute < q.End
+}
+
+func (q QuietHours) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d %s", q.Start/60, q.Start%60, q.End/60, q.End%60, q.Timezone)
+}
+
+// Autopost changes autopost settings of chat. Without arguments returns current settings.
+func (b *Bot) Autopost(ctx context.Context, settingsID int, chatID int64, args string) (string, error) {
+	// Template may start on a new line.
+	command, rest := strings.TrimSpace(args), ""
+	if i := strings.IndexFunc(command, unicode.IsSpace); i != -1 {
+		command, rest = command[:i], strings.TrimSpace(command[i:])
+	}
+
+	channel, enabled, err := b.autopostChannel(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	switch strings.ToLower(command) {
+	case "":
+		if !enabled {
+			return "Autopost is off\n\n" + MessageAutopostUsage, nil
+		}
+
+		return b.autopostStatus(channel), nil
+	case "on":
+		sources := []Source{b.userSource(ctx, settingsID)}
+
+		if rest != "" {
+			sources = nil
+
+			for _, id := range strings.Fields(rest) {
+				source, ok := b.sources.Get(models.Source(id))
+				if !ok {
+					return "Source is invalid: " + renderer.Escape(id), nil
+				}
+
+				sources = append(sources, source)
+			}
+		}
+
+		channel.ChatID = chatID
+		channel.Sources = nil
+
+		for _, source := range sources {
+			if _, ok := source.(Subscribable); !ok {
+				return "Autopost is not supported for " + renderer.Escape(sourceURL(source)), nil
+			}
+
+			channel.Sources = append(channel.Sources, source.ID())
+		}
+
+		if err := b.setAutopostChannel(ctx, channel); err != nil {
+			return "", err
+		}
+
+		return b.autopostStatus(channel), nil
+	case "off":
+		if !enabled {
+			return "Autopost is off", nil
+		}
+
+		if err := b.storage.HDel(ctx, autopostChannelsKey, strconv.FormatInt(chatID, 10)); err != nil {
+			return "", fmt.Errorf("delete autopost channel failed: %s, chat: %d", err, chatID)
+		}
+
+		return "Autopost is off", nil
+	}
+
+	if !enabled {
+		return "Turn autopost on first with /autopost on", nil
+	}
+
+	switch strings.ToLower(command) {
+	case "template":
+		if err := validateAutopostTemplate(rest); err != nil {
+			return fmt.Sprintf("Invalid template: %s\n\n%s", renderer.Escape(err.Error()), MessageAutopostUsage), nil
+		}
+
+		channel.Template = rest
+	case "quiet":
+		if strings.EqualFold(rest, "off") {
+			channel.QuietHours = ""
+			break
+		}
+
+		quiet, err := ParseQuietHours(rest)
+		if err != nil {
+			return fmt.Sprintf("Invalid quiet hours: %s\n\n%s", renderer.Escape(err.Error()), MessageAutopostUsage), nil
+		}
+
+		channel.QuietHours = quiet.String()
+	default:
+		return MessageAutopostUsage, nil
+	}
+
+	if err := b.setAutopostChannel(ctx, channel); err != nil {
+		return "", err
+	}
+
+	return b.autopostStatus(channel), nil
+}
+
+func (b *Bot) autopostStatus(channel AutopostChannel) string {
+	var sources []string
+
+	for _, id := range channel.Sources {
+		if source, ok := b.sources.Get(id); ok {
+			sources = append(sources, sourceURL(source))
+		}
+	}
+
+	template := channel.Template
+	if template == "" {
+		template = DefaultAutopostTemplate
+	}
+
+	quiet := "off"
+	if channel.QuietHours != "" {
+		quiet = channel.QuietHours
+	}
+
+	return fmt.Sprintf("📣 Autopost publishes new posts from %s\n\nTemplate:\n<code>%s</code>\n\nQuiet hours: %s",
+		renderer.Escape(strings.Join(sources, ", ")), renderer.Escape(template), renderer.Escape(quiet))
+}
+
+// LoadAutopostFile saves autopost channels from JSON file overriding settings changed by commands.
+func (b *Bot) LoadAutopostFile(ctx context.Context, path string) error {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read autopost file failed: %s", err)
+	}
+
+	var configured []AutopostChannel
+
+	if err := json.Unmarshal(file, &configured); err != nil {
+		return fmt.Errorf("unmarshal autopost file failed: %s", err)
+	}
+
+	for _, channel := range configured {
+		for _, id := range channel.Sources {
+			source, ok := b.sources.Get(id)
+			if !ok {
+				return fmt.Errorf("autopost source is invalid: %s, chat: %d", id, channel.ChatID)
+			}
+
+			if _, ok := source.(Subscribable); !ok {
+				return fmt.Errorf("autopost is not supported for %s, chat: %d", source.Domain(), channel.ChatID)
+			}
+		}
+
+		if err := validateAutopostTemplate(channel.Template); err != nil {
+			return fmt.Errorf("invalid autopost template: %s, chat: %d", err, channel.ChatID)
+		}
+
+		if channel.QuietHours != "" {
+			if _, err := ParseQuietHours(channel.QuietHours); err != nil {
+				return fmt.Errorf("parse quiet hours failed: %s, chat: %d", err, channel.ChatID)
+			}
+		}
+
+		if err := b.setAutopostChannel(ctx, channel); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// autopostNewPost publishes post to channel or queues it during quiet hours.
+func (b *Bot) autopostNewPost(ctx context.Context, channel AutopostChannel, source Source, post models.Post) error {
+	if !b.quiet(channel) {
+		return b.publishToChannel(ctx, channel, source, post)
+	}
+
+	item, err := json.Marshal(queuedPost{Source: source.ID(), Post: post})
+	if err != nil {
+		return fmt.Errorf("marshal queued post failed: %s, url: %s", err, post.URL)
+	}
+
+	key := autopostQueueKey(channel.ChatID)
+
+	if err := b.storage.RPush(ctx, key, string(item)); err != nil {
+		return fmt.Errorf("queue autopost post failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// flushAutopostQueues publishes posts queued during quiet hours which are over.
+// Publishing stops at the first failed post, only published posts are removed from queue.
+func (b *Bot) flushAutopostQueues(ctx context.Context, channels map[int64]AutopostChannel) []error {
+	var errs []error
+
+	for _, channel := range channels {
+		if b.quiet(channel) {
+			continue
+		}
+
+		if err := b.flushAutopostQueue(ctx, channel); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func (b *Bot) flushAutopostQueue(ctx context.Context, channel AutopostChannel) error {
+	key := autopostQueueKey(channel.ChatID)
+
+	queue, err := b.storage.LRange(ctx, key, 0, -1)
+	if err != nil {
+		return fmt.Errorf("get autopost queue failed: %s, key: %s", err, key)
+	}
+
+	var (
+		published  int
+		publishErr error
+	)
+
+	for _, value := range queue {
+		var queued queuedPost
+		if err := json.Unmarshal([]byte(value), &queued); err != nil {
+			log.Printf("[ERROR] Unmarshal queued post failed: %s, key: %s", err, key)
+			published++
+
+			continue
+		}
+
+		if source, ok := b.sources.Get(queued.Source); ok {
+			if err := b.publishToChannel(ctx, channel, source, queued.Post); err != nil {
+				publishErr = err
+				break
+			}
+		}
+
+		published++
+	}
+
+	if published == 0 {
+		return publishErr
+	}
+
+	// Posts are only appended to the end of queue, so trimming published ones keeps posts queued meanwhile.
+	if err := b.storage.LTrim(ctx, key, int64(published), -1); err != nil {
+		return fmt.Errorf("trim autopost queue failed: %s, key: %s", err, key)
+	}
+
+	return publishErr
+}
+
+// publishToChannel sends post to channel using channel template unless it's in log of last published posts.
+// Post is logged only after it's sent, so failed post is retried, but restart between the two can publish it twice.
+func (b *Bot) publishToChannel(ctx context.Context, channel AutopostChannel, source Source, post models.Post) error {
+	key := fmt.Sprintf("autopost:published:%d", channel.ChatID)
+
+	published, err := b.storage.LRange(ctx, key, 0, -1)
+	if err != nil {
+		return fmt.Errorf("get published posts failed: %s, key: %s", err, key)
+	}
+
+	for _, url := range published {
+		if url == post.URL {
+			return nil
+		}
+	}
+
+	msg := tgbotapi.NewMessage(channel.ChatID, b.autopostText(ctx, channel, source, post))
+	msg.ParseMode = tgbotapi.ModeHTML
+
+	if _, err := b.botAPI.Send(msg); err != nil {
+		return fmt.Errorf("publish post failed: %s, chat: %d, url: %s", err, channel.ChatID, post.URL)
+	}
+
+	if err := b.storage.LPush(ctx, key, post.URL); err != nil {
+		return fmt.Errorf("push published post failed: %s, key: %s", err, key)
+	}
+
+	if err := b.storage.LTrim(ctx, key, 0, models.PublishedMaxCount-1); err != nil {
+		return fmt.Errorf("trim published posts failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// autopostText renders post with channel template. Latest posts of some sources have no body, so it's fetched for preview.
+func (b *Bot) autopostText(ctx context.Context, channel AutopostChannel, source Source, post models.Post) string {
+	if post.HTML == "" {
+		fetched, err := source.Post(ctx, post.URL)
+		if err != nil {
+			log.Printf("[ERROR] Get %s post for autopost failed: %s", source.Domain(), err)
+		} else {
+			post.HTML = fetched.HTML
+		}
+	}
+
+	preview, err := renderer.Render(post.HTML, post.URL)
+	if err != nil {
+		log.Printf("[ERROR] Render %s post for autopost failed: %s", source.Domain(), err)
+		preview = ""
+	}
+
+	return fillAutopostTemplate(channel.Template, post, renderer.Truncate(preview, models.PostMaxLength), source.Name())
+}
+
+// fillAutopostTemplate replaces placeholders of template with escaped post fields. Empty template is default one.
+func fillAutopostTemplate(template string, post models.Post, preview, sourceName string) string {
+	if template == "" {
+		template = DefaultAutopostTemplate
+	}
+
+	text := strings.NewReplacer(
+		"{title}", renderer.Escape(post.Title),
+		"{author}", renderer.Escape(post.Author),
+		"{preview}", preview,
+		"{link}", renderer.Escape(post.URL),
+		"{source}", renderer.Escape(sourceName),
+	).Replace(template)
+
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n"))
+}
+
+// validateAutopostTemplate renders sample post with template and returns error if Telegram would reject it.
+func validateAutopostTemplate(template string) error {
+	return renderer.Validate(fillAutopostTemplate(template, sampleAutopostPost, "<b>Preview</b>", "Source"))
+}
+
+// quiet returns true if channel has quiet hours now.
+func (b *Bot) quiet(channel AutopostChannel) bool {
+	if channel.QuietHours == "" {
+		return false
+	}
+
+	quiet, err := ParseQuietHours(channel.QuietHours)
+	if err != nil {
+		log.Printf("[ERROR] Parse quiet hours failed: %s, chat: %d", err, channel.ChatID)
+		return false
+	}
+
+	return quiet.Contains(b.now())
+}
+
+// autopostSubscribers returns channels which publish posts from source.
+func autopostSubscribers(channels map[int64]AutopostChannel, sourceID models.Source) []AutopostChannel {
+	var subscribers []AutopostChannel
+
+	for _, channel := range channels {
+		for _, id := range channel.Sources {
+			if id == sourceID {
+				subscribers = append(subscribers, channel)
+				break
+			}
+		}
+	}
+
+	return subscribers
+}
+
+func (b *Bot) autopostChannels(ctx context.Context) (map[int64]AutopostChannel, error) {
+	channelsCached, err := b.storage.HGetAll(ctx, autopostChannelsKey)
+	if err != nil {
+		return nil, fmt.Errorf("get autopost channels failed: %s", err)
+	}
+
+	channels := make(map[int64]AutopostChannel, len(channelsCached))
+
+	for field, channelCached := range channelsCached {
+		var channel AutopostChannel
+
+		if err := json.Unmarshal([]byte(channelCached), &channel); err != nil {
+			return nil, fmt.Errorf("unmarshal autopost channel failed: %s, chat: %s", err, field)
+		}
+
+		channels[channel.ChatID] = channel
+	}
+
+	return channels, nil
+}
+
+// autopostChannel returns settings of chat and false if autopost is off.
+func (b *Bot) autopostChannel(ctx context.Context, chatID int64) (AutopostChannel, bool, error) {
+	channelCached, err := b.storage.HGet(ctx, autopostChannelsKey, strconv.FormatInt(chatID, 10))
+	if err != nil {
+		return AutopostChannel{}, false, fmt.Errorf("get autopost channel failed: %s, chat: %d", err, chatID)
+	}
+
+	if channelCached == "" {
+		return AutopostChannel{}, false, nil
+	}
+
+	var channel AutopostChannel
+
+	if err := json.Unmarshal([]byte(channelCached), &channel); err != nil {
+		return AutopostChannel{}, false, fmt.Errorf("unmarshal autopost channel failed: %s, chat: %d", err, chatID)
+	}
+
+	return channel, true, nil
+}
+
+func (b *Bot) setAutopostChannel(ctx context.Context, channel AutopostChannel) error {
+	channelCache, err := json.Marshal(channel)
+	if err != nil {
+		return fmt.Errorf("marshal autopost channel failed: %s, chat: %d", err, channel.ChatID)
+	}
+
+	if err := b.storage.HSet(ctx, autopostChannelsKey, strconv.FormatInt(channel.ChatID, 10), string(channelCache)); err != nil {
+		return fmt.Errorf("set autopost channel failed: %s, chat: %d", err, channel.ChatID)
+	}
+
+	return nil
+}
+
+// autopostQueueKey is a key of list with posts queued during quiet hours, oldest first.
+func autopostQueueKey(chatID int64) string {
+	return fmt.Sprintf("autopost:queue:%d", chatID)
+}
@@ -0,0 +1,303 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+func TestAutopost(t *testing.T) {
+	const channelID = -200
+
+	file, err := os.ReadFile("testdata/astral_new_posts.json")
+	require.NoError(t, err)
+
+	var archive []models.AstralPost
+	require.NoError(t, json.Unmarshal(file, &archive))
+
+	latest := archive
+
+	httpClient := &mocks.HTTPClient{}
+
+	httpClient.On("Get", context.TODO(), "https://astralcodexten.substack.com/api/v1/archive?sort=new&limit=12&offset=0").Return(
+		func(context.Context, string) *http.Response {
+			body, err := json.Marshal(latest)
+			require.NoError(t, err)
+
+			return &http.Response{Body: io.NopCloser(bytes.NewBuffer(body))}
+		},
+		nil,
+	)
+
+	now := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+	storage := memory.NewStorage()
+	telegram, botAPI := newTelegramStub(t)
+
+	newBot := func() *Bot {
+		tgbot, err := New(Options{
+			BotAPI:     botAPI,
+			HTTPClient: httpClient,
+			Storage:    storage,
+			Now:        func() time.Time { return now },
+		})
+		require.NoError(t, err)
+
+		return tgbot
+	}
+
+	tgbot := newBot()
+
+	autopost := func(args string) string {
+		sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+			ChannelPost: &tgbotapi.Message{
+				Chat:     &tgbotapi.Chat{ID: channelID, Type: "channel"},
+				Text:     "/autopost " + args,
+				Entities: &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len("/autopost")}},
+			},
+		})
+		require.NoError(t, err)
+
+		return sent.Text
+	}
+
+	// Published posts are all messages sent after autopost is configured.
+	published := func() []string {
+		var texts []string
+
+		for _, message := range telegram.Sent("sendMessage") {
+			require.Equal(t, fmt.Sprint(channelID), message.Get("chat_id"))
+			texts = append(texts, message.Get("text"))
+		}
+
+		return texts
+	}
+
+	require.Equal(t, "Autopost is off\n\n"+MessageAutopostUsage, autopost(""))
+	require.Equal(t, "Turn autopost on first with /autopost on", autopost("quiet 23:00-08:00"))
+	require.Equal(t, "Autopost is not supported for https://lesswrong.ru", autopost("on 1"))
+	require.Equal(t, "📣 Autopost publishes new posts from https://astralcodexten.substack.com\n\nTemplate:\n<code>"+
+		"📝 &lt;b&gt;{title}&lt;/b&gt;\n{author}\n\n{preview}\n\n{link}</code>\n\nQuiet hours: off", autopost("on 3"))
+	require.Contains(t, autopost("template\n<b>{title}</b> ({source})\n{author}\n\n{preview}\n\n{link}"), "<code>&lt;b&gt;{title}&lt;/b&gt; ({source})")
+	require.Equal(t, "Invalid template: tag isn't closed: b\n\n"+MessageAutopostUsage, autopost("template <b>{title}"))
+	require.Contains(t, autopost(""), "<code>&lt;b&gt;{title}&lt;/b&gt; ({source})")
+	require.Equal(t, "Invalid quiet hours: invalid hour: 25\n\n"+MessageAutopostUsage, autopost("quiet 25:00-08:00"))
+	require.Contains(t, autopost("quiet 23:00-08:00 UTC"), "Quiet hours: 23:00-08:00 UTC")
+
+	telegram.Reset()
+
+	// The first poll only remembers latest posts.
+	require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+	require.Empty(t, telegram.Sent("sendMessage"))
+
+	latest = append([]models.AstralPost{
+		{Title: "Still Alive", CanonicalURL: "https://astralcodexten.substack.com/p/still-alive", Audience: "everyone", BodyHTML: "<p>This was always going to be a story.</p>"},
+	}, archive...)
+
+	require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+	require.Equal(t, []string{`<b>Still Alive</b> (Astral Codex Ten)
+
+This was always going to be a story.
+
+https://astralcodexten.substack.com/p/still-alive`}, published())
+
+	// Restart with lost dedupe state doesn't publish the same post again.
+	seenCached, err := storage.Get(context.TODO(), "seen:3")
+	require.NoError(t, err)
+
+	var seen map[string]time.Time
+	require.NoError(t, json.Unmarshal([]byte(seenCached), &seen))
+
+	delete(seen, "https://astralcodexten.substack.com/p/still-alive")
+
+	seenCache, err := json.Marshal(seen)
+	require.NoError(t, err)
+	require.NoError(t, storage.Set(context.TODO(), "seen:3", string(seenCache), 0))
+
+	tgbot = newBot()
+
+	require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+	require.Len(t, published(), 1)
+
+	// Posts are queued during quiet hours.
+	now = time.Date(2021, 5, 1, 23, 30, 0, 0, time.UTC)
+	latest = append([]models.AstralPost{
+		{Title: "Ontology Of Psychiatric Conditions", CanonicalURL: "https://astralcodexten.substack.com/p/ontology", Audience: "everyone"},
+	}, latest...)
+
+	httpClient.On("Get", context.TODO(), "https://astralcodexten.substack.com/api/v1/posts/ontology").Return(
+		&http.Response{Body: io.NopCloser(bytes.NewBufferString(`{"title":"Ontology Of Psychiatric Conditions","body_html":"<p>Taxometrics.</p>"}`))},
+		nil,
+	).Once()
+
+	require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+	require.Len(t, published(), 1)
+
+	now = time.Date(2021, 5, 2, 8, 0, 0, 0, time.UTC)
+
+	require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+	require.Len(t, published(), 2)
+	require.Equal(t, `<b>Ontology Of Psychiatric Conditions</b> (Astral Codex Ten)
+
+Taxometrics.
+
+https://astralcodexten.substack.com/p/ontology`, published()[1])
+
+	require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+	require.Len(t, published(), 2)
+
+	// Post is logged as published only after it's sent.
+	channels, err := tgbot.autopostChannels(context.TODO())
+	require.NoError(t, err)
+
+	source, ok := tgbot.sources.Get("3")
+	require.True(t, ok)
+
+	post := models.Post{Title: "Failed", URL: "https://astralcodexten.substack.com/p/failed", HTML: "<p>Text</p>"}
+
+	telegram.Fail(true)
+	require.Error(t, tgbot.publishToChannel(context.TODO(), channels[channelID], source, post))
+	telegram.Fail(false)
+
+	require.NoError(t, tgbot.publishToChannel(context.TODO(), channels[channelID], source, post))
+
+	logged, err := storage.LRange(context.TODO(), fmt.Sprintf("autopost:published:%d", channelID), 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []string{post.URL, "https://astralcodexten.substack.com/p/ontology", "https://astralcodexten.substack.com/p/still-alive"}, logged)
+
+	// Queued posts are kept until they are published.
+	now = time.Date(2021, 5, 2, 23, 30, 0, 0, time.UTC)
+
+	for _, title := range []string{"First", "Second"} {
+		queued := models.Post{Title: title, URL: "https://astralcodexten.substack.com/p/" + title, HTML: "<p>Text</p>"}
+		require.NoError(t, tgbot.autopostNewPost(context.TODO(), channels[channelID], source, queued))
+	}
+
+	now = time.Date(2021, 5, 3, 8, 0, 0, 0, time.UTC)
+
+	telegram.Fail(true)
+	require.Len(t, tgbot.flushAutopostQueues(context.TODO(), channels), 1)
+	telegram.Fail(false)
+
+	queue, err := storage.LRange(context.TODO(), fmt.Sprintf("autopost:queue:%d", channelID), 0, -1)
+	require.NoError(t, err)
+	require.Len(t, queue, 2)
+
+	require.Empty(t, tgbot.flushAutopostQueues(context.TODO(), channels))
+	require.Equal(t, []string{
+		"<b>First</b> (Astral Codex Ten)\n\nText\n\nhttps://astralcodexten.substack.com/p/First",
+		"<b>Second</b> (Astral Codex Ten)\n\nText\n\nhttps://astralcodexten.substack.com/p/Second",
+	}, published()[len(published())-2:])
+
+	queue, err = storage.LRange(context.TODO(), fmt.Sprintf("autopost:queue:%d", channelID), 0, -1)
+	require.NoError(t, err)
+	require.Empty(t, queue)
+
+	require.Equal(t, "Autopost is off", autopost("off"))
+
+	httpClient.AssertExpectations(t)
+}
+
+func TestLoadAutopostFile(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "Should load channels",
+			content: `[{"chat_id":-200,"sources":["3","4"],"template":"{title}","quiet_hours":"23:00-08:00 Europe/Moscow"}]`,
+		},
+		{
+			name:    "Should fail on unsupported source",
+			content: `[{"chat_id":-200,"sources":["1"]}]`,
+			wantErr: "autopost is not supported for lesswrong.ru, chat: -200",
+		},
+		{
+			name:    "Should fail on invalid template",
+			content: `[{"chat_id":-200,"sources":["3"],"template":"<h1>{title}</h1>"}]`,
+			wantErr: "invalid autopost template: tag isn't supported: h1, chat: -200",
+		},
+		{
+			name:    "Should fail on invalid quiet hours",
+			content: `[{"chat_id":-200,"sources":["3"],"quiet_hours":"night"}]`,
+			wantErr: "parse quiet hours failed: invalid quiet hours: night, chat: -200",
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(Options{
+				BotAPI: &tgbotapi.BotAPI{},
+				Config: config.Config{AutopostFile: write(fmt.Sprintf("autopost-%d.json", i), tt.content)},
+			})
+
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+
+	storage := memory.NewStorage()
+
+	tgbot, err := New(Options{
+		BotAPI:  &tgbotapi.BotAPI{},
+		Storage: storage,
+		Config:  config.Config{AutopostFile: write("autopost.json", tests[0].content)},
+	})
+	require.NoError(t, err)
+
+	text, err := tgbot.Autopost(context.TODO(), -200, -200, "")
+	require.NoError(t, err)
+	require.Equal(t, "📣 Autopost publishes new posts from https://astralcodexten.substack.com, https://lesswrong.com\n\nTemplate:\n<code>{title}</code>\n\nQuiet hours: 23:00-08:00 Europe/Moscow", text)
+}
+
+func TestQuietHours(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		at    time.Time
+		want  bool
+	}{
+		{name: "Should be quiet after start", value: "23:00-08:00", at: time.Date(2021, 5, 1, 23, 0, 0, 0, time.UTC), want: true},
+		{name: "Should be quiet after midnight", value: "23:00-08:00", at: time.Date(2021, 5, 1, 7, 59, 0, 0, time.UTC), want: true},
+		{name: "Should not be quiet at end", value: "23:00-08:00", at: time.Date(2021, 5, 1, 8, 0, 0, 0, time.UTC)},
+		{name: "Should be quiet within day range", value: "12:00-14:00", at: time.Date(2021, 5, 1, 13, 0, 0, 0, time.UTC), want: true},
+		{name: "Should not be quiet outside day range", value: "12:00-14:00", at: time.Date(2021, 5, 1, 15, 0, 0, 0, time.UTC)},
+		{name: "Should use timezone", value: "23:00-08:00 Europe/Moscow", at: time.Date(2021, 5, 1, 21, 0, 0, 0, time.UTC), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quiet, err := ParseQuietHours(tt.value)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, quiet.Contains(tt.at))
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+const (
+	// saveCallbackPrefix marks callback data of save buttons in format save:<post key>.
+	saveCallbackPrefix = "save:"
+	// savedCallbackPrefix marks callback data of bookmarks navigation buttons in format saved:<page>.
+	savedCallbackPrefix = "saved:"
+	// unsaveCallbackPrefix marks callback data of remove buttons in format unsave:<post key>:<page>.
+	unsaveCallbackPrefix = "unsave:"
+)
+
+// bookmark is a post saved by user.
+type bookmark struct {
+	Title   string    `json:"title"`
+	URL     string    `json:"url"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// SaveBookmark saves post by post key to user bookmarks. Returns text of callback answer.
+func (b *Bot) SaveBookmark(ctx context.Context, userID int, key string) (string, error) {
+	postURL, err := b.storage.Get(ctx, "post:"+key)
+	if err != nil {
+		return "", fmt.Errorf("get post url failed: %s, key: %s", err, key)
+	}
+
+	if postURL == "" {
+		return "Post not found", nil
+	}
+
+	title, err := b.storage.Get(ctx, "title:"+key)
+	if err != nil {
+		return "", fmt.Errorf("get post title failed: %s, key: %s", err, key)
+	}
+
+	if title == "" {
+		title = postURL
+	}
+
+	savedKey := bookmarksKey(userID)
+
+	saved, err := b.storage.HGet(ctx, savedKey, key)
+	if err != nil {
+		return "", fmt.Errorf("get bookmark failed: %s, key: %s", err, savedKey)
+	}
+
+	if saved != "" {
+		return "Already saved", nil
+	}
+
+	bookmarkCache, err := json.Marshal(bookmark{Title: title, URL: postURL, SavedAt: b.now()})
+	if err != nil {
+		return "", fmt.Errorf("marshal bookmark failed: %s, key: %s", err, savedKey)
+	}
+
+	// Each bookmark is a separate field, so concurrent saves don't overwrite each other.
+	if err := b.storage.HSet(ctx, savedKey, key, string(bookmarkCache)); err != nil {
+		return "", fmt.Errorf("set bookmark failed: %s, key: %s", err, savedKey)
+	}
+
+	return "Saved to /saved", nil
+}
+
+// RemoveBookmark removes post by post key from user bookmarks.
+func (b *Bot) RemoveBookmark(ctx context.Context, userID int, key string) error {
+	savedKey := bookmarksKey(userID)
+
+	if err := b.storage.HDel(ctx, savedKey, key); err != nil {
+		return fmt.Errorf("remove bookmark failed: %s, key: %s", err, savedKey)
+	}
+
+	return nil
+}
+
+// Bookmarks returns page of user bookmarks with remove buttons. Page is zero based and is clamped to pages count.
+func (b *Bot) Bookmarks(ctx context.Context, userID int, page int) (string, interface{}, error) {
+	bookmarks, err := b.bookmarks(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(bookmarks) == 0 {
+		return "No saved posts yet. Press ⭐ Save under a post to bookmark it", nil, nil
+	}
+
+	pages := (len(bookmarks) + models.BookmarksPageSize - 1) / models.BookmarksPageSize
+
+	if page >= pages {
+		page = pages - 1
+	}
+
+	if page < 0 {
+		page = 0
+	}
+
+	start := page * models.BookmarksPageSize
+	end := min(start+models.BookmarksPageSize, len(bookmarks))
+
+	var (
+		text    strings.Builder
+		buttons []tgbotapi.InlineKeyboardButton
+	)
+
+	text.WriteString(fmt.Sprintf("⭐ Saved posts (%d):\n\n", len(bookmarks)))
+
+	for i, bookmark := range bookmarks[start:end] {
+		n := strconv.Itoa(start + i + 1)
+
+		text.WriteString(fmt.Sprintf("%s. %s\n\n", n, renderer.Link(bookmark.URL, bookmark.Title)))
+
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(
+			"❌ "+n,
+			fmt.Sprintf("%s%s:%d", unsaveCallbackPrefix, postKey(bookmark.URL), page),
+		))
+	}
+
+	text.WriteString(fmt.Sprintf("Page %d/%d\n\nExport as Markdown: /saved export", page+1, pages))
+
+	rows := [][]tgbotapi.InlineKeyboardButton{buttons}
+
+	var navigation []tgbotapi.InlineKeyboardButton
+
+	if page > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("◀ Prev", savedCallbackPrefix+strconv.Itoa(page-1)))
+	}
+
+	if page < pages-1 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("Next ▶", savedCallbackPrefix+strconv.Itoa(page+1)))
+	}
+
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// ExportBookmarks returns user bookmarks as Markdown list.
+func (b *Bot) ExportBookmarks(ctx context.Context, userID int) ([]byte, error) {
+	bookmarks, err := b.bookmarks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	text := bytes.NewBufferString("# Saved posts\n\n")
+
+	for _, bookmark := range bookmarks {
+		title := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(bookmark.Title)
+
+		text.WriteString(fmt.Sprintf("- [%s](%s) (%s)\n", title, bookmark.URL, bookmark.SavedAt.Format("2006-01-02")))
+	}
+
+	return text.Bytes(), nil
+}
+
+// sendBookmarksExport sends user bookmarks as Markdown file.
+func (b *Bot) sendBookmarksExport(ctx context.Context, chatID int64, userID int) (tgbotapi.Message, error) {
+	export, err := b.ExportBookmarks(ctx, userID)
+	if err != nil {
+		log.Printf("[ERROR] Export bookmarks failed: %s", err)
+
+		sent, err := b.botAPI.Send(tgbotapi.NewMessage(chatID, "Export failed"))
+		if err != nil {
+			return tgbotapi.Message{}, fmt.Errorf("send message failed: %s", err)
+		}
+
+		return sent, nil
+	}
+
+	doc := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: "bookmarks.md", Bytes: export})
+
+	sent, err := b.botAPI.Send(doc)
+	if err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("send bookmarks export failed: %s, chat: %d", err, chatID)
+	}
+
+	return sent, nil
+}
+
+// bookmarks returns user bookmarks, newest first.
+func (b *Bot) bookmarks(ctx context.Context, userID int) ([]bookmark, error) {
+	key := bookmarksKey(userID)
+
+	bookmarksCached, err := b.storage.HGetAll(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("get bookmarks failed: %s, key: %s", err, key)
+	}
+
+	bookmarks := make([]bookmark, 0, len(bookmarksCached))
+
+	for _, bookmarkCached := range bookmarksCached {
+		var bookmark bookmark
+
+		if err := json.Unmarshal([]byte(bookmarkCached), &bookmark); err != nil {
+			return nil, fmt.Errorf("unmarshal bookmark failed: %s, key: %s", err, key)
+		}
+
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	sort.Slice(bookmarks, func(i, j int) bool {
+		if !bookmarks[i].SavedAt.Equal(bookmarks[j].SavedAt) {
+			return bookmarks[i].SavedAt.After(bookmarks[j].SavedAt)
+		}
+
+		return bookmarks[i].URL < bookmarks[j].URL
+	})
+
+	return bookmarks, nil
+}
+
+// bookmarksKey is a key of hash with user bookmarks by post key.
+func bookmarksKey(userID int) string {
+	return fmt.Sprintf("bookmarks:%d", userID)
+}
+
+// parseUnsaveCallback parses callback data in format unsave:<post key>:<page>.
+func parseUnsaveCallback(data string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(data, unsaveCallbackPrefix)
+	if !ok {
+		return "", 0, false
+	}
+
+	key, pageText, ok := strings.Cut(rest, ":")
+	if !ok || key == "" {
+		return "", 0, false
+	}
+
+	page, err := strconv.Atoi(pageText)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return key, page, true
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestBookmarks(t *testing.T) {
+	telegram, botAPI := newTelegramStub(t)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tgbot, err := New(Options{
+		BotAPI: botAPI,
+		Now: func() time.Time {
+			now = now.Add(time.Minute)
+			return now
+		},
+	})
+	require.NoError(t, err)
+
+	callback := func(data string) tgbotapi.Update {
+		return tgbotapi.Update{
+			CallbackQuery: &tgbotapi.CallbackQuery{
+				ID:      "callback",
+				From:    &tgbotapi.User{ID: 1},
+				Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}},
+				Data:    data,
+			},
+		}
+	}
+
+	command := func(text string) tgbotapi.Update {
+		return tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1},
+				Chat:     &tgbotapi.Chat{ID: 1},
+				Text:     text,
+				Entities: &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len("/saved")}},
+			},
+		}
+	}
+
+	sent, err := tgbot.MessageHandler(context.TODO(), command("/saved"))
+	require.NoError(t, err)
+	require.Equal(t, "No saved posts yet. Press ⭐ Save under a post to bookmark it", sent.Text)
+
+	var saveData []string
+
+	for i := 1; i <= 6; i++ {
+		keyboard, err := tgbot.postKeyboard(context.TODO(), models.Post{
+			Title: fmt.Sprintf("Post [%d]", i),
+			URL:   fmt.Sprintf("https://slatestarcodex.com/post-%d/", i),
+		})
+		require.NoError(t, err)
+		require.Equal(t, "⭐ Save", keyboard.InlineKeyboard[0][1].Text)
+
+		saveData = append(saveData, *keyboard.InlineKeyboard[0][1].CallbackData)
+	}
+
+	for _, data := range append(saveData, saveData[0], saveCallbackPrefix+"unknown") {
+		_, err := tgbot.MessageHandler(context.TODO(), callback(data))
+		require.NoError(t, err)
+	}
+
+	var answers []string
+	for _, answer := range telegram.Sent("answerCallbackQuery") {
+		answers = append(answers, answer.Get("text"))
+	}
+
+	require.Equal(t, []string{
+		"Saved to /saved", "Saved to /saved", "Saved to /saved", "Saved to /saved", "Saved to /saved", "Saved to /saved",
+		"Already saved",
+		"Post not found",
+	}, answers)
+
+	sent, err = tgbot.MessageHandler(context.TODO(), command("/saved"))
+	require.NoError(t, err)
+	require.Equal(t, `⭐ Saved posts (6):
+
+1. <a href="https://slatestarcodex.com/post-6/">Post [6]</a>
+
+2. <a href="https://slatestarcodex.com/post-5/">Post [5]</a>
+
+3. <a href="https://slatestarcodex.com/post-4/">Post [4]</a>
+
+4. <a href="https://slatestarcodex.com/post-3/">Post [3]</a>
+
+5. <a href="https://slatestarcodex.com/post-2/">Post [2]</a>
+
+Page 1/2
+
+Export as Markdown: /saved export`, sent.Text)
+
+	messages := telegram.Sent("sendMessage")
+	require.Equal(t, "HTML", messages[len(messages)-1].Get("parse_mode"))
+
+	var markup tgbotapi.InlineKeyboardMarkup
+	require.NoError(t, json.Unmarshal([]byte(messages[len(messages)-1].Get("reply_markup")), &markup))
+	require.Len(t, markup.InlineKeyboard, 2)
+	require.Equal(t, "❌ 1", markup.InlineKeyboard[0][0].Text)
+	require.Equal(t, "Next ▶", markup.InlineKeyboard[1][0].Text)
+
+	// Remove the first post on the second page.
+	sent, err = tgbot.MessageHandler(context.TODO(), callback(savedCallbackPrefix+"1"))
+	require.NoError(t, err)
+	require.Contains(t, sent.Text, "6. <a href=\"https://slatestarcodex.com/post-1/\">Post [1]</a>")
+	require.Contains(t, sent.Text, "Page 2/2")
+
+	require.NoError(t, json.Unmarshal([]byte(telegram.Sent("editMessageText")[0].Get("reply_markup")), &markup))
+
+	sent, err = tgbot.MessageHandler(context.TODO(), callback(*markup.InlineKeyboard[0][0].CallbackData))
+	require.NoError(t, err)
+	require.Contains(t, sent.Text, "⭐ Saved posts (5):")
+	require.Contains(t, sent.Text, "Page 1/1")
+	require.NotContains(t, sent.Text, "Post [1]")
+
+	export, err := tgbot.ExportBookmarks(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Equal(t, `# Saved posts
+
+- [Post \[6\]](https://slatestarcodex.com/post-6/) (2021-05-01)
+- [Post \[5\]](https://slatestarcodex.com/post-5/) (2021-05-01)
+- [Post \[4\]](https://slatestarcodex.com/post-4/) (2021-05-01)
+- [Post \[3\]](https://slatestarcodex.com/post-3/) (2021-05-01)
+- [Post \[2\]](https://slatestarcodex.com/post-2/) (2021-05-01)
+`, string(export))
+
+	_, err = tgbot.MessageHandler(context.TODO(), command("/saved export"))
+	require.NoError(t, err)
+
+	documents := telegram.Sent("sendDocument")
+	require.Len(t, documents, 1)
+	require.Equal(t, "1", documents[0].Get("chat_id"))
+
+	// Bookmarks are stored per user.
+	text, _, err := tgbot.Bookmarks(context.TODO(), 2, 0)
+	require.NoError(t, err)
+	require.Equal(t, "No saved posts yet. Press ⭐ Save under a post to bookmark it", text)
+}
+
+func TestBookmarksConcurrentSave(t *testing.T) {
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for n := 0; n < 10; n++ {
+		keyboard, err := tgbot.postKeyboard(context.TODO(), models.Post{
+			Title: fmt.Sprintf("Post %d", n),
+			URL:   fmt.Sprintf("https://slatestarcodex.com/post-%d/", n),
+		})
+		require.NoError(t, err)
+
+		key := strings.TrimPrefix(*keyboard.InlineKeyboard[0][1].CallbackData, saveCallbackPrefix)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			text, err := tgbot.SaveBookmark(context.TODO(), 1, key)
+			require.NoError(t, err)
+			require.Equal(t, "Saved to /saved", text)
+		}()
+	}
+
+	wg.Wait()
+
+	bookmarks, err := tgbot.bookmarks(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Len(t, bookmarks, 10)
+}
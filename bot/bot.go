@@ -7,34 +7,19 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 
 	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/index"
 	"github.com/ndrewnee/lesswrong-bot/models"
 	"github.com/ndrewnee/lesswrong-bot/storage/memory"
 )
 
-const (
-	MessageHelp = `🤖 I'm a bot for reading posts:
-
-Commands:
-
-/top - Top posts
-
-/random - Read random post
-
-/source - Change source:
-
-  1. [Lesswrong.ru](https://lesswrong.ru) (default)
-  2. [Slate Star Codex](https://slatestarcodex.com)
-  3. [Astral Codex Ten](https://astralcodexten.substack.com)
-  4. [Lesswrong.com](https://lesswrong.com)
-
-/help - Help`
-)
-
 var mainKeyboard = tgbotapi.NewReplyKeyboard(
 	tgbotapi.NewKeyboardButtonRow(
 		tgbotapi.NewKeyboardButton("/top"),
@@ -50,6 +35,14 @@ type (
 		httpClient HTTPClient
 		storage    Storage
 		randomInt  func(n int) int
+		now        func() time.Time
+		sources    *Registry
+		scheduler  *scheduler
+		telegraph  Telegraph
+		index      *index.Index
+		metrics    *Metrics
+		// transport sends requests of scrapers, so they're observed like requests of httpClient.
+		transport http.RoundTripper
 	}
 
 	Options struct {
@@ -58,6 +51,9 @@ type (
 		HTTPClient HTTPClient
 		Storage    Storage
 		RandomInt  func(n int) int
+		Now        func() time.Time
+		Telegraph  Telegraph
+		Metrics    *Metrics
 	}
 
 	HTTPClient interface {
@@ -65,9 +61,35 @@ type (
 		Post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error)
 	}
 
+	// Storage keeps strings, sets, hashes and lists like redis does. Getters return zero values for missing keys.
 	Storage interface {
 		Get(ctx context.Context, key string) (string, error)
 		Set(ctx context.Context, key, value string, expire time.Duration) error
+		// SetNX sets value only if key doesn't exist and returns true if it was set.
+		SetNX(ctx context.Context, key, value string, expire time.Duration) (bool, error)
+		Exists(ctx context.Context, key string) (bool, error)
+		Del(ctx context.Context, key string) error
+		Incr(ctx context.Context, key string) (int64, error)
+
+		HSet(ctx context.Context, key, field, value string) error
+		HGet(ctx context.Context, key, field string) (string, error)
+		HGetAll(ctx context.Context, key string) (map[string]string, error)
+		HDel(ctx context.Context, key string, fields ...string) error
+
+		SAdd(ctx context.Context, key string, members ...string) error
+		SRem(ctx context.Context, key string, members ...string) error
+		SIsMember(ctx context.Context, key, member string) (bool, error)
+		SMembers(ctx context.Context, key string) ([]string, error)
+
+		LPush(ctx context.Context, key string, values ...string) error
+		RPush(ctx context.Context, key string, values ...string) error
+		// LRange returns elements from start to stop inclusive, negative indexes count from the end.
+		LRange(ctx context.Context, key string, start, stop int64) ([]string, error)
+		LTrim(ctx context.Context, key string, start, stop int64) error
+	}
+
+	Telegraph interface {
+		CreatePage(ctx context.Context, page TelegraphPage) (string, error)
 	}
 )
 
@@ -78,7 +100,7 @@ func New(options ...Options) (*Bot, error) {
 		opts = options[0]
 	}
 
-	if opts.Config == (config.Config{}) {
+	if reflect.DeepEqual(opts.Config, config.Config{}) {
 		opts.Config = config.Parse()
 	}
 
@@ -94,25 +116,108 @@ func New(options ...Options) (*Bot, error) {
 
 	log.Printf("Authorized on account %s", opts.BotAPI.Self.UserName)
 
-	if opts.HTTPClient == nil {
-		opts.HTTPClient = NewHTTPClient()
+	if opts.Metrics == nil {
+		opts.Metrics = NewMetrics()
 	}
 
+	opts.BotAPI.Client = instrumentTelegram(opts.BotAPI.Client, opts.Metrics)
+
 	if opts.Storage == nil {
 		opts.Storage = memory.NewStorage()
 	}
 
+	opts.Storage = NewInstrumentedStorage(opts.Storage, opts.Metrics)
+
+	// Every request to sources is observed including retries and scraping.
+	upstream := instrumentUpstream(http.DefaultTransport, opts.Metrics)
+
+	// Telegraph requests change pages, so they aren't cached and aren't retried.
+	telegraphClient := opts.HTTPClient
+
+	if opts.HTTPClient == nil {
+		telegraphClient = NewHTTPClientWithOptions(HTTPClientOptions{Client: &http.Client{Transport: upstream}, MaxRetries: -1})
+		opts.HTTPClient = NewCachingHTTPClient(
+			NewHTTPClientWithOptions(HTTPClientOptions{Client: &http.Client{Transport: upstream}}),
+			opts.Storage,
+			opts.Config.CacheExpire,
+		)
+	}
+
 	if opts.RandomInt == nil {
 		opts.RandomInt = rand.Intn
 	}
 
-	return &Bot{
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	if opts.Telegraph == nil {
+		opts.Telegraph = NewTelegraphClient(telegraphClient, opts.Storage, models.TelegraphAPI, opts.Config.TelegraphToken)
+	}
+
+	b := &Bot{
 		botAPI:     opts.BotAPI,
 		config:     opts.Config,
 		httpClient: opts.HTTPClient,
 		storage:    opts.Storage,
 		randomInt:  opts.RandomInt,
-	}, nil
+		now:        opts.Now,
+		sources:    NewRegistry(),
+		scheduler:  newScheduler(opts.Storage, opts.Now),
+		telegraph:  opts.Telegraph,
+		index:      index.New(opts.Storage),
+		metrics:    opts.Metrics,
+		transport:  upstream,
+	}
+
+	b.scheduler.Handle("digest", b.sendDigest)
+	b.scheduler.Handle("course", b.sendCoursePost)
+
+	sources := []Source{
+		&lesswrongRuSource{bot: b},
+		&slateSource{bot: b},
+		newSubstackSource(b, models.SourceAstral, "Astral Codex Ten", models.DomainAstral),
+		newForumSource(b, models.SourceLesswrong, "Lesswrong.com", models.DomainLesswrong, "https://www.lesswrong.com"),
+		newForumSource(b, models.SourceEAForum, "EA Forum", models.DomainEAForum, "https://forum.effectivealtruism.org"),
+		newForumSource(b, models.SourceAlignmentForum, "Alignment Forum", models.DomainAlignmentForum, "https://www.alignmentforum.org"),
+	}
+
+	for _, substack := range opts.Config.Substacks {
+		sources = append(sources, newSubstackSource(b, models.Source(substack.Host), substack.Name, substack.Host))
+	}
+
+	for _, feed := range opts.Config.Feeds {
+		source, err := newFeedSource(b, feed.Name, feed.URL)
+		if err != nil {
+			return nil, fmt.Errorf("create feed source failed: %s", err)
+		}
+
+		sources = append(sources, source)
+	}
+
+	for _, source := range sources {
+		if err := b.sources.Register(source); err != nil {
+			return nil, fmt.Errorf("register source failed: %s", err)
+		}
+	}
+
+	if opts.Config.AutopostFile != "" {
+		if err := b.LoadAutopostFile(context.Background(), opts.Config.AutopostFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// ObserveDispatcher exports stats of dispatcher processing updates of bot as metrics.
+func (b *Bot) ObserveDispatcher(d *Dispatcher) {
+	b.metrics.ObserveDispatcher(d)
+}
+
+// RunScheduler runs scheduled jobs such as digests and courses until context is done.
+func (b *Bot) RunScheduler(ctx context.Context, interval time.Duration) {
+	b.scheduler.Run(ctx, interval)
 }
 
 func (b *Bot) GetUpdatesChan() (tgbotapi.UpdatesChannel, error) {
@@ -133,6 +238,7 @@ func (b *Bot) GetUpdatesChan() (tgbotapi.UpdatesChannel, error) {
 		}
 
 		updates := b.botAPI.ListenForWebhook("/" + b.botAPI.Token)
+		http.Handle("/metrics", b.metrics.Handler())
 
 		go func() {
 			if err := http.ListenAndServe(b.config.Address, nil); err != nil {
@@ -163,52 +269,276 @@ func (b *Bot) GetUpdatesChan() (tgbotapi.UpdatesChannel, error) {
 	return updates, nil
 }
 
-func (b *Bot) MessageHandler(ctx context.Context, update tgbotapi.Update) (tgbotapi.Message, error) {
-	if update.CallbackQuery != nil {
-		text, _, err := b.ChangeSource(ctx, update.CallbackQuery.From.ID, models.Source(update.CallbackQuery.Data))
+// readCallback sends first page of post when Read button is pressed and edits message with another page on navigation.
+func (b *Bot) readCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (tgbotapi.Message, error) {
+	key, page, ok := parseReadCallback(callback.Data)
+	if !ok {
+		return tgbotapi.Message{}, fmt.Errorf("invalid read callback data: %s", callback.Data)
+	}
+
+	text, keyboard, err := b.ReadPage(ctx, key, page)
+	if err != nil {
+		log.Printf("[ERROR] Read post failed: %s", err)
+		text = "Post not found"
+	}
+
+	// Navigation buttons have page set, so they edit reader message instead of sending a new one.
+	return b.answerCallback(callback, text, keyboard, page >= 0)
+}
+
+// searchCallback edits search results message with another page.
+func (b *Bot) searchCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (tgbotapi.Message, error) {
+	key, page, ok := parseSearchCallback(callback.Data)
+	if !ok {
+		return tgbotapi.Message{}, fmt.Errorf("invalid search callback data: %s", callback.Data)
+	}
+
+	text, keyboard, err := b.SearchPage(ctx, key, page)
+	if err != nil {
+		log.Printf("[ERROR] Search page failed: %s", err)
+		text = "Search failed"
+	}
+
+	return b.answerCallback(callback, text, keyboard, true)
+}
+
+// previewCallback sends preview of post chosen in search results.
+func (b *Bot) previewCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (tgbotapi.Message, error) {
+	text, keyboard, err := b.PreviewPost(ctx, strings.TrimPrefix(callback.Data, previewCallbackPrefix))
+	if err != nil {
+		log.Printf("[ERROR] Preview post failed: %s", err)
+		text = "Post not found"
+	}
+
+	return b.answerCallback(callback, text, keyboard, false)
+}
+
+// resetCallback resets history of source and edits message with result.
+func (b *Bot) resetCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (tgbotapi.Message, error) {
+	if !b.callbackAllowed(callback) {
+		return b.answerCallback(callback, MessageAdminsOnly, nil, false)
+	}
+
+	text, err := b.ResetHistory(ctx, callbackSettingsID(callback), models.Source(strings.TrimPrefix(callback.Data, resetCallbackPrefix)))
+	if err != nil {
+		log.Printf("[ERROR] Reset history failed: %s", err)
+		text = "Reset history failed"
+	}
+
+	return b.answerCallback(callback, text, nil, true)
+}
+
+// sequenceCallback starts sequence chosen by user and sends its first post.
+func (b *Bot) sequenceCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (tgbotapi.Message, error) {
+	sourceID, id, ok := strings.Cut(strings.TrimPrefix(callback.Data, sequenceCallbackPrefix), ":")
+	if !ok {
+		return tgbotapi.Message{}, fmt.Errorf("invalid sequence callback data: %s", callback.Data)
+	}
+
+	if !b.callbackAllowed(callback) {
+		return b.answerCallback(callback, MessageAdminsOnly, nil, false)
+	}
+
+	text, keyboard, err := b.StartSequence(ctx, callbackSettingsID(callback), models.Source(sourceID), id)
+	if err != nil {
+		log.Printf("[ERROR] Start sequence failed: %s", err)
+		text = "Sequence not found"
+	}
+
+	return b.answerCallback(callback, text, keyboard, false)
+}
+
+// saveCallback saves post to bookmarks and shows result as notification.
+func (b *Bot) saveCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (tgbotapi.Message, error) {
+	text, err := b.SaveBookmark(ctx, callback.From.ID, strings.TrimPrefix(callback.Data, saveCallbackPrefix))
+	if err != nil {
+		log.Printf("[ERROR] Save bookmark failed: %s", err)
+		text = "Save failed"
+	}
+
+	if _, err := b.botAPI.AnswerCallbackQuery(tgbotapi.NewCallback(callback.ID, text)); err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("answer callback failed: %s", err)
+	}
+
+	return tgbotapi.Message{}, nil
+}
+
+// savedCallback removes bookmark if remove button is pressed and edits bookmarks message with the page.
+func (b *Bot) savedCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (tgbotapi.Message, error) {
+	key, page, remove := parseUnsaveCallback(callback.Data)
+
+	if remove {
+		if err := b.RemoveBookmark(ctx, callback.From.ID, key); err != nil {
+			log.Printf("[ERROR] Remove bookmark failed: %s", err)
+		}
+	} else {
+		var err error
+
+		page, err = strconv.Atoi(strings.TrimPrefix(callback.Data, savedCallbackPrefix))
 		if err != nil {
-			log.Printf("[ERROR] Command /source failed: %s", err)
-			text = "Change source failed"
+			return tgbotapi.Message{}, fmt.Errorf("invalid saved callback data: %s", callback.Data)
 		}
+	}
+
+	text, keyboard, err := b.Bookmarks(ctx, callback.From.ID, page)
+	if err != nil {
+		log.Printf("[ERROR] Get bookmarks failed: %s", err)
+		text = "Saved posts not found"
+	}
+
+	return b.answerCallback(callback, text, keyboard, true)
+}
+
+// sourceCallback changes source of chat where button was pressed.
+func (b *Bot) sourceCallback(ctx context.Context, callback *tgbotapi.CallbackQuery) (tgbotapi.Message, error) {
+	if !b.callbackAllowed(callback) {
+		return b.answerCallback(callback, MessageAdminsOnly, nil, false)
+	}
+
+	text, _, err := b.ChangeSource(ctx, callbackSettingsID(callback), models.Source(callback.Data))
+	if err != nil {
+		log.Printf("[ERROR] Command /source failed: %s", err)
+		text = "Change source failed"
+	}
+
+	return b.answerCallback(callback, text, nil, false)
+}
 
-		if _, err := b.botAPI.AnswerCallbackQuery(tgbotapi.NewCallback(update.CallbackQuery.ID, "")); err != nil {
-			return tgbotapi.Message{}, fmt.Errorf("answer callback failed: %s", err)
+// answerCallback answers callback query and edits message with button or sends a new one with HTML text.
+func (b *Bot) answerCallback(callback *tgbotapi.CallbackQuery, text string, keyboard interface{}, edit bool) (tgbotapi.Message, error) {
+	if _, err := b.botAPI.AnswerCallbackQuery(tgbotapi.NewCallback(callback.ID, "")); err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("answer callback failed: %s", err)
+	}
+
+	if edit && (callback.Message != nil || callback.InlineMessageID != "") {
+		editMsg := tgbotapi.EditMessageTextConfig{BaseEdit: tgbotapi.BaseEdit{InlineMessageID: callback.InlineMessageID}, Text: text}
+		if callback.Message != nil {
+			editMsg = tgbotapi.NewEditMessageText(callback.Message.Chat.ID, callback.Message.MessageID, text)
 		}
 
-		msg := tgbotapi.NewMessage(update.CallbackQuery.Message.Chat.ID, text)
-		msg.ParseMode = tgbotapi.ModeMarkdown
-		msg.DisableWebPagePreview = true
+		editMsg.ParseMode = tgbotapi.ModeHTML
+		editMsg.DisableWebPagePreview = true
 
-		sent, err := b.botAPI.Send(msg)
+		if markup, ok := keyboard.(tgbotapi.InlineKeyboardMarkup); ok {
+			editMsg.ReplyMarkup = &markup
+		}
+
+		sent, err := b.botAPI.Send(editMsg)
 		if err != nil {
-			return tgbotapi.Message{}, fmt.Errorf("send message failed: %s. Text: \n%s", err, msg.Text)
+			return tgbotapi.Message{}, fmt.Errorf("edit message failed: %s. Text: \n%s", err, editMsg.Text)
 		}
 
 		return sent, nil
 	}
 
-	if update.Message == nil {
+	// Buttons of inline messages have no chat, so answer is sent to user.
+	chatID := int64(callback.From.ID)
+	if callback.Message != nil {
+		chatID = callback.Message.Chat.ID
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = keyboard
+
+	sent, err := b.botAPI.Send(msg)
+	if err != nil {
+		return tgbotapi.Message{}, fmt.Errorf("send message failed: %s. Text: \n%s", err, msg.Text)
+	}
+
+	return sent, nil
+}
+
+func (b *Bot) MessageHandler(ctx context.Context, update tgbotapi.Update) (tgbotapi.Message, error) {
+	if update.InlineQuery != nil {
+		return tgbotapi.Message{}, b.answerInlineQuery(ctx, update.InlineQuery)
+	}
+
+	if update.CallbackQuery != nil {
+		switch data := update.CallbackQuery.Data; {
+		case strings.HasPrefix(data, readCallbackPrefix):
+			return b.readCallback(ctx, update.CallbackQuery)
+		case strings.HasPrefix(data, searchCallbackPrefix):
+			return b.searchCallback(ctx, update.CallbackQuery)
+		case strings.HasPrefix(data, previewCallbackPrefix):
+			return b.previewCallback(ctx, update.CallbackQuery)
+		case strings.HasPrefix(data, resetCallbackPrefix):
+			return b.resetCallback(ctx, update.CallbackQuery)
+		case strings.HasPrefix(data, sequenceCallbackPrefix):
+			return b.sequenceCallback(ctx, update.CallbackQuery)
+		case strings.HasPrefix(data, saveCallbackPrefix):
+			return b.saveCallback(ctx, update.CallbackQuery)
+		case strings.HasPrefix(data, savedCallbackPrefix), strings.HasPrefix(data, unsaveCallbackPrefix):
+			return b.savedCallback(ctx, update.CallbackQuery)
+		}
+
+		return b.sourceCallback(ctx, update.CallbackQuery)
+	}
+
+	// Bot added to channel receives commands as channel posts.
+	message := update.Message
+	if message == nil {
+		message = update.ChannelPost
+	}
+
+	if message == nil {
 		return tgbotapi.Message{}, nil
 	}
 
-	if update.Message.From != nil {
-		log.Printf("[%s] %s", update.Message.From.UserName, update.Message.Text)
+	if message.From != nil {
+		log.Printf("[%s] %s", message.From.UserName, message.Text)
+	}
+
+	if message.Chat == nil {
+		return tgbotapi.Message{}, nil
 	}
 
-	if update.Message.Chat == nil {
+	// Groups have other messages and commands of other bots which must be ignored.
+	if isGroupChat(message.Chat) && (!message.IsCommand() || b.addressedToOtherBot(message)) {
 		return tgbotapi.Message{}, nil
 	}
 
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "")
-	msg.ParseMode = tgbotapi.ModeMarkdown
+	// Source, history and other settings are shared in groups, bookmarks are personal.
+	chatSettingsID := settingsID(message.Chat, message.From)
+	userID := personalID(message)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, "")
+	msg.ParseMode = tgbotapi.ModeHTML
 	msg.DisableWebPagePreview = true
 
-	switch update.Message.Command() {
+	command := message.Command()
+
+	b.metrics.ObserveCommand(command, b.userSource(ctx, chatSettingsID).Name())
+
+	if restricted, ok := adminCommands[command]; ok && (restricted || strings.TrimSpace(message.CommandArguments()) != "") {
+		allowed, err := b.canChangeSettings(message.Chat, message.From)
+		if err != nil {
+			log.Printf("[ERROR] Check chat admin failed: %s", err)
+		}
+
+		if !allowed {
+			msg.Text = MessageAdminsOnly
+
+			sent, err := b.botAPI.Send(msg)
+			if err != nil {
+				return tgbotapi.Message{}, fmt.Errorf("send message failed: %s. Text: \n%s", err, msg.Text)
+			}
+
+			return sent, nil
+		}
+	}
+
+	switch command {
 	case "start", "help":
-		msg.ReplyMarkup = mainKeyboard
-		msg.Text = MessageHelp
+		// Reply keyboard would pop up for every member of group.
+		if !isGroupChat(message.Chat) {
+			msg.ReplyMarkup = mainKeyboard
+		}
+
+		msg.Text = b.helpMessage()
 	case "top":
-		text, err := b.TopPosts(ctx, update.Message.From.ID)
+		text, err := b.TopPosts(ctx, chatSettingsID)
 		if err != nil {
 			log.Printf("[ERROR] Command /top failed: %s", err)
 			text = "Top posts not found"
@@ -216,15 +546,91 @@ func (b *Bot) MessageHandler(ctx context.Context, update tgbotapi.Update) (tgbot
 
 		msg.Text = text
 	case "random":
-		text, err := b.RandomPost(ctx, update.Message.From.ID)
+		text, keyboard, err := b.RandomPost(ctx, chatSettingsID)
 		if err != nil {
 			log.Printf("[ERROR] Command /random failed: %s", err)
 			text = "Random post not found"
 		}
 
 		msg.Text = text
+		msg.ReplyMarkup = keyboard
+		// Instant View is shown as link preview.
+		msg.DisableWebPagePreview = !b.instantViewEnabled(ctx, chatSettingsID)
+	case "instantview":
+		text, err := b.InstantView(ctx, chatSettingsID, message.CommandArguments())
+		if err != nil {
+			log.Printf("[ERROR] Command /instantview failed: %s", err)
+			text = "Change Instant View failed"
+		}
+
+		msg.Text = text
+	case "read":
+		text, keyboard, err := b.ReadPost(ctx, message.CommandArguments())
+		if err != nil {
+			log.Printf("[ERROR] Command /read failed: %s", err)
+			text = "Post not found"
+		}
+
+		msg.Text = text
+		msg.ReplyMarkup = keyboard
+	case "search":
+		text, keyboard, err := b.Search(ctx, message.CommandArguments())
+		if err != nil {
+			log.Printf("[ERROR] Command /search failed: %s", err)
+			text = "Search failed"
+		}
+
+		msg.Text = text
+		msg.ReplyMarkup = keyboard
+	case "sequence":
+		text, keyboard, err := b.Sequences(ctx, chatSettingsID)
+		if err != nil {
+			log.Printf("[ERROR] Command /sequence failed: %s", err)
+			text = "Sequences not found"
+		}
+
+		msg.Text = text
+		msg.ReplyMarkup = keyboard
+	case "next":
+		text, keyboard, err := b.NextPost(ctx, chatSettingsID)
+		if err != nil {
+			log.Printf("[ERROR] Command /next failed: %s", err)
+			text = "Next post not found"
+		}
+
+		msg.Text = text
+		msg.ReplyMarkup = keyboard
+	case "course":
+		text, err := b.Course(ctx, chatSettingsID, message.Chat.ID, message.CommandArguments())
+		if err != nil {
+			log.Printf("[ERROR] Command /course failed: %s", err)
+			text = "Change course failed"
+		}
+
+		msg.Text = text
+	case "history":
+		text, err := b.History(ctx, chatSettingsID)
+		if err != nil {
+			log.Printf("[ERROR] Command /history failed: %s", err)
+			text = "History not found"
+		}
+
+		msg.Text = text
+	case "saved":
+		if strings.TrimSpace(message.CommandArguments()) == "export" {
+			return b.sendBookmarksExport(ctx, message.Chat.ID, userID)
+		}
+
+		text, keyboard, err := b.Bookmarks(ctx, userID, 0)
+		if err != nil {
+			log.Printf("[ERROR] Command /saved failed: %s", err)
+			text = "Saved posts not found"
+		}
+
+		msg.Text = text
+		msg.ReplyMarkup = keyboard
 	case "source":
-		text, keyboard, err := b.ChangeSource(ctx, update.Message.From.ID, models.Source(update.Message.CommandArguments()))
+		text, keyboard, err := b.ChangeSource(ctx, chatSettingsID, models.Source(message.CommandArguments()))
 		if err != nil {
 			log.Printf("[ERROR] Command /source failed: %s", err)
 			text = "Change source failed"
@@ -232,6 +638,38 @@ func (b *Bot) MessageHandler(ctx context.Context, update tgbotapi.Update) (tgbot
 
 		msg.Text = text
 		msg.ReplyMarkup = keyboard
+	case "subscribe":
+		text, err := b.Subscribe(ctx, chatSettingsID, message.Chat.ID, models.Source(message.CommandArguments()))
+		if err != nil {
+			log.Printf("[ERROR] Command /subscribe failed: %s", err)
+			text = "Subscribe failed"
+		}
+
+		msg.Text = text
+	case "digest":
+		text, err := b.Digest(ctx, message.Chat.ID, message.CommandArguments())
+		if err != nil {
+			log.Printf("[ERROR] Command /digest failed: %s", err)
+			text = "Change digest failed"
+		}
+
+		msg.Text = text
+	case "autopost":
+		text, err := b.Autopost(ctx, chatSettingsID, message.Chat.ID, message.CommandArguments())
+		if err != nil {
+			log.Printf("[ERROR] Command /autopost failed: %s", err)
+			text = "Change autopost failed"
+		}
+
+		msg.Text = text
+	case "unsubscribe":
+		text, err := b.Unsubscribe(ctx, message.Chat.ID, models.Source(message.CommandArguments()))
+		if err != nil {
+			log.Printf("[ERROR] Command /unsubscribe failed: %s", err)
+			text = "Unsubscribe failed"
+		}
+
+		msg.Text = text
 	default:
 		msg.Text = "I don't know that command"
 	}
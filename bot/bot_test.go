@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// requireGolden compares text with golden file. Golden file is rewritten if test is run with -update flag.
+func requireGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	if *updateGolden {
+		require.NoError(t, os.WriteFile(name, []byte(got), 0o644))
+	}
+
+	want, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, string(want), got)
+}
+
+// telegramStub records requests to Telegram Bot API and answers them successfully.
+type telegramStub struct {
+	t        *testing.T
+	mu       sync.Mutex
+	requests []telegramRequest
+	// admins are ids of users which getChatMember answers as chat administrators.
+	admins map[string]bool
+	// failing makes requests fail as Telegram does for text it can't parse.
+	failing bool
+}
+
+type telegramRequest struct {
+	Method string
+	Params url.Values
+}
+
+func newTelegramStub(t *testing.T) (*telegramStub, *tgbotapi.BotAPI) {
+	stub := &telegramStub{t: t}
+
+	botAPI := &tgbotapi.BotAPI{
+		Token:  "token",
+		Client: &http.Client{Transport: stub},
+	}
+
+	return stub, botAPI
+}
+
+func (s *telegramStub) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") {
+		require.NoError(s.t, req.ParseMultipartForm(1<<20))
+	} else {
+		require.NoError(s.t, req.ParseForm())
+	}
+
+	request := telegramRequest{
+		Method: path.Base(req.URL.Path),
+		Params: req.PostForm,
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, request)
+	messageID := len(s.requests)
+	failing := s.failing
+	s.mu.Unlock()
+
+	if failing {
+		return &http.Response{
+			StatusCode: http.StatusBadRequest,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewBufferString(`{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`)),
+		}, nil
+	}
+
+	result := json.RawMessage("true")
+
+	if request.Method == "sendMessage" || request.Method == "editMessageText" || request.Method == "sendDocument" {
+		message, err := json.Marshal(map[string]interface{}{
+			"message_id": messageID,
+			"chat":       map[string]interface{}{"id": json.Number(request.Params.Get("chat_id"))},
+			"text":       request.Params.Get("text"),
+		})
+		require.NoError(s.t, err)
+
+		result = message
+	}
+
+	if request.Method == "getChatMember" {
+		status := "member"
+		if s.admins[request.Params.Get("user_id")] {
+			status = "administrator"
+		}
+
+		member, err := json.Marshal(map[string]interface{}{"status": status})
+		require.NoError(s.t, err)
+
+		result = member
+	}
+
+	body, err := json.Marshal(tgbotapi.APIResponse{Ok: true, Result: result})
+	require.NoError(s.t, err)
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBuffer(body)),
+	}, nil
+}
+
+// Sent returns params of requests with given method.
+func (s *telegramStub) Sent(method string) []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sent []url.Values
+
+	for _, request := range s.requests {
+		if request.Method == method {
+			sent = append(sent, request.Params)
+		}
+	}
+
+	return sent
+}
+
+// Fail makes the following requests fail or succeed again.
+func (s *telegramStub) Fail(failing bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failing = failing
+}
+
+func (s *telegramStub) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+}
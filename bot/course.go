@@ -0,0 +1,389 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+const MessageCourseUsage = `Usage: /course name [HH:MM [timezone]]
+
+Course sends one post of a sequence from current /source every day. Default time is 09:00 UTC.
+
+Examples:
+
+/course rationality-az 08:00 Europe/Moscow
+
+/course pause
+
+/course resume
+
+/course skip
+
+/course stop`
+
+// course is a sequence delivered to chat one post per day.
+type course struct {
+	Source models.Source `json:"source"`
+	ID     string        `json:"id"`
+	Title  string        `json:"title"`
+	// UserID is a user who started course, posts are added to the user history.
+	UserID int `json:"user_id"`
+	// Position is an index of the next post to send.
+	Position int      `json:"position"`
+	Total    int      `json:"total"`
+	Schedule Schedule `json:"schedule"`
+	Paused   bool     `json:"paused"`
+}
+
+func courseJobID(chatID int64) string {
+	return fmt.Sprintf("course:%d", chatID)
+}
+
+// Course starts, pauses, resumes, skips post of or stops course in chat. Without arguments returns course progress.
+func (b *Bot) Course(ctx context.Context, userID int, chatID int64, args string) (string, error) {
+	fields := strings.Fields(args)
+
+	if len(fields) == 0 {
+		return b.courseStatus(ctx, chatID)
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case "pause":
+		return b.pauseCourse(ctx, chatID)
+	case "resume":
+		return b.resumeCourse(ctx, chatID)
+	case "skip":
+		return b.skipCoursePost(ctx, chatID)
+	case "stop":
+		return b.stopCourse(ctx, chatID)
+	}
+
+	return b.startCourse(ctx, userID, chatID, fields[0], strings.Join(fields[1:], " "))
+}
+
+func (b *Bot) startCourse(ctx context.Context, userID int, chatID int64, name, at string) (string, error) {
+	source := b.userSource(ctx, userID)
+
+	sequential, ok := source.(Sequential)
+	if !ok {
+		return "Courses are not supported for " + renderer.Escape(sourceURL(source)), nil
+	}
+
+	if at == "" {
+		at = "09:00"
+	}
+
+	schedule, err := ParseSchedule(PeriodDaily + " " + at)
+	if err != nil {
+		return fmt.Sprintf("Invalid time: %s\n\n%s", renderer.Escape(err.Error()), MessageCourseUsage), nil
+	}
+
+	sequences, err := b.sequences(ctx, source.ID(), sequential)
+	if err != nil {
+		return "", err
+	}
+
+	var found *models.Sequence
+
+	for i, sequence := range sequences {
+		if sequence.ID == name || courseName(sequence.Title) == strings.ToLower(name) {
+			found = &sequences[i]
+			break
+		}
+	}
+
+	if found == nil {
+		text := bytes.NewBufferString(fmt.Sprintf("Course %s not found. Courses from %s:\n\n", renderer.Escape(name), renderer.Escape(sourceURL(source))))
+
+		for _, sequence := range sequences {
+			text.WriteString(fmt.Sprintf("%s - %s\n", courseName(sequence.Title), renderer.Escape(sequence.Title)))
+		}
+
+		return strings.TrimSuffix(text.String(), "\n"), nil
+	}
+
+	sequence, err := b.sequence(ctx, source.ID(), found.ID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sequence.Posts) == 0 {
+		return fmt.Sprintf("Course %s has no posts", renderer.Escape(sequence.Title)), nil
+	}
+
+	c := course{
+		Source:   source.ID(),
+		ID:       sequence.ID,
+		Title:    sequence.Title,
+		UserID:   userID,
+		Total:    len(sequence.Posts),
+		Schedule: schedule,
+	}
+
+	if err := b.setCourse(ctx, chatID, c); err != nil {
+		return "", err
+	}
+
+	if err := b.scheduler.Set(ctx, courseJobID(chatID), schedule); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("🎓 Course <b>%s</b> started: %d posts, one post %s", renderer.Escape(c.Title), c.Total, schedule), nil
+}
+
+func (b *Bot) courseStatus(ctx context.Context, chatID int64) (string, error) {
+	c, ok, err := b.course(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "No course is started\n\n" + MessageCourseUsage, nil
+	}
+
+	text := fmt.Sprintf("🎓 <b>%s</b>\n\n%s", renderer.Escape(c.Title), progressBar(c.Position, c.Total))
+
+	if c.Paused {
+		return text + "\n\nCourse is paused. Send /course resume to continue", nil
+	}
+
+	return text + "\n\nNext post is sent " + c.Schedule.String(), nil
+}
+
+func (b *Bot) pauseCourse(ctx context.Context, chatID int64) (string, error) {
+	c, ok, err := b.course(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "No course is started", nil
+	}
+
+	if err := b.scheduler.Remove(ctx, courseJobID(chatID)); err != nil {
+		return "", err
+	}
+
+	c.Paused = true
+
+	if err := b.setCourse(ctx, chatID, c); err != nil {
+		return "", err
+	}
+
+	return "Course is paused. Send /course resume to continue", nil
+}
+
+func (b *Bot) resumeCourse(ctx context.Context, chatID int64) (string, error) {
+	c, ok, err := b.course(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "No course is started", nil
+	}
+
+	if err := b.scheduler.Set(ctx, courseJobID(chatID), c.Schedule); err != nil {
+		return "", err
+	}
+
+	c.Paused = false
+
+	if err := b.setCourse(ctx, chatID, c); err != nil {
+		return "", err
+	}
+
+	return "Course is resumed. Next post is sent " + c.Schedule.String(), nil
+}
+
+func (b *Bot) skipCoursePost(ctx context.Context, chatID int64) (string, error) {
+	c, ok, err := b.course(ctx, chatID)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "No course is started", nil
+	}
+
+	sequence, err := b.sequence(ctx, c.Source, c.ID)
+	if err != nil {
+		return "", err
+	}
+
+	c.Position++
+
+	if c.Position >= len(sequence.Posts) {
+		if _, err := b.stopCourse(ctx, chatID); err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("🎉 Course <b>%s</b> is finished", renderer.Escape(c.Title)), nil
+	}
+
+	if err := b.setCourse(ctx, chatID, c); err != nil {
+		return "", err
+	}
+
+	next := sequence.Posts[c.Position]
+
+	return fmt.Sprintf("Post is skipped. Next post: %s\n\n%s", renderer.Link(next.URL, next.Title), progressBar(c.Position, c.Total)), nil
+}
+
+func (b *Bot) stopCourse(ctx context.Context, chatID int64) (string, error) {
+	if err := b.scheduler.Remove(ctx, courseJobID(chatID)); err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("course:%d", chatID)
+
+	if err := b.storage.Del(ctx, key); err != nil {
+		return "", fmt.Errorf("delete course failed: %s, key: %s", err, key)
+	}
+
+	return "Course is stopped", nil
+}
+
+// sendCoursePost is a scheduler job which sends the next course post.
+// Position is saved before post is sent, so restart doesn't send the same post twice.
+func (b *Bot) sendCoursePost(ctx context.Context, id string) error {
+	_, chat, _ := strings.Cut(id, ":")
+
+	chatID, err := strconv.ParseInt(chat, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse course chat id failed: %s", err)
+	}
+
+	c, ok, err := b.course(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	if !ok || c.Paused {
+		return nil
+	}
+
+	sequence, err := b.sequence(ctx, c.Source, c.ID)
+	if err != nil {
+		return err
+	}
+
+	if c.Position >= len(sequence.Posts) {
+		_, err := b.stopCourse(ctx, chatID)
+		return err
+	}
+
+	post := sequence.Posts[c.Position]
+
+	preview, keyboard, err := b.sequencePostPreview(ctx, c.UserID, c.Source, post)
+	if err != nil {
+		return err
+	}
+
+	c.Position++
+
+	if c.Position >= len(sequence.Posts) {
+		_, err = b.stopCourse(ctx, chatID)
+	} else {
+		err = b.setCourse(ctx, chatID, c)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("🎓 %s, day %d/%d\n%s\n\n%s", renderer.Escape(c.Title), c.Position, len(sequence.Posts), progressBar(c.Position, len(sequence.Posts)), preview)
+
+	if c.Position >= len(sequence.Posts) {
+		text += "\n\n🎉 Course is finished. Start another one with /course"
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = keyboard
+
+	if _, err := b.botAPI.Send(msg); err != nil {
+		return fmt.Errorf("send course post failed: %s, chat: %d, url: %s", err, chatID, post.URL)
+	}
+
+	return nil
+}
+
+func (b *Bot) course(ctx context.Context, chatID int64) (course, bool, error) {
+	key := fmt.Sprintf("course:%d", chatID)
+
+	courseCached, err := b.storage.Get(ctx, key)
+	if err != nil {
+		return course{}, false, fmt.Errorf("get course failed: %s, key: %s", err, key)
+	}
+
+	if courseCached == "" {
+		return course{}, false, nil
+	}
+
+	var c course
+
+	if err := json.Unmarshal([]byte(courseCached), &c); err != nil {
+		return course{}, false, fmt.Errorf("unmarshal course failed: %s, key: %s", err, key)
+	}
+
+	return c, true, nil
+}
+
+func (b *Bot) setCourse(ctx context.Context, chatID int64, c course) error {
+	key := fmt.Sprintf("course:%d", chatID)
+
+	courseCache, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal course failed: %s, key: %s", err, key)
+	}
+
+	if err := b.storage.Set(ctx, key, string(courseCache), 0); err != nil {
+		return fmt.Errorf("set course failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// courseName returns course name made of sequence title words, e.g. "Rationality: A-Z" becomes "rationality-az".
+func courseName(title string) string {
+	var words []string
+
+	for _, field := range strings.Fields(title) {
+		word := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+
+			return -1
+		}, field)
+
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return strings.Join(words, "-")
+}
+
+// progressBar returns progress as bar of 10 cells with counter, e.g. "▓▓▓░░░░░░░ 3/10".
+func progressBar(done, total int) string {
+	if total <= 0 {
+		return ""
+	}
+
+	filled := done * 10 / total
+
+	return strings.Repeat("▓", filled) + strings.Repeat("░", 10-filled) + fmt.Sprintf(" %d/%d", done, total)
+}
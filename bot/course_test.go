@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestCourse(t *testing.T) {
+	const (
+		userID = 1
+		chatID = 1
+	)
+
+	httpClient := &mocks.HTTPClient{}
+
+	mockQuery := func(body string) {
+		httpClient.On("Post", context.TODO(), "https://www.lesswrong.com/graphql", "application/json", mock.Anything).Return(
+			&http.Response{Body: io.NopCloser(bytes.NewBufferString(body))},
+			nil,
+		).Once()
+	}
+
+	mockQuery(`{"data":{"sequences":{"results":[{"_id":"seq1","title":"Rationality: A-Z"}]}}}`)
+	mockQuery(`{"data":{"sequence":{"result":{"_id":"seq1","title":"Rationality: A-Z","chapters":[{"posts":[
+		{"title":"Post 1","pageUrl":"https://www.lesswrong.com/posts/p1/post-1"},
+		{"title":"Post 2","pageUrl":"https://www.lesswrong.com/posts/p2/post-2"},
+		{"title":"Post 3","pageUrl":"https://www.lesswrong.com/posts/p3/post-3"}
+	]}]}}}}`)
+	mockQuery(`{"data":{"post":{"result":{"title":"Post 1","pageUrl":"https://www.lesswrong.com/posts/p1/post-1","htmlBody":"<p>First.</p>"}}}}`)
+	mockQuery(`{"data":{"post":{"result":{"title":"Post 3","pageUrl":"https://www.lesswrong.com/posts/p3/post-3","htmlBody":"<p>Third.</p>"}}}}`)
+
+	now := time.Date(2021, 5, 1, 7, 0, 0, 0, time.UTC)
+	telegram, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{
+		BotAPI:     botAPI,
+		HTTPClient: httpClient,
+		Now:        func() time.Time { return now },
+	})
+	require.NoError(t, err)
+
+	_, _, err = tgbot.ChangeSource(context.TODO(), userID, models.SourceLesswrong)
+	require.NoError(t, err)
+
+	course := func(args string) string {
+		text, err := tgbot.Course(context.TODO(), userID, chatID, args)
+		require.NoError(t, err)
+
+		return text
+	}
+
+	runAt := func(at time.Time) []string {
+		sent := len(telegram.Sent("sendMessage"))
+		now = at
+		require.NoError(t, tgbot.scheduler.RunDue(context.TODO()))
+
+		var texts []string
+		for _, message := range telegram.Sent("sendMessage")[sent:] {
+			texts = append(texts, message.Get("text"))
+		}
+
+		return texts
+	}
+
+	require.Equal(t, "No course is started\n\n"+MessageCourseUsage, course(""))
+	require.Equal(t, "Invalid time: invalid hour: 25\n\n"+MessageCourseUsage, course("rationality-az 25:00"))
+	require.Equal(t, "Course unknown not found. Courses from https://lesswrong.com:\n\nrationality-az - Rationality: A-Z", course("unknown"))
+	require.Equal(t, "🎓 Course <b>Rationality: A-Z</b> started: 3 posts, one post daily at 08:00 UTC", course("rationality-az 08:00"))
+	require.Equal(t, "🎓 <b>Rationality: A-Z</b>\n\n░░░░░░░░░░ 0/3\n\nNext post is sent daily at 08:00 UTC", course(""))
+
+	require.Empty(t, runAt(time.Date(2021, 5, 1, 7, 59, 0, 0, time.UTC)))
+
+	texts := runAt(time.Date(2021, 5, 1, 8, 0, 0, 0, time.UTC))
+	require.Len(t, texts, 1)
+	require.True(t, strings.HasPrefix(texts[0], "🎓 Rationality: A-Z, day 1/3\n▓▓▓░░░░░░░ 1/3\n\n📝 <a href=\"https://www.lesswrong.com/posts/p1/post-1\">Post 1</a>"), texts[0])
+
+	require.Equal(t, "Course is paused. Send /course resume to continue", course("pause"))
+	require.Empty(t, runAt(time.Date(2021, 5, 2, 8, 0, 0, 0, time.UTC)))
+	require.Equal(t, "🎓 <b>Rationality: A-Z</b>\n\n▓▓▓░░░░░░░ 1/3\n\nCourse is paused. Send /course resume to continue", course(""))
+
+	require.Equal(t, "Course is resumed. Next post is sent daily at 08:00 UTC", course("resume"))
+	require.Equal(t, "Post is skipped. Next post: <a href=\"https://www.lesswrong.com/posts/p3/post-3\">Post 3</a>\n\n▓▓▓▓▓▓░░░░ 2/3", course("skip"))
+
+	texts = runAt(time.Date(2021, 5, 3, 8, 0, 0, 0, time.UTC))
+	require.Len(t, texts, 1)
+	require.True(t, strings.HasPrefix(texts[0], "🎓 Rationality: A-Z, day 3/3\n▓▓▓▓▓▓▓▓▓▓ 3/3\n\n📝 <a href=\"https://www.lesswrong.com/posts/p3/post-3\">Post 3</a>"), texts[0])
+	require.True(t, strings.HasSuffix(texts[0], "🎉 Course is finished. Start another one with /course"), texts[0])
+
+	// Finished course isn't scheduled anymore.
+	require.Empty(t, runAt(time.Date(2021, 5, 4, 8, 0, 0, 0, time.UTC)))
+	require.Equal(t, "No course is started\n\n"+MessageCourseUsage, course(""))
+
+	history, err := tgbot.History(context.TODO(), userID)
+	require.NoError(t, err)
+	require.Contains(t, history, "🕘 Last 2 read posts:")
+
+	httpClient.AssertExpectations(t)
+}
+
+func TestCourseName(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{title: "Rationality: A-Z", want: "rationality-az"},
+		{title: "  The Codex  ", want: "the-codex"},
+		{title: "Рациональность: от ИИ до зомби", want: "рациональность-от-ии-до-зомби"},
+		{title: "?!", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			require.Equal(t, tt.want, courseName(tt.title))
+		})
+	}
+}
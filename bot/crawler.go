@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ndrewnee/lesswrong-bot/index"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+// crawlFailuresKey is a key of hash with failed fetches of posts by url.
+const crawlFailuresKey = "crawl:failures"
+
+const (
+	// crawlRetryDelay is delay before the first retry of failed post, it's doubled after each failure.
+	crawlRetryDelay = time.Hour
+	// crawlMaxFailures is number of failures after which post isn't fetched for index anymore.
+	crawlMaxFailures = 5
+)
+
+// crawlFailure is a failed fetch of post for index.
+type crawlFailure struct {
+	Failures int       `json:"failures"`
+	RetryAt  time.Time `json:"retry_at"`
+}
+
+// RunCrawler indexes post bodies for full-text search in batches until context is done.
+func (b *Bot) RunCrawler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		crawlCtx, cancel := context.WithTimeout(ctx, interval)
+
+		indexed := b.Crawl(crawlCtx, models.CrawlBatchSize)
+		if indexed > 0 {
+			log.Printf("Indexed %d posts", indexed)
+		}
+
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Crawl walks catalogs of sources without own search and indexes bodies of posts which aren't indexed yet.
+// At most limit posts are fetched. Failed posts are fetched again after backoff when new posts are fetched
+// and aren't fetched anymore after crawlMaxFailures. Returns number of indexed posts.
+func (b *Bot) Crawl(ctx context.Context, limit int) int {
+	failures, err := b.crawlFailures(ctx)
+	if err != nil {
+		log.Printf("[ERROR] Get crawl failures failed: %s", err)
+		return 0
+	}
+
+	type crawlPost struct {
+		source Source
+		post   models.Post
+	}
+
+	var (
+		fetched, indexed int
+		retries          []crawlPost
+	)
+
+	// crawl fetches post and returns false if crawl must be stopped.
+	crawl := func(source Source, post models.Post) bool {
+		fetched++
+
+		full, err := source.Post(ctx, post.URL)
+		if err != nil {
+			log.Printf("[ERROR] Get %s post for index failed: %s", source.Domain(), err)
+
+			if err := b.addCrawlFailure(ctx, post.URL, failures[post.URL]); err != nil {
+				log.Printf("[ERROR] Add crawl failure failed: %s", err)
+			}
+
+			return true
+		}
+
+		doc := index.Document{
+			URL:    post.URL,
+			Title:  post.Title,
+			Source: source.ID().Value(),
+			Text:   plainText(full.HTML),
+		}
+
+		if err := b.index.Add(ctx, doc); err != nil {
+			log.Printf("[ERROR] Index %s post failed: %s", source.Domain(), err)
+			return false
+		}
+
+		indexed++
+
+		if _, ok := failures[post.URL]; ok {
+			if err := b.storage.HDel(ctx, crawlFailuresKey, post.URL); err != nil {
+				log.Printf("[ERROR] Delete crawl failure failed: %s", err)
+			}
+		}
+
+		return true
+	}
+
+	for _, source := range b.sources.All() {
+		if _, ok := source.(Searchable); ok {
+			continue
+		}
+
+		posts, err := source.List(ctx)
+		if err != nil {
+			log.Printf("[ERROR] Get %s posts for index failed: %s", source.Domain(), err)
+			continue
+		}
+
+		for _, post := range posts {
+			if fetched >= limit {
+				return indexed
+			}
+
+			has, err := b.index.Has(ctx, post.URL)
+			if err != nil {
+				log.Printf("[ERROR] Check post in index failed: %s", err)
+				return indexed
+			}
+
+			if has {
+				continue
+			}
+
+			// Failed posts are retried after new ones, so they don't block crawl.
+			if failure, ok := failures[post.URL]; ok {
+				if failure.Failures < crawlMaxFailures && !b.now().Before(failure.RetryAt) {
+					retries = append(retries, crawlPost{source: source, post: post})
+				}
+
+				continue
+			}
+
+			if !crawl(source, post) {
+				return indexed
+			}
+		}
+	}
+
+	for _, retry := range retries {
+		if fetched >= limit || !crawl(retry.source, retry.post) {
+			break
+		}
+	}
+
+	return indexed
+}
+
+func (b *Bot) crawlFailures(ctx context.Context) (map[string]crawlFailure, error) {
+	failuresCached, err := b.storage.HGetAll(ctx, crawlFailuresKey)
+	if err != nil {
+		return nil, fmt.Errorf("get crawl failures failed: %s, key: %s", err, crawlFailuresKey)
+	}
+
+	failures := make(map[string]crawlFailure, len(failuresCached))
+
+	for url, failureCached := range failuresCached {
+		var failure crawlFailure
+
+		if err := json.Unmarshal([]byte(failureCached), &failure); err != nil {
+			return nil, fmt.Errorf("unmarshal crawl failure failed: %s, url: %s", err, url)
+		}
+
+		failures[url] = failure
+	}
+
+	return failures, nil
+}
+
+// addCrawlFailure counts failed fetch of post and doubles delay before its next retry.
+func (b *Bot) addCrawlFailure(ctx context.Context, url string, failure crawlFailure) error {
+	failure.Failures++
+	failure.RetryAt = b.now().Add(crawlRetryDelay << (failure.Failures - 1))
+
+	failureCache, err := json.Marshal(failure)
+	if err != nil {
+		return fmt.Errorf("marshal crawl failure failed: %s, url: %s", err, url)
+	}
+
+	if err := b.storage.HSet(ctx, crawlFailuresKey, url, string(failureCache)); err != nil {
+		return fmt.Errorf("set crawl failure failed: %s, url: %s", err, url)
+	}
+
+	return nil
+}
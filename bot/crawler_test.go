@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestCrawl(t *testing.T) {
+	broken := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/broken":
+			broken++
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/w/moloch":
+			fmt.Fprint(w, `<html><body><h1>Menu</h1><div class="tex2jax"><p>Размышления о Молохе.</p></div></body></html>`)
+		case "/2014/07/30/meditations-on-moloch/":
+			fmt.Fprint(w, `<html><body><div class="pjgm-postcontent"><p>What sphinx of cement and aluminum bashed open their skulls?</p></div></body></html>`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	astralPost, err := json.Marshal(models.AstralPost{
+		Slug:         "moloch",
+		Title:        "Moloch Again",
+		CanonicalURL: "https://astralcodexten.substack.com/p/moloch",
+		BodyHTML:     "<p>The sphinx returns.</p>",
+	})
+	require.NoError(t, err)
+
+	httpClient := &mocks.HTTPClient{}
+
+	// Indexed posts aren't fetched again.
+	httpClient.On("Get", context.TODO(), "https://astralcodexten.substack.com/api/v1/posts/moloch").Return(
+		&http.Response{Body: io.NopCloser(bytes.NewBuffer(astralPost))},
+		nil,
+	).Once()
+
+	httpClient.On("Post", context.TODO(), mock.Anything, "application/json", mock.Anything).Return(
+		func(context.Context, string, string, io.Reader) *http.Response {
+			return &http.Response{Body: io.NopCloser(bytes.NewBufferString(`{"data":{"posts":{"results":[]}}}`))}
+		},
+		nil,
+	)
+
+	now := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}, HTTPClient: httpClient, Now: func() time.Time { return now }})
+	require.NoError(t, err)
+
+	setPosts := func(name string, posts []models.Post) {
+		postsCache, err := json.Marshal(posts)
+		require.NoError(t, err)
+		require.NoError(t, tgbot.storage.Set(context.TODO(), "posts:"+name, string(postsCache), 0))
+	}
+
+	setPosts("lesswrong.ru", []models.Post{
+		{Title: "Размышления о Молохе", URL: server.URL + "/w/moloch"},
+	})
+	setPosts("slatestarcodex", []models.Post{
+		{Title: "Meditations on Moloch", URL: server.URL + "/2014/07/30/meditations-on-moloch/"},
+		{Title: "Broken Post", URL: server.URL + "/broken"},
+	})
+	setPosts("astralcodexten", []models.Post{
+		{Title: "Moloch Again", URL: "https://astralcodexten.substack.com/p/moloch", Slug: "moloch"},
+	})
+
+	require.Equal(t, 1, tgbot.Crawl(context.TODO(), 1))
+	// Failed post is counted in limit.
+	require.Equal(t, 1, tgbot.Crawl(context.TODO(), 2))
+	require.Equal(t, 1, tgbot.Crawl(context.TODO(), 10))
+	require.Equal(t, 0, tgbot.Crawl(context.TODO(), 10))
+	require.Equal(t, 1, broken)
+
+	// Failed post is retried with doubled delay until it fails crawlMaxFailures times.
+	for failures := 1; failures < crawlMaxFailures; failures++ {
+		now = now.Add(crawlRetryDelay<<(failures-1) - time.Minute)
+		require.Equal(t, 0, tgbot.Crawl(context.TODO(), 10))
+		require.Equal(t, failures, broken)
+
+		now = now.Add(time.Minute)
+		require.Equal(t, 0, tgbot.Crawl(context.TODO(), 10))
+		require.Equal(t, failures+1, broken)
+	}
+
+	now = now.Add(30 * 24 * time.Hour)
+	require.Equal(t, 0, tgbot.Crawl(context.TODO(), 10))
+	require.Equal(t, crawlMaxFailures, broken)
+
+	for _, url := range []string{server.URL + "/w/moloch", server.URL + "/2014/07/30/meditations-on-moloch/", "https://astralcodexten.substack.com/p/moloch"} {
+		has, err := tgbot.index.Has(context.TODO(), url)
+		require.NoError(t, err)
+		require.True(t, has, url)
+	}
+
+	results := tgbot.search(context.TODO(), "sphinx")
+	require.Equal(t, []searchResult{
+		{
+			Title:   "Moloch Again",
+			URL:     "https://astralcodexten.substack.com/p/moloch",
+			Source:  "Astral Codex Ten",
+			Snippet: "The <b>sphinx</b> returns.",
+		},
+		{
+			Title:   "Meditations on Moloch",
+			URL:     server.URL + "/2014/07/30/meditations-on-moloch/",
+			Source:  "Slate Star Codex",
+			Snippet: "What <b>sphinx</b> of cement and aluminum bashed open their skulls?",
+		},
+	}, results)
+
+	text, _, err := tgbot.Search(context.TODO(), `"sphinx returns"`)
+	require.NoError(t, err)
+	require.Equal(t, `🔍 Found 1 posts for <b>"sphinx returns"</b>:
+
+1. <a href="https://astralcodexten.substack.com/p/moloch">Moloch Again</a> (Astral Codex Ten)
+The <b>sphinx</b> <b>returns</b>.
+
+Page 1/1`, text)
+
+	httpClient.AssertExpectations(t)
+}
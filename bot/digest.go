@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+const MessageDigestUsage = `Usage: /digest daily|weekly [weekday] HH:MM [timezone]
+
+Examples:
+
+/digest daily 08:00 Europe/Moscow
+
+/digest weekly sat 10:30 UTC
+
+/digest off`
+
+// digestItem is a new post waiting for digest delivery.
+type digestItem struct {
+	Source string `json:"source"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+}
+
+func digestJobID(chatID int64) string {
+	return fmt.Sprintf("digest:%d", chatID)
+}
+
+func digestQueueKey(chatID int64) string {
+	return fmt.Sprintf("digest:queue:%d", chatID)
+}
+
+func (b *Bot) Digest(ctx context.Context, chatID int64, args string) (string, error) {
+	jobID := digestJobID(chatID)
+
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		schedule, ok, err := b.scheduler.Get(ctx, jobID)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return "Digest is off\n\n" + renderer.Escape(MessageDigestUsage), nil
+		}
+
+		return "Digest is delivered " + renderer.Escape(schedule.String()), nil
+	case "off":
+		if err := b.scheduler.Remove(ctx, jobID); err != nil {
+			return "", err
+		}
+
+		// Queued posts would be sent in the first digest if it's turned on again.
+		key := digestQueueKey(chatID)
+
+		if err := b.storage.Del(ctx, key); err != nil {
+			return "", fmt.Errorf("delete digest posts failed: %s, key: %s", err, key)
+		}
+
+		return "Digest is off. New posts will be sent immediately", nil
+	}
+
+	schedule, err := ParseSchedule(args)
+	if err != nil {
+		return renderer.Escape(fmt.Sprintf("Invalid schedule: %s\n\n%s", err, MessageDigestUsage)), nil
+	}
+
+	if err := b.scheduler.Set(ctx, jobID, schedule); err != nil {
+		return "", err
+	}
+
+	return "Digest is scheduled " + renderer.Escape(schedule.String()) + ". New posts from your /subscribe sources will be bundled into it", nil
+}
+
+// deliverNewPost sends post immediately or queues it if chat has digest scheduled.
+func (b *Bot) deliverNewPost(ctx context.Context, chatID int64, source Source, post models.Post) error {
+	_, digest, err := b.scheduler.Get(ctx, digestJobID(chatID))
+	if err != nil {
+		return err
+	}
+
+	if !digest {
+		return b.sendNewPost(ctx, chatID, source, post)
+	}
+
+	item, err := json.Marshal(digestItem{Source: sourceURL(source), Title: post.Title, URL: post.URL})
+	if err != nil {
+		return fmt.Errorf("marshal digest post failed: %s, url: %s", err, post.URL)
+	}
+
+	key := digestQueueKey(chatID)
+
+	if err := b.storage.RPush(ctx, key, string(item)); err != nil {
+		return fmt.Errorf("queue digest post failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// sendDigest is a scheduler job which sends queued posts as one or several messages.
+// Sent posts are removed from queue only after all parts are sent, posts queued meanwhile are kept for the next digest.
+func (b *Bot) sendDigest(ctx context.Context, id string) error {
+	_, chat, _ := strings.Cut(id, ":")
+
+	chatID, err := strconv.ParseInt(chat, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse digest chat id failed: %s", err)
+	}
+
+	key := digestQueueKey(chatID)
+
+	queued, err := b.storage.LRange(ctx, key, 0, -1)
+	if err != nil {
+		return fmt.Errorf("get digest posts failed: %s, key: %s", err, key)
+	}
+
+	if len(queued) == 0 {
+		return nil
+	}
+
+	items := make([]digestItem, 0, len(queued))
+
+	for _, value := range queued {
+		var item digestItem
+		if err := json.Unmarshal([]byte(value), &item); err != nil {
+			return fmt.Errorf("unmarshal digest post failed: %s, key: %s", err, key)
+		}
+
+		items = append(items, item)
+	}
+
+	for _, text := range renderer.Split(digestText(items), models.MessageMaxLength) {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.DisableWebPagePreview = true
+
+		if _, err := b.botAPI.Send(msg); err != nil {
+			return fmt.Errorf("send digest failed: %s, chat: %d", err, chatID)
+		}
+	}
+
+	// Posts are only appended to the end of queue, so trimming sent ones keeps posts queued during sending.
+	if err := b.storage.LTrim(ctx, key, int64(len(queued)), -1); err != nil {
+		return fmt.Errorf("trim digest posts failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// digestText groups posts by source keeping order of their arrival.
+func digestText(items []digestItem) string {
+	var sources []string
+
+	bySource := make(map[string][]digestItem)
+
+	for _, item := range items {
+		if _, ok := bySource[item.Source]; !ok {
+			sources = append(sources, item.Source)
+		}
+
+		bySource[item.Source] = append(bySource[item.Source], item)
+	}
+
+	text := bytes.NewBufferString("📰 Digest of new posts\n\n")
+
+	for _, source := range sources {
+		text.WriteString(fmt.Sprintf("%s\n\n", renderer.Escape(source)))
+
+		for i, item := range bySource[source] {
+			text.WriteString(fmt.Sprintf("%d. %s\n\n", i+1, renderer.Link(item.URL, item.Title)))
+		}
+	}
+
+	return strings.TrimSpace(text.String())
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+func TestDigest(t *testing.T) {
+	const chatID = 10
+
+	file, err := os.ReadFile("testdata/astral_new_posts.json")
+	require.NoError(t, err)
+
+	var archive []models.AstralPost
+	require.NoError(t, json.Unmarshal(file, &archive))
+
+	latest := archive
+
+	httpClient := &mocks.HTTPClient{}
+
+	httpClient.On("Get", context.TODO(), "https://astralcodexten.substack.com/api/v1/archive?sort=new&limit=12&offset=0").Return(
+		func(context.Context, string) *http.Response {
+			body, err := json.Marshal(latest)
+			require.NoError(t, err)
+
+			return &http.Response{Body: io.NopCloser(bytes.NewBuffer(body))}
+		},
+		nil,
+	)
+
+	now := time.Date(2021, 5, 1, 4, 0, 0, 0, time.UTC)
+	telegram, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{
+		BotAPI:     botAPI,
+		HTTPClient: httpClient,
+		Storage:    memory.NewStorage(),
+		Now:        func() time.Time { return now },
+	})
+	require.NoError(t, err)
+
+	text, err := tgbot.Digest(context.TODO(), chatID, "")
+	require.NoError(t, err)
+	require.Equal(t, "Digest is off\n\n"+MessageDigestUsage, text)
+
+	text, err = tgbot.Digest(context.TODO(), chatID, "hourly")
+	require.NoError(t, err)
+	require.Equal(t, "Invalid schedule: unknown period: hourly\n\n"+MessageDigestUsage, text)
+
+	text, err = tgbot.Digest(context.TODO(), chatID, "weekly sat 10:30 America/New_York")
+	require.NoError(t, err)
+	require.Equal(t, "Digest is scheduled weekly on Saturday at 10:30 America/New_York. New posts from your /subscribe sources will be bundled into it", text)
+
+	text, err = tgbot.Digest(context.TODO(), chatID, "daily 08:00 Europe/Moscow")
+	require.NoError(t, err)
+	require.Equal(t, "Digest is scheduled daily at 08:00 Europe/Moscow. New posts from your /subscribe sources will be bundled into it", text)
+
+	text, err = tgbot.Digest(context.TODO(), chatID, "")
+	require.NoError(t, err)
+	require.Equal(t, "Digest is delivered daily at 08:00 Europe/Moscow", text)
+
+	_, err = tgbot.Subscribe(context.TODO(), chatID, chatID, models.SourceAstral)
+	require.NoError(t, err)
+
+	require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+
+	latest = append([]models.AstralPost{
+		{Title: "Still_Alive <3", CanonicalURL: "https://astralcodexten.substack.com/p/still-alive", Audience: "everyone"},
+		{Title: "Ontology Of Psychiatric Conditions", CanonicalURL: "https://astralcodexten.substack.com/p/ontology", Audience: "everyone"},
+	}, archive...)
+
+	require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+	require.Empty(t, telegram.Sent("sendMessage"), "new posts should wait for digest")
+
+	require.NoError(t, tgbot.scheduler.RunDue(context.TODO()))
+	require.Empty(t, telegram.Sent("sendMessage"), "digest time hasn't come yet")
+
+	// 08:00 in Moscow.
+	now = time.Date(2021, 5, 1, 5, 0, 0, 0, time.UTC)
+
+	// Posts are kept in queue if digest isn't sent.
+	telegram.Fail(true)
+	require.Error(t, tgbot.scheduler.RunDue(context.TODO()))
+	telegram.Fail(false)
+	telegram.Reset()
+
+	require.NoError(t, tgbot.sendDigest(context.TODO(), digestJobID(chatID)))
+
+	sent := telegram.Sent("sendMessage")
+	require.Len(t, sent, 1)
+	require.Equal(t, fmt.Sprint(chatID), sent[0].Get("chat_id"))
+	require.Equal(t, `📰 Digest of new posts
+
+https://astralcodexten.substack.com
+
+1. <a href="https://astralcodexten.substack.com/p/ontology">Ontology Of Psychiatric Conditions</a>
+
+2. <a href="https://astralcodexten.substack.com/p/still-alive">Still_Alive &lt;3</a>`, sent[0].Get("text"))
+	require.Equal(t, "HTML", sent[0].Get("parse_mode"))
+
+	telegram.Reset()
+
+	// Empty digest isn't sent.
+	now = now.Add(24 * time.Hour)
+
+	require.NoError(t, tgbot.scheduler.RunDue(context.TODO()))
+	require.Empty(t, telegram.Sent("sendMessage"))
+
+	// Queue is cleared when digest is turned off.
+	require.NoError(t, tgbot.deliverNewPost(context.TODO(), chatID, &substackSource{}, models.Post{Title: "Old", URL: "https://astralcodexten.substack.com/p/old"}))
+
+	text, err = tgbot.Digest(context.TODO(), chatID, "off")
+	require.NoError(t, err)
+	require.Equal(t, "Digest is off. New posts will be sent immediately", text)
+
+	queued, err := tgbot.storage.LRange(context.TODO(), digestQueueKey(chatID), 0, -1)
+	require.NoError(t, err)
+	require.Empty(t, queued)
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+type (
+	// UpdateHandler processes one update.
+	UpdateHandler func(ctx context.Context, update tgbotapi.Update)
+
+	// Dispatcher processes updates concurrently with fixed number of workers.
+	// Updates of one chat always go to the same worker, so they're processed in order.
+	Dispatcher struct {
+		handler UpdateHandler
+		queues  []chan tgbotapi.Update
+		wg      sync.WaitGroup
+
+		queued    int64
+		processed uint64
+		blocked   uint64
+		waited    int64
+	}
+
+	// DispatcherStats shows how dispatcher keeps up with updates.
+	DispatcherStats struct {
+		Workers int
+		// Queued is number of updates waiting for a worker.
+		Queued int64
+		// Processed is number of handled updates.
+		Processed uint64
+		// Blocked is number of updates which waited for free space in full queue.
+		Blocked uint64
+		// Waited is total time spent waiting for free space in full queues.
+		Waited time.Duration
+	}
+)
+
+// NewDispatcher returns dispatcher with workers each having queue of queueSize updates.
+func NewDispatcher(workers, queueSize int, handler UpdateHandler) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	d := &Dispatcher{
+		handler: handler,
+		queues:  make([]chan tgbotapi.Update, workers),
+	}
+
+	for i := range d.queues {
+		d.queues[i] = make(chan tgbotapi.Update, queueSize)
+	}
+
+	return d
+}
+
+// Start starts workers. Handler gets ctx of Start.
+func (d *Dispatcher) Start(ctx context.Context) {
+	for _, queue := range d.queues {
+		d.wg.Add(1)
+
+		go func(queue chan tgbotapi.Update) {
+			defer d.wg.Done()
+
+			for update := range queue {
+				atomic.AddInt64(&d.queued, -1)
+				d.handler(ctx, update)
+				atomic.AddUint64(&d.processed, 1)
+			}
+		}(queue)
+	}
+}
+
+// Dispatch queues update to worker of its chat. It blocks while the queue is full.
+func (d *Dispatcher) Dispatch(ctx context.Context, update tgbotapi.Update) error {
+	queue := d.queues[uint64(updateChatID(update))%uint64(len(d.queues))]
+
+	atomic.AddInt64(&d.queued, 1)
+
+	select {
+	case queue <- update:
+		return nil
+	default:
+	}
+
+	atomic.AddUint64(&d.blocked, 1)
+
+	start := time.Now()
+	defer func() { atomic.AddInt64(&d.waited, int64(time.Since(start))) }()
+
+	select {
+	case queue <- update:
+		return nil
+	case <-ctx.Done():
+		atomic.AddInt64(&d.queued, -1)
+		return ctx.Err()
+	}
+}
+
+// Stop waits until queued updates are processed and stops workers. Dispatch must not be called after Stop.
+func (d *Dispatcher) Stop() {
+	for _, queue := range d.queues {
+		close(queue)
+	}
+
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) Stats() DispatcherStats {
+	return DispatcherStats{
+		Workers:   len(d.queues),
+		Queued:    atomic.LoadInt64(&d.queued),
+		Processed: atomic.LoadUint64(&d.processed),
+		Blocked:   atomic.LoadUint64(&d.blocked),
+		Waited:    time.Duration(atomic.LoadInt64(&d.waited)),
+	}
+}
+
+// updateChatID returns id of chat update belongs to. Inline queries have no chat, so sender id is used.
+func updateChatID(update tgbotapi.Update) int64 {
+	switch {
+	case update.Message != nil && update.Message.Chat != nil:
+		return update.Message.Chat.ID
+	case update.ChannelPost != nil && update.ChannelPost.Chat != nil:
+		return update.ChannelPost.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil && update.CallbackQuery.Message.Chat != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		return int64(update.CallbackQuery.From.ID)
+	case update.InlineQuery != nil && update.InlineQuery.From != nil:
+		return int64(update.InlineQuery.From.ID)
+	}
+
+	return 0
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+)
+
+func newChatUpdate(chatID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From: &tgbotapi.User{ID: int(chatID)},
+			Chat: &tgbotapi.Chat{ID: chatID, Type: "private"},
+			Text: text,
+		},
+	}
+}
+
+func TestDispatcherOrder(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		handled = make(map[int64][]string)
+	)
+
+	dispatcher := NewDispatcher(4, 2, func(ctx context.Context, update tgbotapi.Update) {
+		// Slow first messages must not be overtaken by next ones of the same chat.
+		if update.Message.Text == "0" {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		handled[update.Message.Chat.ID] = append(handled[update.Message.Chat.ID], update.Message.Text)
+	})
+
+	dispatcher.Start(context.TODO())
+
+	want := make(map[int64][]string)
+
+	for i := 0; i < 5; i++ {
+		for chatID := int64(-3); chatID <= 3; chatID++ {
+			require.NoError(t, dispatcher.Dispatch(context.TODO(), newChatUpdate(chatID, fmt.Sprint(i))))
+			want[chatID] = append(want[chatID], fmt.Sprint(i))
+		}
+	}
+
+	dispatcher.Stop()
+
+	require.Equal(t, want, handled)
+
+	stats := dispatcher.Stats()
+	require.Equal(t, 4, stats.Workers)
+	require.EqualValues(t, 35, stats.Processed)
+	require.Zero(t, stats.Queued)
+}
+
+func TestDispatcherConcurrency(t *testing.T) {
+	started := make(chan int64, 2)
+	release := make(chan struct{})
+
+	dispatcher := NewDispatcher(2, 0, func(ctx context.Context, update tgbotapi.Update) {
+		started <- update.Message.Chat.ID
+		<-release
+	})
+
+	dispatcher.Start(context.TODO())
+
+	// Chats 1 and 2 are handled by different workers, so a slow chat doesn't block another one.
+	require.NoError(t, dispatcher.Dispatch(context.TODO(), newChatUpdate(1, "slow")))
+	require.NoError(t, dispatcher.Dispatch(context.TODO(), newChatUpdate(2, "fast")))
+
+	require.ElementsMatch(t, []int64{1, 2}, []int64{<-started, <-started})
+
+	close(release)
+	dispatcher.Stop()
+}
+
+func TestDispatcherBackpressure(t *testing.T) {
+	release := make(chan struct{})
+
+	dispatcher := NewDispatcher(1, 1, func(ctx context.Context, update tgbotapi.Update) {
+		<-release
+	})
+
+	dispatcher.Start(context.TODO())
+
+	// The first update is taken by worker and the second one waits in queue.
+	require.NoError(t, dispatcher.Dispatch(context.TODO(), newChatUpdate(1, "1")))
+	require.Eventually(t, func() bool { return dispatcher.Stats().Queued == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, dispatcher.Dispatch(context.TODO(), newChatUpdate(1, "2")))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, dispatcher.Dispatch(ctx, newChatUpdate(1, "3")), context.DeadlineExceeded)
+
+	stats := dispatcher.Stats()
+	require.EqualValues(t, 1, stats.Queued)
+	require.EqualValues(t, 1, stats.Blocked)
+	require.GreaterOrEqual(t, stats.Waited, 10*time.Millisecond)
+
+	close(release)
+	dispatcher.Stop()
+
+	require.EqualValues(t, 2, dispatcher.Stats().Processed)
+}
+
+func TestDispatcherMessageHandler(t *testing.T) {
+	telegram, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{BotAPI: botAPI})
+	require.NoError(t, err)
+
+	dispatcher := NewDispatcher(4, 10, func(ctx context.Context, update tgbotapi.Update) {
+		_, err := tgbot.MessageHandler(ctx, update)
+		require.NoError(t, err)
+	})
+
+	dispatcher.Start(context.TODO())
+
+	command := func(chatID int64, text string) tgbotapi.Update {
+		update := newChatUpdate(chatID, text)
+		update.Message.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len("/source")}}
+
+		return update
+	}
+
+	for chatID := int64(1); chatID <= 20; chatID++ {
+		require.NoError(t, dispatcher.Dispatch(context.TODO(), command(chatID, "/source 2")))
+		require.NoError(t, dispatcher.Dispatch(context.TODO(), command(chatID, "/source")))
+	}
+
+	dispatcher.Stop()
+
+	replies := make(map[string][]string)
+	for _, message := range telegram.Sent("sendMessage") {
+		replies[message.Get("chat_id")] = append(replies[message.Get("chat_id")], message.Get("text"))
+	}
+
+	require.Len(t, replies, 20)
+
+	for chatID, texts := range replies {
+		require.Equal(t, []string{
+			"Changed source to https://slatestarcodex.com",
+			"Current source is https://slatestarcodex.com",
+		}, texts, chatID)
+	}
+}
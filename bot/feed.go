@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+// feedSource reads posts from RSS or Atom feed.
+type feedSource struct {
+	bot    *Bot
+	name   string
+	url    string
+	domain string
+}
+
+func newFeedSource(b *Bot, name, feedURL string) (*feedSource, error) {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse feed url failed: %s", err)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("feed url has no host: %s", feedURL)
+	}
+
+	return &feedSource{
+		bot:    b,
+		name:   name,
+		url:    feedURL,
+		domain: u.Host,
+	}, nil
+}
+
+// ID is short hash of feed url without scheme, e.g. feed-1a2b3c4d5e, as feed urls may be longer than callback data.
+// It's also used as cache key.
+func (s *feedSource) ID() models.Source {
+	hash := sha256.Sum256([]byte(strings.TrimPrefix(strings.TrimPrefix(s.url, "https://"), "http://")))
+	return models.Source("feed-" + hex.EncodeToString(hash[:5]))
+}
+
+func (s *feedSource) Name() string {
+	return s.name
+}
+
+func (s *feedSource) Domain() string {
+	return s.domain
+}
+
+func (s *feedSource) List(ctx context.Context) ([]models.Post, error) {
+	return s.bot.cachedPosts(ctx, s.ID().Value(), func() ([]models.Post, error) {
+		return s.Latest(ctx)
+	})
+}
+
+// Latest fetches feed bypassing cache. Feeds are ordered from newest to oldest.
+func (s *feedSource) Latest(ctx context.Context) ([]models.Post, error) {
+	httpResponse, err := s.bot.httpClient.Get(ctx, s.url)
+	if err != nil {
+		return nil, fmt.Errorf("get %s feed failed: %s", s.url, err)
+	}
+
+	body, err := s.bot.readResponse(httpResponse)
+	if err != nil {
+		return nil, fmt.Errorf("handle %s feed response: %s", s.url, err)
+	}
+
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s feed failed: %s", s.url, err)
+	}
+
+	posts := make([]models.Post, 0, len(feed.Items))
+
+	for _, item := range feed.Items {
+		html := item.Content
+		if html == "" {
+			html = item.Description
+		}
+
+		var author string
+		if item.Author != nil {
+			author = item.Author.Name
+		}
+
+		posts = append(posts, models.Post{
+			Title:  strings.TrimSpace(item.Title),
+			URL:    item.Link,
+			HTML:   html,
+			Author: author,
+		})
+	}
+
+	return posts, nil
+}
+
+// Top returns most recent posts as feeds don't have any rating.
+func (s *feedSource) Top(ctx context.Context) (string, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	text := bytes.NewBufferString(fmt.Sprintf("🏆 Recent posts from https://%s\n\n", renderer.Escape(s.domain)))
+
+	for i, post := range posts[:min(models.DefaultLimit, len(posts))] {
+		text.WriteString(fmt.Sprintf("%d. %s\n\n", i+1, renderer.Link(post.URL, post.Title)))
+	}
+
+	return text.String(), nil
+}
+
+func (s *feedSource) Random(ctx context.Context) (models.Post, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	i := s.bot.randomInt(len(posts))
+
+	return posts[i], nil
+}
+
+// Fetch returns post as is as feed items already have content.
+func (s *feedSource) Fetch(_ context.Context, post models.Post) (models.Post, error) {
+	return post, nil
+}
+
+// Post returns post from cached feed as feed items already have content.
+func (s *feedSource) Post(ctx context.Context, url string) (models.Post, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	post := findPost(posts, url)
+	if post.HTML == "" {
+		return models.Post{}, fmt.Errorf("%s post not found: %s", s.url, url)
+	}
+
+	return post, nil
+}
@@ -0,0 +1,153 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestFeedSource(t *testing.T) {
+	const (
+		rssFeed  = "https://www.overcomingbias.com/feed"
+		atomFeed = "https://srconstantin.github.io/feed.xml"
+	)
+
+	httpClient := &mocks.HTTPClient{}
+
+	for uri, name := range map[string]string{rssFeed: "testdata/feed_rss.xml", atomFeed: "testdata/feed_atom.xml"} {
+		file, err := os.ReadFile(name)
+		require.NoError(t, err)
+
+		httpClient.On("Get", context.TODO(), uri).Return(
+			&http.Response{Body: io.NopCloser(bytes.NewBuffer(file))},
+			nil,
+		).Once()
+	}
+
+	tgbot, err := New(Options{
+		BotAPI:     &tgbotapi.BotAPI{},
+		HTTPClient: httpClient,
+		Config: config.Config{
+			Feeds: []config.Feed{
+				{URL: rssFeed, Name: "Overcoming Bias"},
+				{URL: atomFeed, Name: "Otium"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Contains(t, tgbot.helpMessage(), "  7. <a href=\"https://www.overcomingbias.com\">Overcoming Bias</a>\n")
+	require.Contains(t, tgbot.helpMessage(), "  8. <a href=\"https://srconstantin.github.io\">Otium</a>\n")
+
+	// Long feed url fits into callback data.
+	longFeed, err := newFeedSource(tgbot, "Long Feed", "https://example.com/"+strings.Repeat("feed/", 20))
+	require.NoError(t, err)
+	require.Equal(t, models.Source("feed-f008afac6f"), longFeed.ID())
+	require.NoError(t, tgbot.sources.Register(longFeed))
+
+	randomPost := func(source Source, ctx context.Context) (string, error) {
+		post, err := source.Random(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		return tgbot.previewPost(source, post)
+	}
+
+	type args struct {
+		source     models.Source
+		randomPost int
+	}
+
+	tests := []struct {
+		name    string
+		args    args
+		do      func(source Source, ctx context.Context) (string, error)
+		want    func(t *testing.T, got string)
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name: "Should get recent posts from RSS feed",
+			args: args{
+				source: "feed-747e26f77f",
+			},
+			do: Source.Top,
+			want: func(t *testing.T, got string) {
+				file, err := os.ReadFile("testdata/feed_rss_top_posts.html")
+				require.NoError(t, err)
+				require.Equal(t, string(file), got)
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name: "Should get random post with content from cached RSS feed",
+			args: args{
+				source:     "feed-747e26f77f",
+				randomPost: 0,
+			},
+			do: randomPost,
+			want: func(t *testing.T, got string) {
+				requireGolden(t, "testdata/feed_rss_random_post.html", got)
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name: "Should get random post with description from cached RSS feed",
+			args: args{
+				source:     "feed-747e26f77f",
+				randomPost: 1,
+			},
+			do: randomPost,
+			want: func(t *testing.T, got string) {
+				want := "📝 <a href=\"https://www.overcomingbias.com/p/what-is-signaling\">What Is Signaling?</a>\n\n" +
+					"Signaling is when we do things <b>to show</b> others who we are.\n\n" +
+					"https://www.overcomingbias.com/p/what-is-signaling"
+				require.Equal(t, want, got)
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name: "Should get recent posts from Atom feed",
+			args: args{
+				source: "feed-ff2e5bbf45",
+			},
+			do: Source.Top,
+			want: func(t *testing.T, got string) {
+				want := "🏆 Recent posts from https://srconstantin.github.io\n\n" +
+					"1. <a href=\"https://srconstantin.github.io/2017/06/26/do-rational-people-exist.html\">Do Rational People Exist?</a>\n\n" +
+					"2. <a href=\"https://srconstantin.github.io/2017/05/01/why-i-am-not-a-quaker.html\">Why I Am Not A Quaker</a>\n\n"
+				require.Equal(t, want, got)
+			},
+			wantErr: require.NoError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tgbot.randomInt = func(n int) int {
+				return tt.args.randomPost
+			}
+
+			source, ok := tgbot.sources.Get(tt.args.source)
+			require.True(t, ok)
+
+			got, err := tt.do(source, context.TODO())
+			tt.wantErr(t, err)
+			tt.want(t, got)
+		})
+	}
+
+	// Feeds were fetched only once and then served from cache.
+	httpClient.AssertExpectations(t)
+}
@@ -0,0 +1,349 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+type (
+	// forumMagnumClient queries GraphQL API of ForumMagnum based forums: lesswrong.com, EA Forum, Alignment Forum.
+	forumMagnumClient struct {
+		bot     *Bot
+		baseURL string
+	}
+
+	// graphQLRequest is a body of GraphQL request. User input is passed in variables, so it can't change the query.
+	graphQLRequest struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables,omitempty"`
+	}
+
+	// forumSource reads posts from ForumMagnum based forum.
+	forumSource struct {
+		bot    *Bot
+		id     models.Source
+		name   string
+		domain string
+		client *forumMagnumClient
+	}
+)
+
+func newForumMagnumClient(b *Bot, baseURL string) *forumMagnumClient {
+	return &forumMagnumClient{
+		bot:     b,
+		baseURL: baseURL,
+	}
+}
+
+func (c *forumMagnumClient) Query(ctx context.Context, query string, variables map[string]interface{}) (models.LesswrongResponse, error) {
+	var response models.LesswrongResponse
+
+	request, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return response, fmt.Errorf("marshal request failed: %s", err)
+	}
+
+	httpResponse, err := c.bot.httpClient.Post(ctx, c.baseURL+"/graphql", "application/json", bytes.NewBuffer(request))
+	if err != nil {
+		return response, err
+	}
+
+	if err := c.bot.handleResponse(httpResponse, &response); err != nil {
+		return response, fmt.Errorf("handle response: %s", err)
+	}
+
+	return response, nil
+}
+
+// TopPosts returns top posts published after given date.
+func (c *forumMagnumClient) TopPosts(ctx context.Context, after time.Time) ([]models.LesswrongResult, error) {
+	query := fmt.Sprintf(`{
+		posts(input: {terms: {view: "top", limit: 12, meta: null, after: "%s"}}) {
+			results {
+				title
+				pageUrl
+				user {
+					displayName
+				}
+			}
+		}
+	}`, after.Format("2006-01-02"))
+
+	response, err := c.Query(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data.Posts.Results, nil
+}
+
+// NewPosts returns latest posts without body.
+func (c *forumMagnumClient) NewPosts(ctx context.Context, limit int) ([]models.LesswrongResult, error) {
+	query := fmt.Sprintf(`{
+		posts(input: {terms: {view: "new", limit: %d, meta: null}}) {
+			results {
+				title
+				pageUrl
+				user {
+					displayName
+				}
+			}
+		}
+	}`, limit)
+
+	response, err := c.Query(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data.Posts.Results, nil
+}
+
+// Post returns n-th latest post with body.
+func (c *forumMagnumClient) Post(ctx context.Context, offset int) (models.LesswrongResult, error) {
+	query := fmt.Sprintf(`{
+		posts(input: {terms: {view: "new", limit: 1, meta: null, offset: %d}}) {
+			results {
+				title
+				pageUrl
+				htmlBody
+			}
+		}
+	}`, offset)
+
+	response, err := c.Query(ctx, query, nil)
+	if err != nil {
+		return models.LesswrongResult{}, err
+	}
+
+	if len(response.Data.Posts.Results) == 0 {
+		return models.LesswrongResult{}, fmt.Errorf("post not found, offset: %d", offset)
+	}
+
+	return response.Data.Posts.Results[0], nil
+}
+
+// PostByID returns post with body by its id.
+func (c *forumMagnumClient) PostByID(ctx context.Context, id string) (models.LesswrongResult, error) {
+	query := `query PostByID($id: String) {
+		post(input: {selector: {_id: $id}}) {
+			result {
+				title
+				pageUrl
+				htmlBody
+			}
+		}
+	}`
+
+	response, err := c.Query(ctx, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return models.LesswrongResult{}, err
+	}
+
+	if response.Data.Post.Result.PageURL == "" {
+		return models.LesswrongResult{}, fmt.Errorf("post not found, id: %s", id)
+	}
+
+	return response.Data.Post.Result, nil
+}
+
+// SearchPosts returns posts matching query ordered by relevance.
+func (c *forumMagnumClient) SearchPosts(ctx context.Context, query string, limit int) ([]models.LesswrongResult, error) {
+	request := `query SearchPosts($query: String, $limit: Int) {
+		posts(input: {terms: {view: "search", query: $query, limit: $limit}}) {
+			results {
+				title
+				pageUrl
+				user {
+					displayName
+				}
+			}
+		}
+	}`
+
+	response, err := c.Query(ctx, request, map[string]interface{}{"query": query, "limit": limit})
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data.Posts.Results, nil
+}
+
+// Sequences returns curated sequences without posts.
+func (c *forumMagnumClient) Sequences(ctx context.Context, limit int) ([]models.LesswrongSequence, error) {
+	query := fmt.Sprintf(`{
+		sequences(input: {terms: {view: "curatedSequences", limit: %d}}) {
+			results {
+				_id
+				title
+			}
+		}
+	}`, limit)
+
+	response, err := c.Query(ctx, query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Data.Sequences.Results, nil
+}
+
+// Sequence returns sequence with posts of its chapters in reading order.
+func (c *forumMagnumClient) Sequence(ctx context.Context, id string) (models.LesswrongSequence, error) {
+	query := `query Sequence($id: String) {
+		sequence(input: {selector: {_id: $id}}) {
+			result {
+				_id
+				title
+				chapters {
+					posts {
+						title
+						pageUrl
+					}
+				}
+			}
+		}
+	}`
+
+	response, err := c.Query(ctx, query, map[string]interface{}{"id": id})
+	if err != nil {
+		return models.LesswrongSequence{}, err
+	}
+
+	if response.Data.Sequence.Result.ID == "" {
+		return models.LesswrongSequence{}, fmt.Errorf("sequence not found, id: %s", id)
+	}
+
+	return response.Data.Sequence.Result, nil
+}
+
+func newForumSource(b *Bot, id models.Source, name, domain, baseURL string) *forumSource {
+	return &forumSource{
+		bot:    b,
+		id:     id,
+		name:   name,
+		domain: domain,
+		client: newForumMagnumClient(b, baseURL),
+	}
+}
+
+func (s *forumSource) ID() models.Source {
+	return s.id
+}
+
+func (s *forumSource) Name() string {
+	return s.name
+}
+
+func (s *forumSource) Domain() string {
+	return s.domain
+}
+
+func (s *forumSource) List(ctx context.Context) ([]models.Post, error) {
+	results, err := s.client.NewPosts(ctx, models.DefaultLimit)
+	if err != nil {
+		return nil, fmt.Errorf("get %s new posts failed: %s", s.domain, err)
+	}
+
+	posts := make([]models.Post, 0, len(results))
+	for _, result := range results {
+		posts = append(posts, result.AsPost())
+	}
+
+	return posts, nil
+}
+
+// Latest returns newest posts. As List isn't cached it's the same.
+func (s *forumSource) Latest(ctx context.Context) ([]models.Post, error) {
+	return s.List(ctx)
+}
+
+// Search uses forum search as List returns only latest posts.
+func (s *forumSource) Search(ctx context.Context, query string) ([]models.Post, error) {
+	results, err := s.client.SearchPosts(ctx, query, models.DefaultLimit)
+	if err != nil {
+		return nil, fmt.Errorf("search %s posts failed: %s", s.domain, err)
+	}
+
+	posts := make([]models.Post, 0, len(results))
+	for _, result := range results {
+		posts = append(posts, result.AsPost())
+	}
+
+	return posts, nil
+}
+
+func (s *forumSource) Sequences(ctx context.Context) ([]models.Sequence, error) {
+	results, err := s.client.Sequences(ctx, models.SequencesMaxCount)
+	if err != nil {
+		return nil, fmt.Errorf("get %s sequences failed: %s", s.domain, err)
+	}
+
+	sequences := make([]models.Sequence, 0, len(results))
+	for _, result := range results {
+		sequences = append(sequences, result.AsSequence())
+	}
+
+	return sequences, nil
+}
+
+func (s *forumSource) Sequence(ctx context.Context, id string) (models.Sequence, error) {
+	result, err := s.client.Sequence(ctx, id)
+	if err != nil {
+		return models.Sequence{}, fmt.Errorf("get %s sequence failed: %s", s.domain, err)
+	}
+
+	return result.AsSequence(), nil
+}
+
+func (s *forumSource) Top(ctx context.Context) (string, error) {
+	results, err := s.client.TopPosts(ctx, time.Now().AddDate(0, 0, -7))
+	if err != nil {
+		return "", fmt.Errorf("get %s top posts failed: %s", s.domain, err)
+	}
+
+	text := bytes.NewBufferString(fmt.Sprintf("🏆 Top posts this week from https://%s:\n\n", s.domain))
+
+	for i, post := range results {
+		text.WriteString(fmt.Sprintf("%d. %s (%s)\n\n", i+1, renderer.Link(post.PageURL, post.Title), renderer.Escape(post.User.DisplayName)))
+	}
+
+	return text.String(), nil
+}
+
+func (s *forumSource) Random(ctx context.Context) (models.Post, error) {
+	result, err := s.client.Post(ctx, s.bot.randomInt(models.LesswrongPostsMaxCount))
+	if err != nil {
+		return models.Post{}, fmt.Errorf("get %s random post failed: %s", s.domain, err)
+	}
+
+	return result.AsPost(), nil
+}
+
+// Post returns post by url in format https://domain/posts/id/slug.
+func (s *forumSource) Post(ctx context.Context, postURL string) (models.Post, error) {
+	u, err := url.Parse(postURL)
+	if err != nil {
+		return models.Post{}, fmt.Errorf("parse %s post url failed: %s", s.domain, err)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "posts" {
+		return models.Post{}, fmt.Errorf("%s post url has no id: %s", s.domain, postURL)
+	}
+
+	result, err := s.client.PostByID(ctx, parts[1])
+	if err != nil {
+		return models.Post{}, fmt.Errorf("get %s post failed: %s", s.domain, err)
+	}
+
+	return result.AsPost(), nil
+}
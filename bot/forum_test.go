@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestForumSource(t *testing.T) {
+	topQuery := fmt.Sprintf(`{
+		posts(input: {terms: {view: "top", limit: 12, meta: null, after: "%s"}}) {
+			results {
+				title
+				pageUrl
+				user {
+					displayName
+				}
+			}
+		}
+	}`, time.Now().AddDate(0, 0, -7).Format("2006-01-02"))
+
+	randomQuery := `{
+		posts(input: {terms: {view: "new", limit: 1, meta: null, offset: 0}}) {
+			results {
+				title
+				pageUrl
+				htmlBody
+			}
+		}
+	}`
+
+	listQuery := `{
+		posts(input: {terms: {view: "new", limit: 12, meta: null}}) {
+			results {
+				title
+				pageUrl
+				user {
+					displayName
+				}
+			}
+		}
+	}`
+
+	mockQuery := func(httpClient *mocks.HTTPClient, uri, query string, body []byte) {
+		request, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+
+		httpClient.On("Post", context.TODO(), uri, "application/json", bytes.NewBuffer(request)).Return(
+			&http.Response{Body: io.NopCloser(bytes.NewBuffer(body))},
+			nil,
+		)
+	}
+
+	topPosts, err := os.ReadFile("testdata/lesswrong_top_posts.json")
+	require.NoError(t, err)
+
+	randomPost, err := os.ReadFile("testdata/lesswrong_random_post.json")
+	require.NoError(t, err)
+
+	topPostsWant, err := os.ReadFile("testdata/lesswrong_top_posts.html")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		source  models.Source
+		baseURL string
+		domain  string
+	}{
+		{
+			name:    "Should get posts from https://lesswrong.com",
+			source:  models.SourceLesswrong,
+			baseURL: "https://www.lesswrong.com",
+			domain:  models.DomainLesswrong,
+		},
+		{
+			name:    "Should get posts from https://forum.effectivealtruism.org",
+			source:  models.SourceEAForum,
+			baseURL: "https://forum.effectivealtruism.org",
+			domain:  models.DomainEAForum,
+		},
+		{
+			name:    "Should get posts from https://alignmentforum.org",
+			source:  models.SourceAlignmentForum,
+			baseURL: "https://www.alignmentforum.org",
+			domain:  models.DomainAlignmentForum,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient := &mocks.HTTPClient{}
+			mockQuery(httpClient, tt.baseURL+"/graphql", topQuery, topPosts)
+			mockQuery(httpClient, tt.baseURL+"/graphql", randomQuery, randomPost)
+			mockQuery(httpClient, tt.baseURL+"/graphql", listQuery, topPosts)
+
+			tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}, HTTPClient: httpClient})
+			require.NoError(t, err)
+
+			tgbot.randomInt = func(n int) int {
+				return 0
+			}
+
+			source, ok := tgbot.sources.Get(tt.source)
+			require.True(t, ok)
+			require.Equal(t, tt.domain, source.Domain())
+
+			top, err := source.Top(context.TODO())
+			require.NoError(t, err)
+
+			want := strings.Replace(string(topPostsWant), "https://lesswrong.com:", "https://"+tt.domain+":", 1)
+			require.Equal(t, want, top)
+
+			post, err := source.Random(context.TODO())
+			require.NoError(t, err)
+
+			random, err := tgbot.previewPost(source, post)
+			require.NoError(t, err)
+			requireGolden(t, "testdata/lesswrong_random_post.html", random)
+
+			posts, err := source.List(context.TODO())
+			require.NoError(t, err)
+			require.NotEmpty(t, posts)
+			require.Equal(t, "RadVac Commercial Antibody Test Results", posts[0].Title)
+			require.Empty(t, posts[0].HTML)
+
+			httpClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestForumMagnumClient_PostNotFound(t *testing.T) {
+	httpClient := &mocks.HTTPClient{}
+
+	httpClient.On("Post", context.TODO(), "https://forum.effectivealtruism.org/graphql", "application/json", mock.Anything).Return(
+		&http.Response{Body: io.NopCloser(bytes.NewBufferString(`{"data":{"posts":{"results":[]}}}`))},
+		nil,
+	)
+
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}, HTTPClient: httpClient})
+	require.NoError(t, err)
+
+	client := newForumMagnumClient(tgbot, "https://forum.effectivealtruism.org")
+
+	_, err = client.Post(context.TODO(), 42)
+	require.EqualError(t, err, "post not found, offset: 42")
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// MessageAdminsOnly is sent to group members who aren't admins on commands changing chat settings.
+const MessageAdminsOnly = "Only chat admins can change settings of this chat"
+
+// adminCommands change chat settings, so in groups only chat admins can run them.
+// Commands with false value can be run by anyone without arguments to show current settings.
+var adminCommands = map[string]bool{
+	"source":      false,
+	"instantview": false,
+	"digest":      false,
+	"course":      false,
+	"autopost":    false,
+	"sequence":    false,
+	"next":        true,
+	"subscribe":   true,
+	"unsubscribe": true,
+}
+
+// isGroupChat returns true for chats shared by several users: groups, supergroups and channels.
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup() || chat.IsChannel())
+}
+
+// settingsID returns id which settings such as source are stored by.
+// Settings are shared by all members of group chats and are personal in private chats.
+func settingsID(chat *tgbotapi.Chat, from *tgbotapi.User) int {
+	if isGroupChat(chat) || from == nil {
+		return int(chat.ID)
+	}
+
+	return from.ID
+}
+
+// personalID returns id of user who sent message. Channel posts have no sender, so chat id is used.
+func personalID(message *tgbotapi.Message) int {
+	if message.From == nil {
+		return int(message.Chat.ID)
+	}
+
+	return message.From.ID
+}
+
+// addressedToOtherBot returns true for commands like /top@OtherBot sent to group with several bots.
+func (b *Bot) addressedToOtherBot(message *tgbotapi.Message) bool {
+	_, botName, ok := strings.Cut(message.CommandWithAt(), "@")
+
+	return ok && !strings.EqualFold(botName, b.botAPI.Self.UserName)
+}
+
+// canChangeSettings returns true if user is allowed to change settings of chat.
+// Channel posts are sent only by channel admins.
+func (b *Bot) canChangeSettings(chat *tgbotapi.Chat, from *tgbotapi.User) (bool, error) {
+	if !isGroupChat(chat) || chat.IsChannel() {
+		return true, nil
+	}
+
+	if from == nil {
+		return false, nil
+	}
+
+	member, err := b.botAPI.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: chat.ID, UserID: from.ID})
+	if err != nil {
+		return false, fmt.Errorf("get chat member failed: %s, chat: %d, user: %d", err, chat.ID, from.ID)
+	}
+
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
+
+// callbackAllowed returns true if user who pressed button is allowed to change settings of chat.
+// Buttons of inline messages have no chat, so they change personal settings of user.
+func (b *Bot) callbackAllowed(callback *tgbotapi.CallbackQuery) bool {
+	if callback.Message == nil {
+		return true
+	}
+
+	allowed, err := b.canChangeSettings(callback.Message.Chat, callback.From)
+	if err != nil {
+		log.Printf("[ERROR] Check chat admin failed: %s", err)
+	}
+
+	return allowed
+}
+
+// callbackSettingsID returns settings id of chat where button was pressed.
+func callbackSettingsID(callback *tgbotapi.CallbackQuery) int {
+	if callback.Message == nil {
+		return callback.From.ID
+	}
+
+	return settingsID(callback.Message.Chat, callback.From)
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupChat(t *testing.T) {
+	const (
+		adminID  = 1
+		memberID = 2
+	)
+
+	telegram, botAPI := newTelegramStub(t)
+	telegram.admins = map[string]bool{"1": true}
+	botAPI.Self.UserName = "lesswrong_bot"
+
+	tgbot, err := New(Options{BotAPI: botAPI})
+	require.NoError(t, err)
+
+	group := &tgbotapi.Chat{ID: -100, Type: "supergroup"}
+	channel := &tgbotapi.Chat{ID: -200, Type: "channel"}
+
+	newMessage := func(chat *tgbotapi.Chat, from *tgbotapi.User, text string) *tgbotapi.Message {
+		command, _, _ := strings.Cut(text, " ")
+
+		message := &tgbotapi.Message{From: from, Chat: chat, Text: text}
+		if strings.HasPrefix(command, "/") {
+			message.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len(command)}}
+		}
+
+		return message
+	}
+
+	send := func(chat *tgbotapi.Chat, userID int, text string) string {
+		sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+			Message: newMessage(chat, &tgbotapi.User{ID: userID}, text),
+		})
+		require.NoError(t, err)
+
+		return sent.Text
+	}
+
+	tests := []struct {
+		name string
+		chat *tgbotapi.Chat
+		user int
+		text string
+		want string
+	}{
+		{
+			name: "Should forbid member to change source of group",
+			chat: group, user: memberID, text: "/source 2",
+			want: MessageAdminsOnly,
+		},
+		{
+			name: "Should show source of group to member",
+			chat: group, user: memberID, text: "/source",
+			want: "Current source is https://lesswrong.ru",
+		},
+		{
+			name: "Should allow admin to change source of group",
+			chat: group, user: adminID, text: "/source@LessWrong_Bot 2",
+			want: "Changed source to https://slatestarcodex.com",
+		},
+		{
+			name: "Should share source between members of group",
+			chat: group, user: memberID, text: "/source",
+			want: "Current source is https://slatestarcodex.com",
+		},
+		{
+			name: "Should keep personal source in private chat",
+			chat: &tgbotapi.Chat{ID: memberID, Type: "private"}, user: memberID, text: "/source",
+			want: "Current source is https://lesswrong.ru",
+		},
+		{
+			name: "Should forbid member to subscribe group",
+			chat: group, user: memberID, text: "/subscribe",
+			want: MessageAdminsOnly,
+		},
+		{
+			name: "Should forbid member to move sequence of group",
+			chat: group, user: memberID, text: "/next",
+			want: MessageAdminsOnly,
+		},
+		{
+			name: "Should ignore command to other bot",
+			chat: group, user: adminID, text: "/source@other_bot 3",
+		},
+		{
+			name: "Should ignore text messages in group",
+			chat: group, user: adminID, text: "hello",
+		},
+		{
+			name: "Should answer text messages in private chat",
+			chat: &tgbotapi.Chat{ID: memberID, Type: "private"}, user: memberID, text: "hello",
+			want: "I don't know that command",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, send(tt.chat, tt.user, tt.text))
+		})
+	}
+
+	var members []string
+	for _, request := range telegram.Sent("getChatMember") {
+		members = append(members, request.Get("user_id"))
+	}
+
+	require.Equal(t, []string{"2", "1", "2", "2"}, members)
+
+	// Channel posts are sent by admins only and have no sender.
+	sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		ChannelPost: newMessage(channel, nil, "/source 3"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Changed source to https://astralcodexten.substack.com", sent.Text)
+	require.Len(t, telegram.Sent("getChatMember"), 4)
+
+	// Buttons changing settings are checked too.
+	for _, data := range []string{"3", sequenceCallbackPrefix + "1:rationality-az", resetCallbackPrefix + "1"} {
+		sent, err = tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+			CallbackQuery: &tgbotapi.CallbackQuery{
+				ID:      "callback",
+				From:    &tgbotapi.User{ID: memberID},
+				Message: &tgbotapi.Message{MessageID: 1, Chat: group},
+				Data:    data,
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, MessageAdminsOnly, sent.Text, data)
+	}
+
+	require.Equal(t, "Current source is https://slatestarcodex.com", send(group, memberID, "/source"))
+
+	// Buttons of inline messages have no chat, so personal settings are changed.
+	sent, err = tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:              "callback",
+			From:            &tgbotapi.User{ID: memberID},
+			InlineMessageID: "inline",
+			Data:            "3",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Changed source to https://astralcodexten.substack.com", sent.Text)
+	require.Equal(t, "Current source is https://astralcodexten.substack.com", send(&tgbotapi.Chat{ID: memberID, Type: "private"}, memberID, "/source"))
+}
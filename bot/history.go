@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+// historyItem is a post served to user by /random.
+type historyItem struct {
+	Title    string    `json:"title"`
+	URL      string    `json:"url"`
+	Source   string    `json:"source"`
+	ServedAt time.Time `json:"served_at"`
+}
+
+// History returns last posts served to user, newest first.
+func (b *Bot) History(ctx context.Context, userID int) (string, error) {
+	items, err := b.history(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if len(items) == 0 {
+		return "History is empty. Send /random to read a post", nil
+	}
+
+	var text strings.Builder
+
+	text.WriteString(fmt.Sprintf("🕘 Last %d read posts:\n\n", len(items)))
+
+	for i, item := range items {
+		text.WriteString(fmt.Sprintf("%d. %s (%s)\n\n", i+1, renderer.Link(item.URL, item.Title), renderer.Escape(item.Source)))
+	}
+
+	return strings.TrimSuffix(text.String(), "\n\n"), nil
+}
+
+// addHistory marks post as served and adds it to the top of user history bounded by models.HistoryMaxLength.
+func (b *Bot) addHistory(ctx context.Context, userID int, source Source, post models.Post) error {
+	if err := b.markServed(ctx, userID, source, post.URL); err != nil {
+		return err
+	}
+
+	key := historyKey(userID)
+	item := historyItem{Title: post.Title, URL: post.URL, Source: source.Name(), ServedAt: b.now()}
+
+	itemCache, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("marshal history failed: %s, key: %s", err, key)
+	}
+
+	if err := b.storage.LPush(ctx, key, string(itemCache)); err != nil {
+		return fmt.Errorf("push history failed: %s, key: %s", err, key)
+	}
+
+	if err := b.storage.LTrim(ctx, key, 0, models.HistoryMaxLength-1); err != nil {
+		return fmt.Errorf("trim history failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+func (b *Bot) history(ctx context.Context, userID int) ([]historyItem, error) {
+	key := historyKey(userID)
+
+	historyCached, err := b.storage.LRange(ctx, key, 0, models.HistoryMaxLength-1)
+	if err != nil {
+		return nil, fmt.Errorf("get history failed: %s, key: %s", err, key)
+	}
+
+	items := make([]historyItem, 0, len(historyCached))
+
+	for _, itemCached := range historyCached {
+		var item historyItem
+
+		if err := json.Unmarshal([]byte(itemCached), &item); err != nil {
+			return nil, fmt.Errorf("unmarshal history failed: %s, key: %s", err, key)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// historyKey is a key of list with last served posts, newest first.
+func historyKey(userID int) string {
+	return fmt.Sprintf("history:%d", userID)
+}
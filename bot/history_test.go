@@ -0,0 +1,157 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+func TestRandomPost_NoRepeat(t *testing.T) {
+	const (
+		userID  = 1
+		rssFeed = "https://www.overcomingbias.com/feed"
+	)
+
+	file, err := os.ReadFile("testdata/feed_rss.xml")
+	require.NoError(t, err)
+
+	httpClient := &mocks.HTTPClient{}
+	httpClient.On("Get", context.TODO(), rssFeed).Return(
+		&http.Response{Body: io.NopCloser(bytes.NewBuffer(file))},
+		nil,
+	).Once()
+
+	telegram, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{
+		BotAPI:     botAPI,
+		HTTPClient: httpClient,
+		Config:     config.Config{Feeds: []config.Feed{{URL: rssFeed, Name: "Overcoming Bias"}}},
+		RandomInt:  func(n int) int { return n - 1 },
+	})
+	require.NoError(t, err)
+
+	_, _, err = tgbot.ChangeSource(context.TODO(), userID, "feed-747e26f77f")
+	require.NoError(t, err)
+
+	text, err := tgbot.History(context.TODO(), userID)
+	require.NoError(t, err)
+	require.Equal(t, "History is empty. Send /random to read a post", text)
+
+	random := func() string {
+		sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: userID},
+				Chat:     &tgbotapi.Chat{ID: userID},
+				Text:     "/random",
+				Entities: &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len("/random")}},
+			},
+		})
+		require.NoError(t, err)
+
+		return sent.Text
+	}
+
+	var titles []string
+
+	// Every post is served once although random always picks the last one.
+	for i := 0; i < 3; i++ {
+		titles = append(titles, strings.SplitN(random(), "\n", 2)[0])
+	}
+
+	require.Equal(t, []string{
+		`📝 <a href="https://www.overcomingbias.com/p/near-far-summary">Near Far Summary</a>`,
+		`📝 <a href="https://www.overcomingbias.com/p/what-is-signaling">What Is Signaling?</a>`,
+		`📝 <a href="https://www.overcomingbias.com/p/beware-consistency-checks">Beware Consistency Checks</a>`,
+	}, titles)
+
+	require.Equal(t, "🎉 You've read all posts from https://www.overcomingbias.com. Reset history to read them again?", random())
+
+	messages := telegram.Sent("sendMessage")
+	require.Contains(t, messages[len(messages)-1].Get("reply_markup"), resetCallbackPrefix+"feed-747e26f77f")
+
+	sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "callback",
+			From:    &tgbotapi.User{ID: userID},
+			Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: userID}},
+			Data:    resetCallbackPrefix + "feed-747e26f77f",
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "History of https://www.overcomingbias.com is reset. Send /random to read a post", sent.Text)
+	require.Len(t, telegram.Sent("editMessageText"), 1)
+
+	require.True(t, strings.HasPrefix(random(), `📝 <a href="https://www.overcomingbias.com/p/near-far-summary">`))
+
+	text, err = tgbot.History(context.TODO(), userID)
+	require.NoError(t, err)
+	require.Equal(t, `🕘 Last 4 read posts:
+
+1. <a href="https://www.overcomingbias.com/p/near-far-summary">Near Far Summary</a> (Overcoming Bias)
+
+2. <a href="https://www.overcomingbias.com/p/beware-consistency-checks">Beware Consistency Checks</a> (Overcoming Bias)
+
+3. <a href="https://www.overcomingbias.com/p/what-is-signaling">What Is Signaling?</a> (Overcoming Bias)
+
+4. <a href="https://www.overcomingbias.com/p/near-far-summary">Near Far Summary</a> (Overcoming Bias)`, text)
+
+	text, err = tgbot.ResetHistory(context.TODO(), userID, "unknown")
+	require.NoError(t, err)
+	require.Equal(t, "Source is invalid", text)
+
+	httpClient.AssertExpectations(t)
+}
+
+func TestMarkServed(t *testing.T) {
+	ctx := context.TODO()
+	storage := memory.NewStorage()
+
+	_, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{BotAPI: botAPI, Storage: storage})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		source models.Source
+		list   bool
+		want   int
+	}{
+		{name: "Should keep all posts of catalog", source: "3", want: models.ServedMaxCount + 10},
+		{name: "Should keep last posts of source without catalog", source: "4", list: true, want: models.ServedMaxCount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, ok := tgbot.sources.Get(tt.source)
+			require.True(t, ok)
+
+			for i := 0; i < models.ServedMaxCount+10; i++ {
+				require.NoError(t, tgbot.markServed(ctx, 1, source, "https://example.com/"+strconv.Itoa(i)))
+			}
+
+			served, err := storage.SMembers(ctx, servedKey(1, source))
+			if tt.list {
+				served, err = storage.LRange(ctx, servedKey(1, source), 0, -1)
+			}
+
+			require.NoError(t, err)
+			require.Len(t, served, tt.want)
+			require.Contains(t, served, "https://example.com/"+strconv.Itoa(models.ServedMaxCount+9))
+		})
+	}
+}
@@ -1,44 +1,335 @@
 package bot
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
 )
 
-type DefaultHTTPClient struct {
-	*http.Client
-}
+// ErrCircuitOpen is returned without sending request when host failed too many times in a row.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Defaults of HTTPClientOptions.
+const (
+	DefaultMaxRetries       = 3
+	DefaultBaseDelay        = 500 * time.Millisecond
+	DefaultMaxDelay         = 30 * time.Second
+	DefaultMaxPerHost       = 4
+	DefaultBreakerThreshold = 5
+	DefaultBreakerTimeout   = time.Minute
+)
+
+type (
+	// DefaultHTTPClient retries failed requests with exponential backoff, limits concurrent requests
+	// to each host and stops sending requests to host which keeps failing.
+	DefaultHTTPClient struct {
+		client  *http.Client
+		options HTTPClientOptions
+		now     func() time.Time
+		sleep   func(ctx context.Context, d time.Duration) error
+		jitter  func(d time.Duration) time.Duration
+
+		mu    sync.Mutex
+		hosts map[string]*hostState
+	}
+
+	// HTTPClientOptions configures DefaultHTTPClient. Zero values are replaced with defaults.
+	HTTPClientOptions struct {
+		Client *http.Client
+		// MaxRetries is number of retries after the first attempt. Negative value disables retries.
+		MaxRetries int
+		// BaseDelay is delay before the first retry. It's doubled for each next retry up to MaxDelay.
+		BaseDelay time.Duration
+		// MaxDelay limits backoff and Retry-After delays. Response is returned as is if server asks to wait longer.
+		MaxDelay time.Duration
+		// MaxPerHost is max number of concurrent requests to one host.
+		MaxPerHost int
+		// BreakerThreshold is number of failed requests in a row after which circuit breaker opens.
+		BreakerThreshold int
+		// BreakerTimeout is time circuit breaker stays open before letting a trial request through.
+		BreakerTimeout time.Duration
+	}
+
+	// hostState is concurrency limit and circuit breaker state of one host.
+	hostState struct {
+		slots     chan struct{}
+		failures  int
+		openUntil time.Time
+		probing   bool
+	}
+)
 
 func NewHTTPClient() *DefaultHTTPClient {
+	return NewHTTPClientWithOptions(HTTPClientOptions{})
+}
+
+func NewHTTPClientWithOptions(opts HTTPClientOptions) *DefaultHTTPClient {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+
+	if opts.BaseDelay == 0 {
+		opts.BaseDelay = DefaultBaseDelay
+	}
+
+	if opts.MaxDelay == 0 {
+		opts.MaxDelay = DefaultMaxDelay
+	}
+
+	if opts.MaxPerHost == 0 {
+		opts.MaxPerHost = DefaultMaxPerHost
+	}
+
+	if opts.BreakerThreshold == 0 {
+		opts.BreakerThreshold = DefaultBreakerThreshold
+	}
+
+	if opts.BreakerTimeout == 0 {
+		opts.BreakerTimeout = DefaultBreakerTimeout
+	}
+
 	return &DefaultHTTPClient{
-		Client: http.DefaultClient,
+		client:  opts.Client,
+		options: opts,
+		now:     time.Now,
+		sleep:   sleep,
+		jitter:  jitter,
+		hosts:   map[string]*hostState{},
 	}
 }
 
 func (c *DefaultHTTPClient) Get(ctx context.Context, url string) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	return c.Send(ctx, http.MethodGet, url, nil, nil)
+}
+
+func (c *DefaultHTTPClient) Post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	// Body is buffered to send it again on retry.
+	bodyBytes, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read request body failed: %s", err)
+	}
+
+	return c.Send(ctx, http.MethodPost, url, bodyBytes, http.Header{"Content-Type": {contentType}})
+}
+
+// Send sends request with extra headers retrying transport errors, 429 and 5xx responses.
+// The last response is returned as is when retries are exhausted, so callers can handle its status.
+func (c *DefaultHTTPClient) Send(ctx context.Context, method, uri string, body []byte, header http.Header) (*http.Response, error) {
+	u, err := url.Parse(uri)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; LesswrongBot/1.0)")
-	req.Header.Set("Accept", "application/json, text/html, */*")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	host := c.host(u.Host)
+
+	for attempt := 0; ; attempt++ {
+		if err := c.allow(host); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, u.Host)
+		}
+
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
 
-	return c.Do(req)
+		req, err := http.NewRequestWithContext(ctx, method, uri, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; LesswrongBot/1.0)")
+		req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+
+		if method == http.MethodPost {
+			req.Header.Set("Accept", "application/json")
+		} else {
+			req.Header.Set("Accept", "application/json, text/html, */*")
+		}
+
+		for name, values := range header {
+			req.Header[name] = values
+		}
+
+		httpResponse, err := c.send(ctx, host, req)
+
+		retryable := isRetryable(httpResponse, err)
+		c.record(host, err == nil && !retryable)
+
+		if !retryable || attempt >= c.options.MaxRetries || ctx.Err() != nil {
+			return httpResponse, err
+		}
+
+		delay := c.backoff(attempt)
+
+		if httpResponse != nil {
+			if retryAfter, ok := parseRetryAfter(httpResponse.Header.Get("Retry-After"), c.now()); ok {
+				if retryAfter > c.options.MaxDelay {
+					return httpResponse, nil
+				}
+
+				delay = retryAfter
+			}
+
+			// Body is drained so connection can be reused.
+			_, _ = io.Copy(io.Discard, httpResponse.Body)
+			httpResponse.Body.Close()
+		}
+
+		if err := c.sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
 }
 
-func (c *DefaultHTTPClient) Post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+// send sends request holding one of host's concurrency slots.
+func (c *DefaultHTTPClient) send(ctx context.Context, host *hostState, req *http.Request) (*http.Response, error) {
+	select {
+	case host.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	defer func() { <-host.slots }()
+
+	return c.client.Do(req)
+}
+
+func (c *DefaultHTTPClient) host(name string) *hostState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host, ok := c.hosts[name]
+	if !ok {
+		host = &hostState{slots: make(chan struct{}, c.options.MaxPerHost)}
+		c.hosts[name] = host
+	}
+
+	return host
+}
+
+// allow returns ErrCircuitOpen if circuit breaker of host is open.
+// When it's timed out only one trial request is let through until its result is recorded.
+func (c *DefaultHTTPClient) allow(host *hostState) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if host.failures < c.options.BreakerThreshold {
+		return nil
+	}
+
+	if c.now().Before(host.openUntil) || host.probing {
+		return ErrCircuitOpen
+	}
+
+	host.probing = true
+
+	return nil
+}
+
+// record updates circuit breaker of host with result of request.
+func (c *DefaultHTTPClient) record(host *hostState, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	host.probing = false
+
+	if ok {
+		host.failures = 0
+		return
+	}
+
+	host.failures++
+
+	if host.failures >= c.options.BreakerThreshold {
+		host.openUntil = c.now().Add(c.options.BreakerTimeout)
+	}
+}
+
+// backoff returns exponential delay with jitter before retry.
+func (c *DefaultHTTPClient) backoff(attempt int) time.Duration {
+	delay := c.options.BaseDelay << attempt
+	if delay <= 0 || delay > c.options.MaxDelay {
+		delay = c.options.MaxDelay
+	}
+
+	return c.jitter(delay)
+}
+
+// isRetryable returns true for transport errors, rate limiting and server errors.
+func isRetryable(httpResponse *http.Response, err error) bool {
 	if err != nil {
-		return nil, err
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch httpResponse.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
 	}
 
-	req.Header.Set("Content-Type", contentType)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; LesswrongBot/1.0)")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	return false
+}
+
+// parseRetryAfter parses Retry-After header which is either delay in seconds or HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+
+		return time.Duration(seconds) * time.Second, true
+	}
 
-	return c.Do(req)
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+
+	return 0, true
+}
+
+// jitter returns random delay between d/2 and d, so clients don't retry at the same time.
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -0,0 +1,245 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestHTTPClient returns client which records delays instead of sleeping.
+func newTestHTTPClient(opts HTTPClientOptions) (*DefaultHTTPClient, *[]time.Duration) {
+	var delays []time.Duration
+
+	client := NewHTTPClientWithOptions(opts)
+	client.jitter = func(d time.Duration) time.Duration { return d }
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	return client, &delays
+}
+
+func TestHTTPClientRetries(t *testing.T) {
+	type response struct {
+		status     int
+		retryAfter string
+	}
+
+	tests := []struct {
+		name       string
+		responses  []response
+		wantStatus int
+		wantDelays []time.Duration
+	}{
+		{
+			name:       "Should not retry successful response",
+			responses:  []response{{status: http.StatusOK}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Should not retry client error",
+			responses:  []response{{status: http.StatusNotFound}},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "Should retry server errors with exponential backoff",
+			responses: []response{
+				{status: http.StatusInternalServerError},
+				{status: http.StatusBadGateway},
+				{status: http.StatusServiceUnavailable},
+				{status: http.StatusOK},
+			},
+			wantStatus: http.StatusOK,
+			wantDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond},
+		},
+		{
+			name: "Should wait as long as Retry-After says",
+			responses: []response{
+				{status: http.StatusTooManyRequests, retryAfter: "2"},
+				{status: http.StatusTooManyRequests, retryAfter: "Sat, 01 May 2021 12:00:03 GMT"},
+				{status: http.StatusOK},
+			},
+			wantStatus: http.StatusOK,
+			wantDelays: []time.Duration{2 * time.Second, 3 * time.Second},
+		},
+		{
+			name: "Should return the last response when retries are exhausted",
+			responses: []response{
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests},
+			},
+			wantStatus: http.StatusTooManyRequests,
+			wantDelays: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond},
+		},
+		{
+			name: "Should not wait longer than max delay",
+			responses: []response{
+				{status: http.StatusTooManyRequests, retryAfter: "3600"},
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, `{"query":"posts"}`, string(body))
+
+				response := tt.responses[atomic.AddInt32(&requests, 1)-1]
+				if response.retryAfter != "" {
+					w.Header().Set("Retry-After", response.retryAfter)
+				}
+
+				w.WriteHeader(response.status)
+			}))
+			defer server.Close()
+
+			client, delays := newTestHTTPClient(HTTPClientOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Minute})
+			client.now = func() time.Time { return time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+			httpResponse, err := client.Post(context.TODO(), server.URL, "application/json", strings.NewReader(`{"query":"posts"}`))
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStatus, httpResponse.StatusCode)
+			require.Equal(t, tt.wantDelays, *delays)
+			require.Equal(t, len(tt.responses), int(atomic.LoadInt32(&requests)))
+		})
+	}
+}
+
+func TestHTTPClientCircuitBreaker(t *testing.T) {
+	var (
+		requests int32
+		status   int32 = http.StatusInternalServerError
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	now := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	client, _ := newTestHTTPClient(HTTPClientOptions{MaxRetries: -1, BreakerThreshold: 2, BreakerTimeout: time.Minute})
+	client.now = func() time.Time { return now }
+
+	get := func() (*http.Response, error) {
+		return client.Get(context.TODO(), server.URL)
+	}
+
+	for i := 0; i < 2; i++ {
+		httpResponse, err := get()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, httpResponse.StatusCode)
+	}
+
+	// Breaker is open, so request isn't sent.
+	_, err := get()
+	require.ErrorIs(t, err, ErrCircuitOpen)
+	require.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+	// Failed trial request opens breaker again.
+	now = now.Add(time.Minute)
+
+	httpResponse, err := get()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, httpResponse.StatusCode)
+
+	_, err = get()
+	require.ErrorIs(t, err, ErrCircuitOpen)
+
+	// Successful trial request closes breaker.
+	now = now.Add(time.Minute)
+	atomic.StoreInt32(&status, http.StatusOK)
+
+	for i := 0; i < 2; i++ {
+		httpResponse, err := get()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+	}
+
+	require.EqualValues(t, 5, atomic.LoadInt32(&requests))
+}
+
+func TestHTTPClientMaxPerHost(t *testing.T) {
+	var (
+		inFlight    int32
+		maxInFlight int32
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClientWithOptions(HTTPClientOptions{MaxPerHost: 2})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			httpResponse, err := client.Get(context.TODO(), server.URL)
+			require.NoError(t, err)
+			httpResponse.Body.Close()
+		}()
+	}
+
+	wg.Wait()
+
+	require.EqualValues(t, 2, atomic.LoadInt32(&maxInFlight))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "Should parse seconds", value: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "Should parse HTTP date", value: "Sat, 01 May 2021 12:00:30 GMT", want: 30 * time.Second, wantOK: true},
+		{name: "Should not wait for past date", value: "Sat, 01 May 2021 11:00:00 GMT", wantOK: true},
+		{name: "Should skip empty value", value: ""},
+		{name: "Should skip negative seconds", value: "-1"},
+		{name: "Should skip invalid value", value: "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			require.Equal(t, tt.wantOK, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// RequestSender is implemented by HTTP clients which can send requests with extra headers.
+	// CachingHTTPClient uses it to revalidate cached responses.
+	RequestSender interface {
+		Send(ctx context.Context, method, url string, body []byte, header http.Header) (*http.Response, error)
+	}
+
+	// CachingHTTPClient is HTTPClient decorator which caches successful responses in storage.
+	// It honours Cache-Control, revalidates stale responses with If-None-Match and If-Modified-Since
+	// and serves stale response when upstream is down.
+	CachingHTTPClient struct {
+		client  HTTPClient
+		storage Storage
+		expire  time.Duration
+		now     func() time.Time
+	}
+
+	// cachedResponse is response stored in cache.
+	cachedResponse struct {
+		Header   http.Header `json:"header"`
+		Body     []byte      `json:"body"`
+		StoredAt time.Time   `json:"stored_at"`
+		MaxAge   int         `json:"max_age"`
+		NoCache  bool        `json:"no_cache,omitempty"`
+	}
+
+	cacheControl struct {
+		maxAge  int
+		noStore bool
+		noCache bool
+	}
+)
+
+// NewCachingHTTPClient returns caching decorator of client.
+// Responses are kept in storage for expire, so they can be revalidated or served when upstream is down.
+func NewCachingHTTPClient(client HTTPClient, storage Storage, expire time.Duration) *CachingHTTPClient {
+	return &CachingHTTPClient{
+		client:  client,
+		storage: storage,
+		expire:  expire,
+		now:     time.Now,
+	}
+}
+
+func (c *CachingHTTPClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, url, nil, http.Header{})
+}
+
+func (c *CachingHTTPClient) Post(ctx context.Context, url, contentType string, body io.Reader) (*http.Response, error) {
+	bodyBytes, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read request body failed: %s", err)
+	}
+
+	return c.do(ctx, http.MethodPost, url, bodyBytes, http.Header{"Content-Type": {contentType}})
+}
+
+func (c *CachingHTTPClient) do(ctx context.Context, method, url string, body []byte, header http.Header) (*http.Response, error) {
+	key := httpCacheKey(method, url, body)
+
+	cached, err := c.load(ctx, key)
+	if err != nil {
+		log.Printf("[ERROR] Load cached response failed: %s", err)
+	}
+
+	if cached != nil && c.fresh(cached) {
+		return cached.response(), nil
+	}
+
+	if cached != nil {
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			header.Set("If-None-Match", etag)
+		}
+
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	httpResponse, err := c.send(ctx, method, url, body, header)
+	if err != nil || httpResponse.StatusCode >= http.StatusInternalServerError || httpResponse.StatusCode == http.StatusTooManyRequests {
+		if cached == nil {
+			return httpResponse, err
+		}
+
+		if err == nil {
+			httpResponse.Body.Close()
+		}
+
+		log.Printf("[ERROR] Request %s failed, serving stale response: %s", url, responseError(httpResponse, err))
+
+		return cached.response(), nil
+	}
+
+	switch httpResponse.StatusCode {
+	case http.StatusNotModified:
+		httpResponse.Body.Close()
+
+		if cached == nil {
+			return httpResponse, nil
+		}
+
+		// Validators and freshness of 304 response replace cached ones.
+		for _, name := range []string{"Cache-Control", "ETag", "Last-Modified", "Expires"} {
+			if value := httpResponse.Header.Get(name); value != "" {
+				cached.Header.Set(name, value)
+			}
+		}
+
+		c.store(ctx, key, cached.Header, cached.Body)
+
+		return cached.response(), nil
+	case http.StatusOK:
+		defer httpResponse.Body.Close()
+
+		responseBody, err := io.ReadAll(httpResponse.Body)
+		if err != nil {
+			return nil, fmt.Errorf("read response body failed: %s", err)
+		}
+
+		c.store(ctx, key, httpResponse.Header, responseBody)
+
+		httpResponse.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+		return httpResponse, nil
+	}
+
+	return httpResponse, nil
+}
+
+// send sends request with conditional headers if decorated client supports them.
+func (c *CachingHTTPClient) send(ctx context.Context, method, url string, body []byte, header http.Header) (*http.Response, error) {
+	if sender, ok := c.client.(RequestSender); ok {
+		return sender.Send(ctx, method, url, body, header)
+	}
+
+	if method == http.MethodPost {
+		return c.client.Post(ctx, url, header.Get("Content-Type"), bytes.NewReader(body))
+	}
+
+	return c.client.Get(ctx, url)
+}
+
+// fresh returns true if cached response can be served without revalidation.
+func (c *CachingHTTPClient) fresh(cached *cachedResponse) bool {
+	if cached.NoCache || cached.MaxAge <= 0 {
+		return false
+	}
+
+	return c.now().Before(cached.StoredAt.Add(time.Duration(cached.MaxAge) * time.Second))
+}
+
+func (c *CachingHTTPClient) load(ctx context.Context, key string) (*cachedResponse, error) {
+	value, err := c.storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("get from storage failed: %s, key: %s", err, key)
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	var cached cachedResponse
+	if err := json.Unmarshal([]byte(value), &cached); err != nil {
+		return nil, fmt.Errorf("unmarshal cached response failed: %s, key: %s", err, key)
+	}
+
+	return &cached, nil
+}
+
+// store saves response unless Cache-Control forbids it.
+func (c *CachingHTTPClient) store(ctx context.Context, key string, header http.Header, body []byte) {
+	control := parseCacheControl(header.Get("Cache-Control"))
+	if control.noStore {
+		return
+	}
+
+	maxAge := control.maxAge
+	if maxAge < 0 {
+		maxAge = 0
+
+		if expires, err := http.ParseTime(header.Get("Expires")); err == nil && expires.After(c.now()) {
+			maxAge = int(expires.Sub(c.now()).Seconds())
+		}
+	}
+
+	cached := cachedResponse{
+		Header:   header.Clone(),
+		Body:     body,
+		StoredAt: c.now(),
+		MaxAge:   maxAge,
+		NoCache:  control.noCache,
+	}
+
+	cache, err := json.Marshal(cached)
+	if err != nil {
+		log.Printf("[ERROR] Marshal cached response failed: %s, key: %s", err, key)
+		return
+	}
+
+	if err := c.storage.Set(ctx, key, string(cache), c.expire); err != nil {
+		log.Printf("[ERROR] Set cached response failed: %s, key: %s", err, key)
+	}
+}
+
+// response returns new http response with cached body.
+func (cached *cachedResponse) response() *http.Response {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     cached.Header.Clone(),
+		Body:       io.NopCloser(bytes.NewReader(cached.Body)),
+	}
+}
+
+// httpCacheKey returns storage key of response to request with method, url and body.
+func httpCacheKey(method, url string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + url + "\n"))
+	hash.Write(body)
+
+	return "http:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// parseCacheControl parses Cache-Control header. Max age is -1 if it isn't set.
+func parseCacheControl(value string) cacheControl {
+	control := cacheControl{maxAge: -1}
+
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+
+		switch strings.ToLower(name) {
+		case "no-store":
+			control.noStore = true
+		case "no-cache":
+			control.noCache = true
+		case "max-age":
+			if maxAge, err := strconv.Atoi(strings.Trim(arg, `"`)); err == nil {
+				control.maxAge = maxAge
+			}
+		}
+	}
+
+	return control
+}
+
+func responseError(httpResponse *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("status %d", httpResponse.StatusCode)
+}
@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+func TestCachingHTTPClient(t *testing.T) {
+	type request struct {
+		// after is time passed since the first request.
+		after time.Duration
+		body  string
+		// status is returned by server if request reaches it.
+		status int
+		// wantHeader is conditional header sent to server.
+		wantHeader string
+		wantServer bool
+		wantBody   string
+	}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		requests []request
+	}{
+		{
+			name:   "Should serve fresh response and revalidate stale one with ETag",
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"v1"`}},
+			requests: []request{
+				{status: http.StatusOK, wantServer: true, wantBody: "posts"},
+				{after: 30 * time.Second, wantBody: "posts"},
+				{after: 90 * time.Second, status: http.StatusNotModified, wantHeader: `If-None-Match: "v1"`, wantServer: true, wantBody: "posts"},
+				{after: 120 * time.Second, wantBody: "posts"},
+			},
+		},
+		{
+			name:   "Should revalidate with Last-Modified",
+			header: http.Header{"Last-Modified": {"Sat, 01 May 2021 10:00:00 GMT"}},
+			requests: []request{
+				{status: http.StatusOK, wantServer: true, wantBody: "posts"},
+				{status: http.StatusNotModified, wantHeader: "If-Modified-Since: Sat, 01 May 2021 10:00:00 GMT", wantServer: true, wantBody: "posts"},
+			},
+		},
+		{
+			name:   "Should always revalidate no-cache response",
+			header: http.Header{"Cache-Control": {"no-cache, max-age=60"}, "Etag": {`"v1"`}},
+			requests: []request{
+				{status: http.StatusOK, wantServer: true, wantBody: "posts"},
+				{status: http.StatusNotModified, wantHeader: `If-None-Match: "v1"`, wantServer: true, wantBody: "posts"},
+			},
+		},
+		{
+			name:   "Should not store no-store response",
+			header: http.Header{"Cache-Control": {"no-store"}, "Etag": {`"v1"`}},
+			requests: []request{
+				{status: http.StatusOK, wantServer: true, wantBody: "posts"},
+				{status: http.StatusOK, wantServer: true, wantBody: "posts"},
+			},
+		},
+		{
+			name:   "Should serve stale response when upstream is down",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			requests: []request{
+				{status: http.StatusOK, wantServer: true, wantBody: "posts"},
+				{after: time.Hour, status: http.StatusBadGateway, wantServer: true, wantBody: "posts"},
+			},
+		},
+		{
+			name:   "Should cache requests with different bodies separately",
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+			requests: []request{
+				{body: "top", status: http.StatusOK, wantServer: true, wantBody: "top"},
+				{body: "new", status: http.StatusOK, wantServer: true, wantBody: "new"},
+				{body: "top", wantBody: "top"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				current request
+				reached bool
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+
+				var conditional []string
+				for _, name := range []string{"If-None-Match", "If-Modified-Since"} {
+					if value := r.Header.Get(name); value != "" {
+						conditional = append(conditional, name+": "+value)
+					}
+				}
+
+				require.Equal(t, current.wantHeader, strings.Join(conditional, ", "))
+
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				for name, values := range tt.header {
+					w.Header()[name] = values
+				}
+
+				w.WriteHeader(current.status)
+
+				if current.status == http.StatusOK {
+					if len(body) == 0 {
+						body = []byte("posts")
+					}
+
+					_, err = w.Write(body)
+					require.NoError(t, err)
+				}
+			}))
+			defer server.Close()
+
+			start := time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+			now := start
+
+			client, _ := newTestHTTPClient(HTTPClientOptions{MaxRetries: -1})
+			client.now = func() time.Time { return now }
+
+			cachingClient := NewCachingHTTPClient(client, memory.NewStorage(), time.Hour*24)
+			cachingClient.now = func() time.Time { return now }
+
+			for _, request := range tt.requests {
+				current, reached, now = request, false, start.Add(request.after)
+
+				var (
+					httpResponse *http.Response
+					err          error
+				)
+
+				if request.body != "" {
+					httpResponse, err = cachingClient.Post(context.TODO(), server.URL, "application/json", strings.NewReader(request.body))
+				} else {
+					httpResponse, err = cachingClient.Get(context.TODO(), server.URL)
+				}
+
+				require.NoError(t, err)
+				require.Equal(t, request.wantServer, reached)
+				require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+
+				body, err := io.ReadAll(httpResponse.Body)
+				require.NoError(t, err)
+				require.Equal(t, request.wantBody, string(body))
+			}
+		})
+	}
+}
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  cacheControl
+	}{
+		{name: "Should parse max age", value: "public, max-age=300", want: cacheControl{maxAge: 300}},
+		{name: "Should parse no-cache and no-store", value: "no-cache, No-Store", want: cacheControl{maxAge: -1, noCache: true, noStore: true}},
+		{name: "Should skip invalid max age", value: "max-age=soon", want: cacheControl{maxAge: -1}},
+		{name: "Should parse empty value", value: "", want: cacheControl{maxAge: -1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, parseCacheControl(tt.value))
+		})
+	}
+}
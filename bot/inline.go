@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+// InlineResults returns articles for inline query with posts matching query from all sources.
+// Offset is index of the first result, next offset is empty when there are no more results.
+func (b *Bot) InlineResults(ctx context.Context, query, offset string) ([]interface{}, string, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []interface{}{}, "", nil
+	}
+
+	start, err := strconv.Atoi(offset)
+	if err != nil || start < 0 {
+		start = 0
+	}
+
+	var results []searchResult
+
+	key := "inline:" + postKey(strings.ToLower(query))
+
+	if err := b.cached(ctx, key, &results, func() error {
+		results = b.search(ctx, query)
+		return nil
+	}); err != nil {
+		return nil, "", err
+	}
+
+	if start >= len(results) {
+		return []interface{}{}, "", nil
+	}
+
+	end := min(start+models.InlinePageSize, len(results))
+
+	articles := make([]interface{}, 0, end-start)
+
+	for _, result := range results[start:end] {
+		text := fmt.Sprintf("📝 %s (%s)\n\n%s", renderer.Link(result.URL, result.Title), renderer.Escape(result.Source), renderer.Escape(result.URL))
+
+		article := tgbotapi.NewInlineQueryResultArticleHTML(postKey(result.URL), result.Title, text)
+		article.URL = result.URL
+		article.Description = result.Source
+
+		if result.Snippet != "" {
+			article.Description += " · " + plainText(result.Snippet)
+		}
+
+		articles = append(articles, article)
+	}
+
+	var nextOffset string
+	if end < len(results) {
+		nextOffset = strconv.Itoa(end)
+	}
+
+	return articles, nextOffset, nil
+}
+
+// answerInlineQuery answers inline query with found posts.
+func (b *Bot) answerInlineQuery(ctx context.Context, query *tgbotapi.InlineQuery) error {
+	results, nextOffset, err := b.InlineResults(ctx, query.Query, query.Offset)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.botAPI.AnswerInlineQuery(tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     models.InlineCacheTime,
+		NextOffset:    nextOffset,
+	}); err != nil {
+		return fmt.Errorf("answer inline query failed: %s, query: %s", err, query.Query)
+	}
+
+	return nil
+}
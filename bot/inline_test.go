@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestInlineQuery(t *testing.T) {
+	httpClient := &mocks.HTTPClient{}
+
+	// Forum sources are searched once, then results are cached per query.
+	httpClient.On("Post", context.TODO(), mock.Anything, "application/json", mock.Anything).Return(
+		nil,
+		fmt.Errorf("forum is down"),
+	).Times(3)
+
+	telegram, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{BotAPI: botAPI, HTTPClient: httpClient})
+	require.NoError(t, err)
+
+	var posts []models.Post
+
+	for i := 1; i <= models.InlinePageSize+5; i++ {
+		posts = append(posts, models.Post{
+			Title: fmt.Sprintf("Meditations on Moloch %d", i),
+			URL:   fmt.Sprintf("https://slatestarcodex.com/moloch-%d/", i),
+		})
+	}
+
+	setPosts := func(name string, posts []models.Post) {
+		postsCache, err := json.Marshal(posts)
+		require.NoError(t, err)
+		require.NoError(t, tgbot.storage.Set(context.TODO(), "posts:"+name, string(postsCache), 0))
+	}
+
+	setPosts("slatestarcodex", posts)
+	setPosts("lesswrong.ru", []models.Post{{Title: "Размышления о Молохе", URL: "https://lesswrong.ru/w/moloch"}})
+	setPosts("astralcodexten", []models.Post{{Title: "Still Alive", URL: "https://astralcodexten.substack.com/p/still-alive"}})
+
+	inlineQuery := func(query, offset string) (tgbotapi.Message, error) {
+		return tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+			InlineQuery: &tgbotapi.InlineQuery{ID: "inline", Query: query, Offset: offset},
+		})
+	}
+
+	_, err = inlineQuery("Moloch meditations", "")
+	require.NoError(t, err)
+
+	answers := telegram.Sent("answerInlineQuery")
+	require.Len(t, answers, 1)
+	require.Equal(t, "inline", answers[0].Get("inline_query_id"))
+	require.Equal(t, "300", answers[0].Get("cache_time"))
+	require.Equal(t, "20", answers[0].Get("next_offset"))
+
+	var articles []tgbotapi.InlineQueryResultArticle
+	require.NoError(t, json.Unmarshal([]byte(answers[0].Get("results")), &articles))
+	require.Len(t, articles, models.InlinePageSize)
+
+	article := articles[0]
+	require.Equal(t, "article", article.Type)
+	require.Equal(t, postKey("https://slatestarcodex.com/moloch-1/"), article.ID)
+	require.Equal(t, "Meditations on Moloch 1", article.Title)
+	require.Equal(t, "https://slatestarcodex.com/moloch-1/", article.URL)
+	require.Equal(t, "Slate Star Codex", article.Description)
+	require.Equal(t, map[string]interface{}{
+		"message_text":             "📝 <a href=\"https://slatestarcodex.com/moloch-1/\">Meditations on Moloch 1</a> (Slate Star Codex)\n\nhttps://slatestarcodex.com/moloch-1/",
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": false,
+	}, article.InputMessageContent)
+
+	// The next page is taken from cache.
+	_, err = inlineQuery("moloch meditations ", "20")
+	require.NoError(t, err)
+
+	answers = telegram.Sent("answerInlineQuery")
+	require.Len(t, answers, 2)
+	require.Empty(t, answers[1].Get("next_offset"))
+	require.NoError(t, json.Unmarshal([]byte(answers[1].Get("results")), &articles))
+	require.Len(t, articles, 5)
+	require.Equal(t, "Meditations on Moloch 21", articles[0].Title)
+
+	_, err = inlineQuery(" ", "")
+	require.NoError(t, err)
+
+	answers = telegram.Sent("answerInlineQuery")
+	require.Equal(t, "[]", answers[2].Get("results"))
+
+	httpClient.AssertExpectations(t)
+}
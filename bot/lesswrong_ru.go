@@ -0,0 +1,230 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+// lesswrongRuPostDepth is the first depth of lesswrong.ru menu with posts, upper levels are site sections.
+const lesswrongRuPostDepth = 3
+
+type (
+	lesswrongRuSource struct {
+		bot *Bot
+	}
+
+	// menuItem is an item of lesswrong.ru menu. Items with children are sections, items without them are posts.
+	menuItem struct {
+		Title    string     `json:"title"`
+		URL      string     `json:"url"`
+		Depth    int        `json:"depth"`
+		Children []menuItem `json:"children,omitempty"`
+	}
+)
+
+func (s *lesswrongRuSource) ID() models.Source {
+	return models.SourceLesswrongRu
+}
+
+func (s *lesswrongRuSource) Name() string {
+	return "Lesswrong.ru"
+}
+
+func (s *lesswrongRuSource) Domain() string {
+	return models.DomainLesswrongRu
+}
+
+func (s *lesswrongRuSource) List(ctx context.Context) ([]models.Post, error) {
+	return s.bot.cachedPosts(ctx, "lesswrong.ru", func() ([]models.Post, error) {
+		menu, err := s.menu(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var posts []models.Post
+
+		var walk func(items []menuItem)
+		walk = func(items []menuItem) {
+			for _, item := range items {
+				if len(item.Children) > 0 {
+					walk(item.Children)
+				} else if item.Depth >= lesswrongRuPostDepth {
+					posts = append(posts, models.Post{Title: item.Title, URL: item.URL})
+				}
+			}
+		}
+
+		walk(menu)
+
+		return posts, nil
+	})
+}
+
+func (s *lesswrongRuSource) Top(ctx context.Context) (string, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	text := bytes.NewBufferString("🏆 Random posts from https://lesswrong.ru\n\n")
+
+	// As lesswrong.ru doesn't have page with top posts return random posts instead.
+	for i := 0; i < models.DefaultLimit; i++ {
+		n := s.bot.randomInt(len(posts))
+		post := posts[n]
+
+		text.WriteString(fmt.Sprintf("%d. %s\n\n", i+1, renderer.Link(post.URL, post.Title)))
+	}
+
+	return text.String(), nil
+}
+
+func (s *lesswrongRuSource) Random(ctx context.Context) (models.Post, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	i := s.bot.randomInt(len(posts))
+
+	return s.Fetch(ctx, posts[i])
+}
+
+func (s *lesswrongRuSource) Post(ctx context.Context, url string) (models.Post, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return s.Fetch(ctx, findPost(posts, url))
+}
+
+// Sequences returns sections of lesswrong.ru menu with their posts in menu order.
+// Nested sections of any depth are separate sequences.
+func (s *lesswrongRuSource) Sequences(ctx context.Context) ([]models.Sequence, error) {
+	menu, err := s.menu(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sequences := menuSequences(menu)
+
+	return sequences[:min(len(sequences), models.SequencesMaxCount)], nil
+}
+
+func (s *lesswrongRuSource) Sequence(ctx context.Context, id string) (models.Sequence, error) {
+	menu, err := s.menu(ctx)
+	if err != nil {
+		return models.Sequence{}, err
+	}
+
+	for _, sequence := range menuSequences(menu) {
+		if sequence.ID == id {
+			return sequence, nil
+		}
+	}
+
+	return models.Sequence{}, fmt.Errorf("lesswrong.ru sequence not found, id: %s", id)
+}
+
+// TextLink is true as lesswrong.ru urls are urlencoded cyrillic.
+func (s *lesswrongRuSource) TextLink() bool {
+	return true
+}
+
+// Fetch returns post from List with content.
+func (s *lesswrongRuSource) Fetch(_ context.Context, post models.Post) (models.Post, error) {
+	postCollector := s.bot.newCollector()
+
+	postCollector.OnHTML("div.tex2jax", func(e *colly.HTMLElement) {
+		post.HTML, _ = e.DOM.Html()
+	})
+
+	if err := postCollector.Visit(post.URL); err != nil {
+		return models.Post{}, fmt.Errorf("get lesswrong.ru post failed: %s", err)
+	}
+
+	return post, nil
+}
+
+// menu returns cached menu of lesswrong.ru which lists all posts.
+func (s *lesswrongRuSource) menu(ctx context.Context) ([]menuItem, error) {
+	var menu []menuItem
+
+	err := s.bot.cached(ctx, "menu:lesswrong.ru", &menu, func() error {
+		menuCollector := s.bot.newCollector()
+
+		menuCollector.OnHTML("body", func(e *colly.HTMLElement) {
+			menu = parseMenu(e.DOM.Find("li.menu-depth-1"), 1, e.Request.AbsoluteURL)
+		})
+
+		if err := menuCollector.Visit("https://lesswrong.ru/w"); err != nil {
+			return fmt.Errorf("get lesswrong.ru menu failed: %s", err)
+		}
+
+		if len(menu) == 0 {
+			return fmt.Errorf("lesswrong.ru menu not found")
+		}
+
+		return nil
+	})
+
+	return menu, err
+}
+
+// parseMenu parses menu items of depth and their nested items recursively.
+func parseMenu(items *goquery.Selection, depth int, absoluteURL func(string) string) []menuItem {
+	var menu []menuItem
+
+	items.Each(func(_ int, li *goquery.Selection) {
+		href, _ := li.ChildrenFiltered("a").First().Attr("href")
+
+		item := menuItem{
+			Title: strings.TrimSpace(li.ChildrenFiltered("a").First().Text()),
+			URL:   absoluteURL(href),
+			Depth: depth,
+		}
+
+		item.Children = parseMenu(li.ChildrenFiltered("ul").ChildrenFiltered("li"), depth+1, absoluteURL)
+
+		menu = append(menu, item)
+	})
+
+	return menu
+}
+
+// menuSequences returns sections of menu which have posts, sections are ordered as in menu with parents first.
+func menuSequences(menu []menuItem) []models.Sequence {
+	var sequences []models.Sequence
+
+	for _, item := range menu {
+		if len(item.Children) == 0 {
+			continue
+		}
+
+		// Titles of sections can repeat, so id is made of section url.
+		sequence := models.Sequence{ID: postKey(item.URL), Title: item.Title}
+
+		for _, child := range item.Children {
+			if len(child.Children) == 0 && child.Depth >= lesswrongRuPostDepth {
+				sequence.Posts = append(sequence.Posts, models.Post{Title: child.Title, URL: child.URL})
+			}
+		}
+
+		if len(sequence.Posts) > 0 {
+			sequences = append(sequences, sequence)
+		}
+
+		sequences = append(sequences, menuSequences(item.Children)...)
+	}
+
+	return sequences
+}
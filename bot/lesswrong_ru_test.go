@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestLesswrongRuMenu(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<ul class="menu">
+		<li class="expanded menu-depth-1"><a href="/w">Вики</a><ul class="menu">
+			<li class="leaf menu-depth-2"><a href="/w/about">О сайте</a></li>
+			<li class="expanded menu-depth-2"><a href="/w/az">Рациональность: от ИИ до Зомби</a><ul class="menu">
+				<li class="expanded menu-depth-3"><a href="/w/map">Карта и территория</a><ul class="menu">
+					<li class="leaf menu-depth-4"><a href="/w/map/1">Предсказуемо неправы</a></li>
+					<li class="expanded menu-depth-4"><a href="/w/map/fake">Фальшивые убеждения</a><ul class="menu">
+						<li class="leaf menu-depth-5"><a href="/w/map/fake/1">Делай убеждения</a></li>
+					</ul></li>
+					<li class="leaf menu-depth-4"><a href="/w/map/2">Тайна и загадка</a></li>
+				</ul></li>
+				<li class="leaf menu-depth-3"><a href="/w/az/end">Послесловие</a></li>
+			</ul></li>
+		</ul></li>
+	</ul>`))
+	require.NoError(t, err)
+
+	menu := parseMenu(doc.Find("li.menu-depth-1"), 1, func(href string) string { return "https://lesswrong.ru" + href })
+
+	require.Equal(t, []models.Sequence{
+		{
+			ID:    postKey("https://lesswrong.ru/w/az"),
+			Title: "Рациональность: от ИИ до Зомби",
+			Posts: []models.Post{{Title: "Послесловие", URL: "https://lesswrong.ru/w/az/end"}},
+		},
+		{
+			ID:    postKey("https://lesswrong.ru/w/map"),
+			Title: "Карта и территория",
+			Posts: []models.Post{
+				{Title: "Предсказуемо неправы", URL: "https://lesswrong.ru/w/map/1"},
+				{Title: "Тайна и загадка", URL: "https://lesswrong.ru/w/map/2"},
+			},
+		},
+		{
+			ID:    postKey("https://lesswrong.ru/w/map/fake"),
+			Title: "Фальшивые убеждения",
+			Posts: []models.Post{{Title: "Делай убеждения", URL: "https://lesswrong.ru/w/map/fake/1"}},
+		},
+	}, menuSequences(menu))
+
+	// Menu is parsed once and cached, so list and sequences don't scrape it again.
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}})
+	require.NoError(t, err)
+
+	menuCache, err := json.Marshal(menu)
+	require.NoError(t, err)
+	require.NoError(t, tgbot.storage.Set(context.TODO(), "menu:lesswrong.ru", string(menuCache), 0))
+
+	source := &lesswrongRuSource{bot: tgbot}
+
+	posts, err := source.List(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, []models.Post{
+		{Title: "Предсказуемо неправы", URL: "https://lesswrong.ru/w/map/1"},
+		{Title: "Делай убеждения", URL: "https://lesswrong.ru/w/map/fake/1"},
+		{Title: "Тайна и загадка", URL: "https://lesswrong.ru/w/map/2"},
+		{Title: "Послесловие", URL: "https://lesswrong.ru/w/az/end"},
+	}, posts)
+
+	sequence, err := source.Sequence(context.TODO(), postKey("https://lesswrong.ru/w/map/fake"))
+	require.NoError(t, err)
+	require.Equal(t, "Фальшивые убеждения", sequence.Title)
+
+	_, err = source.Sequence(context.TODO(), "missing")
+	require.EqualError(t, err, "lesswrong.ru sequence not found, id: missing")
+
+	// Sections with the same title have different ids.
+	duplicates := menuSequences([]menuItem{
+		{Title: "Введение", URL: "https://lesswrong.ru/w/intro-1", Depth: 2, Children: []menuItem{{Title: "Первый", URL: "https://lesswrong.ru/w/1", Depth: 3}}},
+		{Title: "Введение", URL: "https://lesswrong.ru/w/intro-2", Depth: 2, Children: []menuItem{{Title: "Второй", URL: "https://lesswrong.ru/w/2", Depth: 3}}},
+	})
+	require.Len(t, duplicates, 2)
+	require.NotEqual(t, duplicates[0].ID, duplicates[1].ID)
+}
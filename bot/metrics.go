@@ -0,0 +1,261 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// knownCommands are commands counted by name. Other text sent to bot is counted as unknown command,
+// so users can't create unlimited number of time series.
+var knownCommands = map[string]bool{
+	"start":       true,
+	"help":        true,
+	"top":         true,
+	"random":      true,
+	"instantview": true,
+	"read":        true,
+	"search":      true,
+	"sequence":    true,
+	"next":        true,
+	"course":      true,
+	"history":     true,
+	"saved":       true,
+	"source":      true,
+	"subscribe":   true,
+	"digest":      true,
+	"autopost":    true,
+	"unsubscribe": true,
+}
+
+type (
+	// Metrics are Prometheus metrics of bot. Each Metrics has its own registry served by Handler.
+	Metrics struct {
+		registry         *prometheus.Registry
+		commands         *prometheus.CounterVec
+		upstreamDuration *prometheus.HistogramVec
+		upstreamRequests *prometheus.CounterVec
+		postsCache       *prometheus.CounterVec
+		telegramErrors   *prometheus.CounterVec
+		rateLimited      *prometheus.CounterVec
+	}
+
+	// InstrumentedStorage is Storage decorator which counts cache hits and misses of "posts:*" keys.
+	InstrumentedStorage struct {
+		Storage
+		metrics *Metrics
+	}
+
+	// upstreamTransport measures latency and counts responses of each request to sources per domain.
+	// It's used by retrying HTTP client and scrapers, so every retry is observed.
+	upstreamTransport struct {
+		transport http.RoundTripper
+		metrics   *Metrics
+	}
+
+	// telegramTransport counts failed Telegram Bot API requests.
+	telegramTransport struct {
+		transport http.RoundTripper
+		metrics   *Metrics
+	}
+)
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lesswrong_bot_commands_total",
+			Help: "Commands received by bot.",
+		}, []string{"command", "source"}),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lesswrong_bot_upstream_request_duration_seconds",
+			Help:    "Latency of each request attempt to sources.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"domain"}),
+		upstreamRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lesswrong_bot_upstream_requests_total",
+			Help: "Request attempts to sources by response status code, error if request failed.",
+		}, []string{"domain", "code"}),
+		postsCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lesswrong_bot_posts_cache_requests_total",
+			Help: "Lookups of cached posts by result: hit or miss.",
+		}, []string{"name", "result"}),
+		telegramErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lesswrong_bot_telegram_errors_total",
+			Help: "Failed Telegram Bot API requests by method and status code.",
+		}, []string{"method", "code"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lesswrong_bot_rate_limited_total",
+			Help: "429 Too Many Requests responses by domain of source or telegram.",
+		}, []string{"domain"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.commands,
+		m.upstreamDuration,
+		m.upstreamRequests,
+		m.postsCache,
+		m.telegramErrors,
+		m.rateLimited,
+	)
+
+	return m
+}
+
+// Handler returns HTTP handler serving metrics in Prometheus format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveDispatcher exports stats of dispatcher. It must be called once for metrics.
+func (m *Metrics) ObserveDispatcher(d *Dispatcher) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "lesswrong_bot_updates_queued",
+			Help: "Updates waiting for a worker.",
+		}, func() float64 { return float64(d.Stats().Queued) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "lesswrong_bot_updates_processed_total",
+			Help: "Updates handled by workers.",
+		}, func() float64 { return float64(d.Stats().Processed) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "lesswrong_bot_updates_blocked_total",
+			Help: "Updates which waited for free space in full queue.",
+		}, func() float64 { return float64(d.Stats().Blocked) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "lesswrong_bot_updates_blocked_seconds_total",
+			Help: "Time spent waiting for free space in full queues.",
+		}, func() float64 { return d.Stats().Waited.Seconds() }),
+	)
+}
+
+// ObserveCommand counts command run with source.
+func (m *Metrics) ObserveCommand(command, source string) {
+	if !knownCommands[command] {
+		command = "unknown"
+	}
+
+	m.commands.WithLabelValues(command, source).Inc()
+}
+
+func (m *Metrics) observeUpstream(domain string, duration time.Duration, statusCode int, err error) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(statusCode)
+	}
+
+	m.upstreamDuration.WithLabelValues(domain).Observe(duration.Seconds())
+	m.upstreamRequests.WithLabelValues(domain, code).Inc()
+
+	if err == nil && statusCode == http.StatusTooManyRequests {
+		m.rateLimited.WithLabelValues(domain).Inc()
+	}
+}
+
+// instrumentUpstream returns transport which observes requests to sources.
+func instrumentUpstream(transport http.RoundTripper, metrics *Metrics) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &upstreamTransport{transport: transport, metrics: metrics}
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	httpResponse, err := t.transport.RoundTrip(req)
+
+	statusCode := 0
+	if httpResponse != nil {
+		statusCode = httpResponse.StatusCode
+	}
+
+	t.metrics.observeUpstream(requestDomain(req.URL.String()), time.Since(start), statusCode, err)
+
+	return httpResponse, err
+}
+
+// NewInstrumentedStorage returns instrumented decorator of storage.
+func NewInstrumentedStorage(storage Storage, metrics *Metrics) *InstrumentedStorage {
+	return &InstrumentedStorage{
+		Storage: storage,
+		metrics: metrics,
+	}
+}
+
+func (s *InstrumentedStorage) Get(ctx context.Context, key string) (string, error) {
+	value, err := s.Storage.Get(ctx, key)
+
+	if name := strings.TrimPrefix(key, "posts:"); name != key && err == nil {
+		result := "hit"
+		if value == "" {
+			result = "miss"
+		}
+
+		s.metrics.postsCache.WithLabelValues(name, result).Inc()
+	}
+
+	return value, err
+}
+
+// instrumentTelegram makes client count failed requests. Client is copied as it may be shared.
+func instrumentTelegram(client *http.Client, metrics *Metrics) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	instrumented := *client
+
+	transport := instrumented.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// Client of bot created again with the same BotAPI isn't instrumented twice.
+	if instrumentedTransport, ok := transport.(*telegramTransport); ok {
+		transport = instrumentedTransport.transport
+	}
+
+	instrumented.Transport = &telegramTransport{transport: transport, metrics: metrics}
+
+	return &instrumented
+}
+
+func (t *telegramTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	httpResponse, err := t.transport.RoundTrip(req)
+
+	// Path is /bot<token>/<method>, so only method is used as label.
+	method := path.Base(req.URL.Path)
+
+	switch {
+	case err != nil:
+		t.metrics.telegramErrors.WithLabelValues(method, "error").Inc()
+	case httpResponse.StatusCode >= http.StatusBadRequest:
+		t.metrics.telegramErrors.WithLabelValues(method, strconv.Itoa(httpResponse.StatusCode)).Inc()
+
+		if httpResponse.StatusCode == http.StatusTooManyRequests {
+			t.metrics.rateLimited.WithLabelValues("telegram").Inc()
+		}
+	}
+
+	return httpResponse, err
+}
+
+func requestDomain(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+
+	return u.Hostname()
+}
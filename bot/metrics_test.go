@@ -0,0 +1,236 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestMetricsCommands(t *testing.T) {
+	_, botAPI := newTelegramStub(t)
+	metrics := NewMetrics()
+
+	tgbot, err := New(Options{
+		BotAPI:     botAPI,
+		HTTPClient: &mocks.HTTPClient{},
+		Storage:    memory.NewStorage(),
+		Metrics:    metrics,
+	})
+	require.NoError(t, err)
+
+	for _, text := range []string{"/help", "/source 3", "/help", "/foo", "hello"} {
+		update := newChatUpdate(1, text)
+
+		if command, _, _ := strings.Cut(text, " "); strings.HasPrefix(command, "/") {
+			update.Message.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len(command)}}
+		}
+
+		_, err := tgbot.MessageHandler(context.TODO(), update)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		command string
+		source  string
+		want    float64
+	}{
+		{command: "help", source: "Lesswrong.ru", want: 1},
+		{command: "help", source: "Astral Codex Ten", want: 1},
+		{command: "source", source: "Lesswrong.ru", want: 1},
+		{command: "unknown", source: "Astral Codex Ten", want: 2},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, testutil.ToFloat64(metrics.commands.WithLabelValues(tt.command, tt.source)), "%s %s", tt.command, tt.source)
+	}
+}
+
+func TestUpstreamMetrics(t *testing.T) {
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/retry":
+			attempts++
+			if attempts == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		fmt.Fprint(w, "<html><body>ok</body></html>")
+	}))
+	defer server.Close()
+
+	metrics := NewMetrics()
+
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}, Metrics: metrics})
+	require.NoError(t, err)
+
+	// Each attempt of retrying client is observed.
+	client := NewHTTPClientWithOptions(HTTPClientOptions{
+		Client:     &http.Client{Transport: tgbot.transport},
+		BaseDelay:  time.Millisecond,
+		MaxRetries: -1,
+	})
+
+	httpResponse, err := client.Get(context.TODO(), server.URL+"/limited")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusTooManyRequests, httpResponse.StatusCode)
+
+	client = NewHTTPClientWithOptions(HTTPClientOptions{Client: &http.Client{Transport: tgbot.transport}, BaseDelay: time.Millisecond})
+
+	httpResponse, err = client.Get(context.TODO(), server.URL+"/retry")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, httpResponse.StatusCode)
+
+	// Scrapers are observed too.
+	require.NoError(t, tgbot.newCollector().Visit(server.URL+"/page"))
+
+	_, err = client.Get(context.TODO(), "http://127.0.0.1:0/closed")
+	require.Error(t, err)
+
+	tests := []struct {
+		code string
+		want float64
+	}{
+		{code: "200", want: 2},
+		{code: "503", want: 1},
+		{code: "429", want: 1},
+		{code: "error", want: 4},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, testutil.ToFloat64(metrics.upstreamRequests.WithLabelValues("127.0.0.1", tt.code)), tt.code)
+	}
+
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.rateLimited.WithLabelValues("127.0.0.1")))
+	require.Equal(t, 1, testutil.CollectAndCount(metrics.upstreamDuration))
+}
+
+func TestInstrumentedStorage(t *testing.T) {
+	ctx := context.TODO()
+	metrics := NewMetrics()
+	storage := NewInstrumentedStorage(memory.NewStorage(), metrics)
+
+	value, err := storage.Get(ctx, "posts:slatestarcodex")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	require.NoError(t, storage.Set(ctx, "posts:slatestarcodex", "[]", 0))
+	require.NoError(t, storage.Set(ctx, "source:1", "2", 0))
+
+	for _, key := range []string{"posts:slatestarcodex", "posts:slatestarcodex", "source:1"} {
+		_, err := storage.Get(ctx, key)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.postsCache.WithLabelValues("slatestarcodex", "miss")))
+	require.Equal(t, float64(2), testutil.ToFloat64(metrics.postsCache.WithLabelValues("slatestarcodex", "hit")))
+	require.Equal(t, 2, testutil.CollectAndCount(metrics.postsCache))
+}
+
+func TestTelegramMetrics(t *testing.T) {
+	statusCodes := map[string]int{
+		"sendMessage":         http.StatusTooManyRequests,
+		"getChat":             http.StatusBadRequest,
+		"answerCallbackQuery": http.StatusOK,
+	}
+
+	metrics := NewMetrics()
+	client := instrumentTelegram(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		statusCode, ok := statusCodes[req.URL.Path[len("/bottoken/"):]]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+
+		return &http.Response{
+			StatusCode: statusCode,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 5"}`)),
+		}, nil
+	})}, metrics)
+
+	botAPI := &tgbotapi.BotAPI{Token: "token", Client: client}
+
+	_, err := botAPI.Send(tgbotapi.NewMessage(1, "text"))
+	require.Error(t, err)
+
+	_, err = botAPI.GetChat(tgbotapi.ChatConfig{ChatID: 1})
+	require.Error(t, err)
+
+	_, err = botAPI.AnswerCallbackQuery(tgbotapi.NewCallback("1", ""))
+	require.Error(t, err)
+
+	_, err = botAPI.GetMe()
+	require.Error(t, err)
+
+	tests := []struct {
+		method string
+		code   string
+		want   float64
+	}{
+		{method: "sendMessage", code: "429", want: 1},
+		{method: "getChat", code: "400", want: 1},
+		{method: "getMe", code: "error", want: 1},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, testutil.ToFloat64(metrics.telegramErrors.WithLabelValues(tt.method, tt.code)), "%s %s", tt.method, tt.code)
+	}
+
+	require.Equal(t, 3, testutil.CollectAndCount(metrics.telegramErrors))
+	require.Equal(t, float64(1), testutil.ToFloat64(metrics.rateLimited.WithLabelValues("telegram")))
+}
+
+func TestMetricsDispatcher(t *testing.T) {
+	metrics := NewMetrics()
+	dispatcher := NewDispatcher(1, 1, func(context.Context, tgbotapi.Update) {})
+	metrics.ObserveDispatcher(dispatcher)
+
+	require.NoError(t, dispatcher.Dispatch(context.TODO(), newChatUpdate(1, "/help")))
+
+	dispatcher.Start(context.TODO())
+	dispatcher.Stop()
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Contains(t, recorder.Body.String(), "lesswrong_bot_updates_queued 0")
+	require.Contains(t, recorder.Body.String(), "lesswrong_bot_updates_processed_total 1")
+	require.Contains(t, recorder.Body.String(), "lesswrong_bot_updates_blocked_total 0")
+}
+
+func TestMetricsHandler(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.ObserveCommand("top", "Lesswrong.ru")
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `lesswrong_bot_commands_total{command="top",source="Lesswrong.ru"} 1`)
+	require.Contains(t, recorder.Body.String(), "go_goroutines")
+}
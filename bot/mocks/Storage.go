@@ -14,6 +14,41 @@ type Storage struct {
 	mock.Mock
 }
 
+// Del provides a mock function with given fields: ctx, key
+func (_m *Storage) Del(ctx context.Context, key string) error {
+	ret := _m.Called(ctx, key)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Exists provides a mock function with given fields: ctx, key
+func (_m *Storage) Exists(ctx context.Context, key string) (bool, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string) bool); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Get provides a mock function with given fields: ctx, key
 func (_m *Storage) Get(ctx context.Context, key string) (string, error) {
 	ret := _m.Called(ctx, key)
@@ -35,6 +70,271 @@ func (_m *Storage) Get(ctx context.Context, key string) (string, error) {
 	return r0, r1
 }
 
+// HDel provides a mock function with given fields: ctx, key, fields
+func (_m *Storage) HDel(ctx context.Context, key string, fields ...string) error {
+	_va := make([]interface{}, len(fields))
+	for _i := range fields {
+		_va[_i] = fields[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, key)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) error); ok {
+		r0 = rf(ctx, key, fields...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// HGet provides a mock function with given fields: ctx, key, field
+func (_m *Storage) HGet(ctx context.Context, key string, field string) (string, error) {
+	ret := _m.Called(ctx, key, field)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) string); ok {
+		r0 = rf(ctx, key, field)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, key, field)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HGetAll provides a mock function with given fields: ctx, key
+func (_m *Storage) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 map[string]string
+	if rf, ok := ret.Get(0).(func(context.Context, string) map[string]string); ok {
+		r0 = rf(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// HSet provides a mock function with given fields: ctx, key, field, value
+func (_m *Storage) HSet(ctx context.Context, key string, field string, value string) error {
+	ret := _m.Called(ctx, key, field, value)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, string) error); ok {
+		r0 = rf(ctx, key, field, value)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Incr provides a mock function with given fields: ctx, key
+func (_m *Storage) Incr(ctx context.Context, key string) (int64, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, string) int64); ok {
+		r0 = rf(ctx, key)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LPush provides a mock function with given fields: ctx, key, values
+func (_m *Storage) LPush(ctx context.Context, key string, values ...string) error {
+	_va := make([]interface{}, len(values))
+	for _i := range values {
+		_va[_i] = values[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, key)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) error); ok {
+		r0 = rf(ctx, key, values...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// LRange provides a mock function with given fields: ctx, key, start, stop
+func (_m *Storage) LRange(ctx context.Context, key string, start int64, stop int64) ([]string, error) {
+	ret := _m.Called(ctx, key, start, stop)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, int64) []string); ok {
+		r0 = rf(ctx, key, start, stop)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, int64, int64) error); ok {
+		r1 = rf(ctx, key, start, stop)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LTrim provides a mock function with given fields: ctx, key, start, stop
+func (_m *Storage) LTrim(ctx context.Context, key string, start int64, stop int64) error {
+	ret := _m.Called(ctx, key, start, stop)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64, int64) error); ok {
+		r0 = rf(ctx, key, start, stop)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RPush provides a mock function with given fields: ctx, key, values
+func (_m *Storage) RPush(ctx context.Context, key string, values ...string) error {
+	_va := make([]interface{}, len(values))
+	for _i := range values {
+		_va[_i] = values[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, key)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) error); ok {
+		r0 = rf(ctx, key, values...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SAdd provides a mock function with given fields: ctx, key, members
+func (_m *Storage) SAdd(ctx context.Context, key string, members ...string) error {
+	_va := make([]interface{}, len(members))
+	for _i := range members {
+		_va[_i] = members[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, key)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) error); ok {
+		r0 = rf(ctx, key, members...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SIsMember provides a mock function with given fields: ctx, key, member
+func (_m *Storage) SIsMember(ctx context.Context, key string, member string) (bool, error) {
+	ret := _m.Called(ctx, key, member)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = rf(ctx, key, member)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, key, member)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SMembers provides a mock function with given fields: ctx, key
+func (_m *Storage) SMembers(ctx context.Context, key string) ([]string, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(context.Context, string) []string); ok {
+		r0 = rf(ctx, key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SRem provides a mock function with given fields: ctx, key, members
+func (_m *Storage) SRem(ctx context.Context, key string, members ...string) error {
+	_va := make([]interface{}, len(members))
+	for _i := range members {
+		_va[_i] = members[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, key)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, ...string) error); ok {
+		r0 = rf(ctx, key, members...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Set provides a mock function with given fields: ctx, key, value, expire
 func (_m *Storage) Set(ctx context.Context, key string, value string, expire time.Duration) error {
 	ret := _m.Called(ctx, key, value, expire)
@@ -48,3 +348,24 @@ func (_m *Storage) Set(ctx context.Context, key string, value string, expire tim
 
 	return r0
 }
+
+// SetNX provides a mock function with given fields: ctx, key, value, expire
+func (_m *Storage) SetNX(ctx context.Context, key string, value string, expire time.Duration) (bool, error) {
+	ret := _m.Called(ctx, key, value, expire)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Duration) bool); ok {
+		r0 = rf(ctx, key, value, expire)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, time.Duration) error); ok {
+		r1 = rf(ctx, key, value, expire)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -1,326 +1,203 @@
 package bot
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
 
-	md "github.com/JohannesKaufmann/html-to-markdown"
-	"github.com/gocolly/colly"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 
 	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
 )
 
-func (b *Bot) RandomPost(ctx context.Context, userID int) (string, error) {
-	key := fmt.Sprintf("source:%d", userID)
+// randomRetries is how many times random post is picked again if it was already served.
+const randomRetries = 3
 
-	source, err := b.storage.Get(ctx, key)
-	if err != nil {
-		log.Printf("[ERROR] Get source failed: %s, key: %s", err, key)
-	}
+// resetCallbackPrefix marks callback data of reset history button in format reset:<source id>.
+const resetCallbackPrefix = "reset:"
 
-	switch models.Source(source) {
-	case models.SourceLesswrongRu:
-		return b.randomLesswrongRu(ctx)
-	case models.SourceSlate:
-		return b.randomSlate(ctx)
-	case models.SourceAstral:
-		return b.randomAstral(ctx)
-	case models.SourceLesswrong:
-		return b.randomLesswrong(ctx)
-	default:
-		return b.randomLesswrongRu(ctx)
-	}
+// Catalog is implemented by sources which List returns all posts, so random post can be picked from it.
+type Catalog interface {
+	// Fetch returns post from List with content.
+	Fetch(ctx context.Context, post models.Post) (models.Post, error)
 }
 
-func (b *Bot) randomSlate(ctx context.Context) (string, error) {
-	postsCached, err := b.storage.Get(ctx, "posts:slatestarcodex")
+func (b *Bot) RandomPost(ctx context.Context, userID int) (string, interface{}, error) {
+	source := b.userSource(ctx, userID)
+
+	post, ok, err := b.unseenPost(ctx, userID, source)
 	if err != nil {
-		return "", fmt.Errorf("get slatestarcodex cached posts failed: %s", err)
+		return "", nil, err
 	}
 
-	var posts []models.Post
+	if !ok {
+		text := fmt.Sprintf("🎉 You've read all posts from %s. Reset history to read them again?", renderer.Escape(sourceURL(source)))
 
-	if postsCached != "" {
-		if err := json.Unmarshal([]byte(postsCached), &posts); err != nil {
-			return "", fmt.Errorf("unmarshal slatestarcodex cached posts failed: %s", err)
-		}
-	}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Reset history", resetCallbackPrefix+source.ID().Value()),
+		))
 
-	// Load posts for the first time.
-	if len(posts) == 0 {
-		archivesCollector := colly.NewCollector()
+		return text, keyboard, nil
+	}
 
-		archivesCollector.OnHTML("a[href][rel=bookmark]", func(e *colly.HTMLElement) {
-			posts = append(posts, models.Post{
-				Title: e.Text,
-				URL:   e.Attr("href"),
-			})
-		})
+	if err := b.addHistory(ctx, userID, source, post); err != nil {
+		return "", nil, err
+	}
 
-		if err := archivesCollector.Visit("https://slatestarcodex.com/archives/"); err != nil {
-			return "", fmt.Errorf("get slatestarcodex posts failed: %s", err)
-		}
+	var text string
 
-		postsCache, err := json.Marshal(posts)
+	if b.instantViewEnabled(ctx, userID) {
+		text, err = b.instantViewPost(ctx, source, post)
 		if err != nil {
-			return "", fmt.Errorf("marshal slatestarcodex posts failed: %s", err)
+			log.Printf("[ERROR] Publish post to telegraph failed: %s", err)
 		}
+	}
 
-		if err := b.storage.Set(ctx, "posts:slatestarcodex", string(postsCache), b.config.CacheExpire); err != nil {
-			return "", fmt.Errorf("cache slatestarcodex posts failed: %s", err)
+	if text == "" {
+		text, err = b.previewPost(source, post)
+		if err != nil {
+			return "", nil, err
 		}
 	}
 
-	if len(posts) == 0 {
-		return "", fmt.Errorf("slatestarcodex posts not found")
+	keyboard, err := b.postKeyboard(ctx, post)
+	if err != nil {
+		return "", nil, err
 	}
 
-	i := b.randomInt(len(posts))
-	post := posts[i]
-
-	postCollector := colly.NewCollector()
+	return text, keyboard, nil
+}
 
-	postCollector.OnHTML("div.pjgm-postcontent", func(e *colly.HTMLElement) {
-		post.HTML, _ = e.DOM.Html()
-	})
+// ResetHistory forgets posts of source served to user, so they can be picked by /random again.
+func (b *Bot) ResetHistory(ctx context.Context, userID int, sourceID models.Source) (string, error) {
+	source, ok := b.sources.Get(sourceID)
+	if !ok {
+		return "Source is invalid", nil
+	}
 
-	if err := postCollector.Visit(post.URL); err != nil {
-		return "", fmt.Errorf("get slatestarcodex random post failed: %s", err)
+	if err := b.storage.Del(ctx, servedKey(userID, source)); err != nil {
+		return "", fmt.Errorf("delete served posts failed: %s", err)
 	}
 
-	return b.postToMarkdown(post, md.NewConverter(models.DomainSlate, true, nil), false)
+	return "History of " + renderer.Escape(sourceURL(source)) + " is reset. Send /random to read a post", nil
 }
 
-func (b *Bot) randomAstral(ctx context.Context) (string, error) {
-	postsCached, err := b.storage.Get(ctx, "posts:astralcodexten")
-	if err != nil {
-		return "", fmt.Errorf("get astralcodexten cached posts failed: %s", err)
-	}
-
-	var posts []models.Post
+// unseenPost returns random post which wasn't served to user. Returns false if user has read all posts of catalog.
+// Sources without catalog can't be exhausted, so post is picked again few times if it was served.
+func (b *Bot) unseenPost(ctx context.Context, userID int, source Source) (models.Post, bool, error) {
+	key := servedKey(userID, source)
 
-	if postsCached != "" {
-		if err := json.Unmarshal([]byte(postsCached), &posts); err != nil {
-			return "", fmt.Errorf("unmarshal astralcodexten cached posts failed: %s", err)
+	catalog, ok := source.(Catalog)
+	if !ok {
+		served, err := b.storage.LRange(ctx, key, 0, -1)
+		if err != nil {
+			return models.Post{}, false, fmt.Errorf("get served posts failed: %s", err)
 		}
-	}
 
-	// Load posts for the first time.
-	if len(posts) == 0 {
-		// As substack limits list to 12 posts in one request we fetch all posts using offset.
-		for offset := 0; true; offset += models.DefaultLimit {
-			uri := fmt.Sprintf("https://astralcodexten.substack.com/api/v1/archive?sort=new&limit=%d&offset=%d",
-				models.DefaultLimit,
-				offset,
-			)
+		servedURLs := urlSet(served)
 
-			httpResponse, err := b.httpClient.Get(ctx, uri)
-			if err != nil {
-				log.Printf("[ERROR] Get astralcodexten posts failed: %s", err)
-				break
-			}
+		var post models.Post
 
-			var newPosts []models.AstralPost
+		for i := 0; i < randomRetries; i++ {
+			var err error
 
-			if err := b.handleResponse(httpResponse, &newPosts); err != nil {
-				log.Printf("[ERROR] handle astralcodexten posts response: %s", err)
-				// If rate limited and we have no posts yet, return a helpful error
-				if httpResponse.StatusCode == 429 && len(posts) == 0 {
-					return "", fmt.Errorf("astralcodexten API is temporarily rate limited, please try again later")
-				}
-				break
+			post, err = source.Random(ctx)
+			if err != nil {
+				return models.Post{}, false, err
 			}
 
-			if len(newPosts) == 0 {
+			if !servedURLs[post.URL] {
 				break
 			}
-
-			for _, astralPost := range newPosts {
-				if astralPost.Audience != "only_paid" {
-					posts = append(posts, astralPost.AsPost())
-				}
-			}
 		}
 
-		postsCache, err := json.Marshal(posts)
-		if err != nil {
-			return "", fmt.Errorf("marshal astralcodexten posts failed: %s", err)
-		}
-
-		if err := b.storage.Set(ctx, "posts:astralcodexten", string(postsCache), b.config.CacheExpire); err != nil {
-			return "", fmt.Errorf("cache astralcodexten posts failed: %s", err)
-		}
+		return post, true, nil
 	}
 
-	if len(posts) == 0 {
-		return "", fmt.Errorf("astralcodexten posts not found")
-	}
-
-	i := b.randomInt(len(posts))
-	post := posts[i]
-
-	httpResponse, err := b.httpClient.Get(ctx, "https://astralcodexten.substack.com/api/v1/posts/"+post.Slug)
+	posts, err := source.List(ctx)
 	if err != nil {
-		return "", fmt.Errorf("get astralcodexten random post failed: %s", err)
+		return models.Post{}, false, err
 	}
 
-	var astralPost models.AstralPost
-
-	if err := b.handleResponse(httpResponse, &astralPost); err != nil {
-		// Handle rate limiting gracefully - return a basic post with available info
-		if httpResponse.StatusCode == 429 {
-			fallbackPost := models.Post{
-				Title: post.Title,
-				URL:   post.URL,
-				HTML:  "<p>Content temporarily unavailable due to API rate limiting. Please visit the link above to read the full post.</p>",
-			}
-			return b.postToMarkdown(fallbackPost, md.NewConverter(models.DomainAstral, true, nil), false)
-		}
-		return "", fmt.Errorf("handle astralcodexten post response: %s", err)
-	}
-
-	return b.postToMarkdown(astralPost.AsPost(), md.NewConverter(models.DomainAstral, true, nil), false)
-}
-
-func (b *Bot) randomLesswrongRu(ctx context.Context) (string, error) {
-	postsCached, err := b.storage.Get(ctx, "posts:lesswrong.ru")
+	served, err := b.storage.SMembers(ctx, key)
 	if err != nil {
-		return "", fmt.Errorf("get lesswrong.ru cached posts failed: %s", err)
+		return models.Post{}, false, fmt.Errorf("get served posts failed: %s", err)
 	}
 
-	var posts []models.Post
-
-	if postsCached != "" {
-		if err := json.Unmarshal([]byte(postsCached), &posts); err != nil {
-			return "", fmt.Errorf("unmarshal lesswrong.ru cached posts failed: %s", err)
-		}
-	}
+	servedURLs := urlSet(served)
 
-	// Load posts for the first time.
-	if len(posts) == 0 {
-		postsCollector := colly.NewCollector()
+	var unseen []models.Post
 
-		postsCollector.OnHTML("li.leaf.menu-depth-3,li.leaf.menu-depth-4", func(e *colly.HTMLElement) {
-			posts = append(posts, models.Post{
-				Title: e.Text,
-				URL:   e.Request.AbsoluteURL(e.ChildAttr("a", "href")),
-			})
-		})
-
-		if err := postsCollector.Visit("https://lesswrong.ru/w"); err != nil {
-			return "", fmt.Errorf("get lesswrong.ru posts failed: %s", err)
-		}
-
-		postsCache, err := json.Marshal(posts)
-		if err != nil {
-			return "", fmt.Errorf("marshal lesswrong.ru posts failed: %s", err)
-		}
-
-		if err := b.storage.Set(ctx, "posts:lesswrong.ru", string(postsCache), b.config.CacheExpire); err != nil {
-			return "", fmt.Errorf("cache lesswrong.ru posts failed: %s", err)
+	for _, post := range posts {
+		if !servedURLs[post.URL] {
+			unseen = append(unseen, post)
 		}
 	}
 
-	if len(posts) == 0 {
-		return "", fmt.Errorf("lesswrong.ru posts not found")
+	if len(unseen) == 0 {
+		return models.Post{}, false, nil
 	}
 
-	i := b.randomInt(len(posts))
-	post := posts[i]
-
-	postCollector := colly.NewCollector()
-
-	postCollector.OnHTML("div.tex2jax", func(e *colly.HTMLElement) {
-		post.HTML, _ = e.DOM.Html()
-	})
-
-	if err := postCollector.Visit(post.URL); err != nil {
-		return "", fmt.Errorf("get lesswrong.ru random post failed: %s", err)
+	post, err := catalog.Fetch(ctx, unseen[b.randomInt(len(unseen))])
+	if err != nil {
+		return models.Post{}, false, err
 	}
 
-	return b.postToMarkdown(post, md.NewConverter(models.DomainLesswrongRu, true, nil), true)
+	return post, true, nil
 }
 
-func (b *Bot) randomLesswrong(ctx context.Context) (string, error) {
-	query := fmt.Sprintf(`{
-		posts(input: {terms: {view: "new", limit: 1, meta: null, offset: %d}}) {
-			results {
-				title
-				pageUrl
-				htmlBody
-			}
-		}
-	}`, b.randomInt(models.LesswrongPostsMaxCount))
+// markServed remembers that post was served to user. Catalog posts are kept in a set bounded by catalog size.
+// Sources without catalog can have any number of posts, so only last models.ServedMaxCount of them are kept in a list.
+func (b *Bot) markServed(ctx context.Context, userID int, source Source, url string) error {
+	key := servedKey(userID, source)
 
-	request, err := json.Marshal(map[string]string{"query": query})
-	if err != nil {
-		return "", fmt.Errorf("marshal request for lesswrong.com random post failed: %s", err)
-	}
+	if _, ok := source.(Catalog); ok {
+		if err := b.storage.SAdd(ctx, key, url); err != nil {
+			return fmt.Errorf("add served post failed: %s, key: %s", err, key)
+		}
 
-	httpResponse, err := b.httpClient.Post(ctx, "https://www.lesswrong.com/graphql", "application/json", bytes.NewBuffer(request))
-	if err != nil {
-		return "", fmt.Errorf("get lesswrong.com random post failed: %s", err)
+		return nil
 	}
 
-	var response models.LesswrongResponse
-
-	if err := b.handleResponse(httpResponse, &response); err != nil {
-		return "", fmt.Errorf("handle lesswrong.com random post response: %s", err)
+	if err := b.storage.LPush(ctx, key, url); err != nil {
+		return fmt.Errorf("push served post failed: %s, key: %s", err, key)
 	}
 
-	if len(response.Data.Posts.Results) == 0 {
-		return "", fmt.Errorf("lesswrong.com random post not found")
+	if err := b.storage.LTrim(ctx, key, 0, models.ServedMaxCount-1); err != nil {
+		return fmt.Errorf("trim served posts failed: %s, key: %s", err, key)
 	}
 
-	result := response.Data.Posts.Results[0]
+	return nil
+}
 
-	return b.postToMarkdown(result.AsPost(), md.NewConverter(models.DomainLesswrong, true, nil), false)
+// servedKey is a key of urls of source posts served to user: set for catalog sources, capped list for others.
+func servedKey(userID int, source Source) string {
+	return fmt.Sprintf("served:%d:%s", userID, source.ID())
 }
 
-func (b *Bot) postToMarkdown(post models.Post, mdConverter *md.Converter, urlWithText bool) (string, error) {
-	markdownOrig, err := mdConverter.ConvertString(post.HTML)
-	if err != nil {
-		return "", fmt.Errorf("convert lesswrong.ru html to markdown failed: %s", err)
+func urlSet(urls []string) map[string]bool {
+	set := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		set[url] = true
 	}
 
-	markdown := markdownOrig
-
-	// Cut post for preview mode.
-	if len(markdown) > models.PostMaxLength {
-		// Convert to runes to properly split between unicode symbols.
-		runes := []rune(markdown)
-		markdown = string(runes[:models.PostMaxLength])
-
-		// Truncate after next line end to not break markdown text.
-		rest := string(runes[models.PostMaxLength:])
-		if n := strings.IndexByte(rest, '\n'); n != -1 {
-			markdown += rest[:n]
-		} else {
-			markdown = markdownOrig
-		}
+	return set
+}
 
-		// Stupid hotfixes when markdown was cut in the middle.
-		markdown = strings.ReplaceAll(markdown, "* * *", "")
-		markdown = strings.ReplaceAll(markdown, "```", "")
+// previewPost renders post as Telegram HTML cut to models.PostMaxLength.
+func (b *Bot) previewPost(source Source, post models.Post) (string, error) {
+	body, err := renderer.Render(post.HTML, post.URL)
+	if err != nil {
+		return "", fmt.Errorf("render %s post failed: %s", source.Domain(), err)
 	}
 
-	// Stupid hotfixes for some invalid markdowns.
-	markdown = strings.ReplaceAll(markdown, "[[", "[")
-	markdown = strings.ReplaceAll(markdown, "]]", "]")
-	markdown = strings.ReplaceAll(markdown, "![]", "[Image]")
-	markdown = strings.ReplaceAll(markdown, "_[", "")
-	markdown = strings.ReplaceAll(markdown, "]_", "")
-
-	link := fmt.Sprintf("[%s](%s)", post.Title, post.URL)
+	link := renderer.Link(post.URL, post.Title)
 
-	postURL := post.URL
-	if urlWithText {
+	postURL := renderer.Escape(post.URL)
+	if linker, ok := source.(textLinker); ok && linker.TextLink() {
 		postURL = link
 	}
 
-	return fmt.Sprintf("📝 %s\n\n%s\n\n%s", link, markdown, postURL), nil
+	return fmt.Sprintf("📝 %s\n\n%s\n\n%s", link, renderer.Truncate(body, models.PostMaxLength), postURL), nil
 }
@@ -138,12 +138,10 @@ func TestRandomPost_ShouldGetRandomPostFromLessWrongRuWhenSourceNotSet(t *testin
 		return 2
 	}
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/lesswrong_ru_random_post.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/lesswrong_ru_random_post.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromSlateStarCodex(t *testing.T) {
@@ -161,12 +159,10 @@ func TestRandomPost_ShouldGetRandomPostFromSlateStarCodex(t *testing.T) {
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceSlate.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/slate_random_post.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/slate_random_post.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromSlateStarCodexInvalidMarkdownCut(t *testing.T) {
@@ -184,12 +180,10 @@ func TestRandomPost_ShouldGetRandomPostFromSlateStarCodexInvalidMarkdownCut(t *t
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceSlate.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/slate_random_post_invalid_cut.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/slate_random_post_invalid_cut.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromSlateStarCodexImageFix(t *testing.T) {
@@ -207,12 +201,10 @@ func TestRandomPost_ShouldGetRandomPostFromSlateStarCodexImageFix(t *testing.T)
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceSlate.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/slate_random_post_image_fix.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/slate_random_post_image_fix.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromAstralCodexTen(t *testing.T) {
@@ -230,12 +222,10 @@ func TestRandomPost_ShouldGetRandomPostFromAstralCodexTen(t *testing.T) {
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceAstral.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/astral_random_post.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/astral_random_post.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromAstralCodexTenInvalidCut(t *testing.T) {
@@ -253,12 +243,10 @@ func TestRandomPost_ShouldGetRandomPostFromAstralCodexTenInvalidCut(t *testing.T
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceAstral.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/astral_random_post_invalid_cut.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/astral_random_post_invalid_cut.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromAstralCodexTenLinkBug(t *testing.T) {
@@ -276,12 +264,10 @@ func TestRandomPost_ShouldGetRandomPostFromAstralCodexTenLinkBug(t *testing.T) {
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceAstral.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/astral_random_post_link_bug.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/astral_random_post_link_bug.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromLessWrongRuInvalidCut(t *testing.T) {
@@ -299,12 +285,10 @@ func TestRandomPost_ShouldGetRandomPostFromLessWrongRuInvalidCut(t *testing.T) {
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceLesswrongRu.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/lesswrong_ru_random_post_invalid_cut.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/lesswrong_ru_random_post_invalid_cut.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromLessWrongCom(t *testing.T) {
@@ -322,12 +306,10 @@ func TestRandomPost_ShouldGetRandomPostFromLessWrongCom(t *testing.T) {
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceLesswrong.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/lesswrong_random_post.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/lesswrong_random_post.html", got)
 }
 
 func TestRandomPost_ShouldGetRandomPostFromLessWrongComInvalidDomain(t *testing.T) {
@@ -345,10 +327,8 @@ func TestRandomPost_ShouldGetRandomPostFromLessWrongComInvalidDomain(t *testing.
 	err = tgbot.storage.Set(context.TODO(), key, models.SourceLesswrong.Value(), 0)
 	require.NoError(t, err)
 
-	got, err := tgbot.RandomPost(context.TODO(), userID)
+	got, _, err := tgbot.RandomPost(context.TODO(), userID)
 	require.NoError(t, err)
 
-	file, err := os.ReadFile("testdata/lesswrong_random_post_invalid_domain.md")
-	require.NoError(t, err)
-	require.Equal(t, string(file), got)
+	requireGolden(t, "testdata/lesswrong_random_post_invalid_domain.html", got)
 }
\ No newline at end of file
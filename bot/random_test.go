@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestPreviewPost(t *testing.T) {
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}})
+	require.NoError(t, err)
+
+	astralPost := func(t *testing.T, name string) models.Post {
+		file, err := os.ReadFile(name)
+		require.NoError(t, err)
+
+		var post models.AstralPost
+		require.NoError(t, json.Unmarshal(file, &post))
+
+		return post.AsPost()
+	}
+
+	lesswrongPost := func(t *testing.T, name string) models.Post {
+		file, err := os.ReadFile(name)
+		require.NoError(t, err)
+
+		var response models.LesswrongResponse
+		require.NoError(t, json.Unmarshal(file, &response))
+		require.NotEmpty(t, response.Data.Posts.Results)
+
+		return response.Data.Posts.Results[0].AsPost()
+	}
+
+	tests := []struct {
+		name   string
+		source models.Source
+		post   func(t *testing.T, name string) models.Post
+	}{
+		{name: "astral_random_post", source: models.SourceAstral, post: astralPost},
+		{name: "astral_random_post_invalid_cut", source: models.SourceAstral, post: astralPost},
+		{name: "astral_random_post_link_bug", source: models.SourceAstral, post: astralPost},
+		{name: "lesswrong_random_post", source: models.SourceLesswrong, post: lesswrongPost},
+		{name: "lesswrong_random_post_invalid_domain", source: models.SourceLesswrong, post: lesswrongPost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, ok := tgbot.sources.Get(tt.source)
+			require.True(t, ok)
+
+			post := tt.post(t, "testdata/"+tt.name+".json")
+
+			got, err := tgbot.previewPost(source, post)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(got, "📝 <a href=\""+post.URL+"\">"))
+			requireGolden(t, "testdata/"+tt.name+".html", got)
+		})
+	}
+}
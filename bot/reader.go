@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+const MessageReadUsage = `Usage: /read <post url>
+
+Example:
+
+/read https://slatestarcodex.com/2014/07/30/meditations-on-moloch/`
+
+// readCallbackPrefix marks callback data of reader buttons in format read:<key>[:<page>].
+const readCallbackPrefix = "read:"
+
+// postKeyExpireFactor is how many times longer than posts cache url and title are kept by post key,
+// so buttons under old messages keep working for a while.
+const postKeyExpireFactor = 30
+
+// pagedPost is full post split into pages.
+type pagedPost struct {
+	Title string   `json:"title"`
+	URL   string   `json:"url"`
+	Pages []string `json:"pages"`
+}
+
+// ReadPost returns first page of post by its url.
+func (b *Bot) ReadPost(ctx context.Context, postURL string) (string, interface{}, error) {
+	postURL = strings.TrimSpace(postURL)
+	if postURL == "" {
+		return renderer.Escape(MessageReadUsage), nil, nil
+	}
+
+	if _, ok := b.sources.Find(postURL); !ok {
+		return "Source for this url is not supported", nil, nil
+	}
+
+	key, err := b.readKey(ctx, postURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return b.ReadPage(ctx, key, 0)
+}
+
+// ReadPage returns page of post by post key. Page is zero based and is clamped to pages count.
+func (b *Bot) ReadPage(ctx context.Context, key string, page int) (string, interface{}, error) {
+	post, err := b.pagedPost(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if page >= len(post.Pages) {
+		page = len(post.Pages) - 1
+	}
+
+	if page < 0 {
+		page = 0
+	}
+
+	text := fmt.Sprintf("📝 %s\n\n%s\n\nPage %d/%d", renderer.Link(post.URL, post.Title), post.Pages[page], page+1, len(post.Pages))
+
+	var buttons []tgbotapi.InlineKeyboardButton
+
+	if page > 0 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("◀ Prev", readCallbackData(key, page-1)))
+	}
+
+	if page < len(post.Pages)-1 {
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData("Next ▶", readCallbackData(key, page+1)))
+	}
+
+	if len(buttons) == 0 {
+		return text, nil, nil
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(buttons), nil
+}
+
+// postKeyboard returns keyboard with buttons which open post in reader and save it to bookmarks.
+func (b *Bot) postKeyboard(ctx context.Context, post models.Post) (tgbotapi.InlineKeyboardMarkup, error) {
+	key, err := b.readKey(ctx, post.URL)
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	// Title is kept for bookmarks as callback has only post key.
+	if err := b.storage.Set(ctx, "title:"+key, post.Title, b.postKeyExpire()); err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, fmt.Errorf("set post title failed: %s, key: %s", err, key)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📖 Read", readCallbackPrefix+key),
+			tgbotapi.NewInlineKeyboardButtonData("⭐ Save", saveCallbackPrefix+key),
+		),
+	), nil
+}
+
+// readKey returns short key of post url and remembers url by it,
+// as callback data is limited to 64 bytes and post urls are often longer.
+func (b *Bot) readKey(ctx context.Context, postURL string) (string, error) {
+	key := postKey(postURL)
+
+	if err := b.storage.Set(ctx, "post:"+key, postURL, b.postKeyExpire()); err != nil {
+		return "", fmt.Errorf("set post url failed: %s, key: %s", err, key)
+	}
+
+	return key, nil
+}
+
+// postKeyExpire returns expire of post url and title kept by post key.
+func (b *Bot) postKeyExpire() time.Duration {
+	return b.config.CacheExpire * postKeyExpireFactor
+}
+
+// postKey returns short key of post url.
+func postKey(postURL string) string {
+	hash := sha256.Sum256([]byte(postURL))
+	return hex.EncodeToString(hash[:8])
+}
+
+// pagedPost returns post pages from cache or fetches post and splits it into pages.
+func (b *Bot) pagedPost(ctx context.Context, key string) (pagedPost, error) {
+	pagesKey := "pages:" + key
+
+	pagesCached, err := b.storage.Get(ctx, pagesKey)
+	if err != nil {
+		return pagedPost{}, fmt.Errorf("get post pages failed: %s, key: %s", err, pagesKey)
+	}
+
+	if pagesCached != "" {
+		var post pagedPost
+
+		if err := json.Unmarshal([]byte(pagesCached), &post); err != nil {
+			return pagedPost{}, fmt.Errorf("unmarshal post pages failed: %s, key: %s", err, pagesKey)
+		}
+
+		return post, nil
+	}
+
+	postURL, err := b.storage.Get(ctx, "post:"+key)
+	if err != nil {
+		return pagedPost{}, fmt.Errorf("get post url failed: %s, key: %s", err, key)
+	}
+
+	source, ok := b.sources.Find(postURL)
+	if !ok {
+		return pagedPost{}, fmt.Errorf("source not found for post: %q, key: %s", postURL, key)
+	}
+
+	post, err := source.Post(ctx, postURL)
+	if err != nil {
+		return pagedPost{}, err
+	}
+
+	body, err := renderer.Render(post.HTML, post.URL)
+	if err != nil {
+		return pagedPost{}, fmt.Errorf("render %s post failed: %s", source.Domain(), err)
+	}
+
+	paged := pagedPost{
+		Title: post.Title,
+		URL:   post.URL,
+		Pages: renderer.Split(body, models.PageMaxLength),
+	}
+
+	pagesCache, err := json.Marshal(paged)
+	if err != nil {
+		return pagedPost{}, fmt.Errorf("marshal post pages failed: %s, key: %s", err, pagesKey)
+	}
+
+	if err := b.storage.Set(ctx, pagesKey, string(pagesCache), b.config.CacheExpire); err != nil {
+		return pagedPost{}, fmt.Errorf("set post pages failed: %s, key: %s", err, pagesKey)
+	}
+
+	return paged, nil
+}
+
+// parseReadCallback parses callback data in format read:<key>[:<page>]. Page is -1 if it isn't set.
+func parseReadCallback(data string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(data, readCallbackPrefix)
+	if !ok || rest == "" {
+		return "", 0, false
+	}
+
+	key, pageText, ok := strings.Cut(rest, ":")
+	if !ok {
+		return key, -1, true
+	}
+
+	page, err := strconv.Atoi(pageText)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return key, page, true
+}
+
+func readCallbackData(key string, page int) string {
+	return fmt.Sprintf("%s%s:%d", readCallbackPrefix, key, page)
+}
@@ -0,0 +1,156 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+func TestReadPost(t *testing.T) {
+	const postURL = "https://astralcodexten.substack.com/p/long-post"
+
+	var body strings.Builder
+
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&body, "<p>Paragraph %d. %s</p>", i, strings.Repeat("Lorem ipsum dolor sit amet. ", 10))
+	}
+
+	post, err := json.Marshal(models.AstralPost{
+		Slug:         "long-post",
+		Title:        "Long Post",
+		CanonicalURL: postURL,
+		BodyHTML:     body.String(),
+	})
+	require.NoError(t, err)
+
+	httpClient := &mocks.HTTPClient{}
+
+	// Pages are cached, so post is fetched only once.
+	httpClient.On("Get", context.TODO(), "https://astralcodexten.substack.com/api/v1/posts/long-post").Return(
+		&http.Response{Body: io.NopCloser(bytes.NewBuffer(post))},
+		nil,
+	).Once()
+
+	telegram, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{BotAPI: botAPI, HTTPClient: httpClient})
+	require.NoError(t, err)
+
+	text, _, err := tgbot.ReadPost(context.TODO(), "")
+	require.NoError(t, err)
+	require.Equal(t, "Usage: /read &lt;post url&gt;", strings.SplitN(text, "\n", 2)[0])
+
+	text, _, err = tgbot.ReadPost(context.TODO(), "https://example.com/post")
+	require.NoError(t, err)
+	require.Equal(t, "Source for this url is not supported", text)
+
+	sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 1},
+			Text:     "/read " + postURL,
+			Entities: &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len("/read")}},
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(sent.Text, "📝 <a href=\""+postURL+"\">Long Post</a>\n\nParagraph 1."))
+	require.True(t, strings.HasSuffix(sent.Text, "\n\nPage 1/3"))
+
+	messages := telegram.Sent("sendMessage")
+	require.Len(t, messages, 1)
+	require.Contains(t, messages[0].Get("reply_markup"), "Next ▶")
+	require.NotContains(t, messages[0].Get("reply_markup"), "◀ Prev")
+
+	key, err := tgbot.readKey(context.TODO(), postURL)
+	require.NoError(t, err)
+
+	for page := 1; page <= 2; page++ {
+		sent, err = tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+			CallbackQuery: &tgbotapi.CallbackQuery{
+				ID:      "callback",
+				From:    &tgbotapi.User{ID: 1},
+				Message: &tgbotapi.Message{MessageID: sent.MessageID, Chat: &tgbotapi.Chat{ID: 1}},
+				Data:    readCallbackData(key, page),
+			},
+		})
+		require.NoError(t, err)
+		require.True(t, strings.HasSuffix(sent.Text, fmt.Sprintf("\n\nPage %d/3", page+1)))
+	}
+
+	edits := telegram.Sent("editMessageText")
+	require.Len(t, edits, 2)
+	require.Contains(t, edits[0].Get("reply_markup"), "◀ Prev")
+	require.Contains(t, edits[0].Get("reply_markup"), "Next ▶")
+	require.NotContains(t, edits[1].Get("reply_markup"), "Next ▶")
+
+	for _, edit := range edits {
+		require.Equal(t, "HTML", edit.Get("parse_mode"))
+		require.LessOrEqual(t, renderer.Len(edit.Get("text")), models.MessageMaxLength)
+	}
+
+	// Read button of random post sends first page as a new message.
+	_, err = tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "callback",
+			From:    &tgbotapi.User{ID: 1},
+			Message: &tgbotapi.Message{MessageID: 100, Chat: &tgbotapi.Chat{ID: 1}},
+			Data:    readCallbackPrefix + key,
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, telegram.Sent("sendMessage"), 2)
+	require.Len(t, telegram.Sent("answerCallbackQuery"), 3)
+
+	httpClient.AssertExpectations(t)
+}
+
+func TestPostKeyExpire(t *testing.T) {
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tgbot, err := New(Options{
+		BotAPI:  &tgbotapi.BotAPI{},
+		Config:  config.Config{CacheExpire: time.Hour},
+		Storage: memory.NewStorageWithOptions(memory.Options{Now: func() time.Time { return now }}),
+	})
+	require.NoError(t, err)
+
+	post := models.Post{Title: "Meditations On Moloch", URL: "https://slatestarcodex.com/2014/07/30/meditations-on-moloch/"}
+
+	_, err = tgbot.postKeyboard(context.TODO(), post)
+	require.NoError(t, err)
+
+	key := postKey(post.URL)
+
+	// Buttons keep working long after posts cache expired.
+	now = now.Add(29 * time.Hour)
+
+	postURL, err := tgbot.storage.Get(context.TODO(), "post:"+key)
+	require.NoError(t, err)
+	require.Equal(t, post.URL, postURL)
+
+	// But url and title aren't kept forever.
+	now = now.Add(2 * time.Hour)
+
+	postURL, err = tgbot.storage.Get(context.TODO(), "post:"+key)
+	require.NoError(t, err)
+	require.Empty(t, postURL)
+
+	title, err := tgbot.storage.Get(context.TODO(), "title:"+key)
+	require.NoError(t, err)
+	require.Empty(t, title)
+}
@@ -0,0 +1,309 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+)
+
+const schedulesKey = "schedules"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+type (
+	// Schedule is recurring time in user's timezone.
+	Schedule struct {
+		Period   string       `json:"period"`
+		Weekday  time.Weekday `json:"weekday,omitempty"`
+		Hour     int          `json:"hour"`
+		Minute   int          `json:"minute"`
+		Timezone string       `json:"timezone"`
+	}
+
+	// JobHandler runs scheduled job with given id.
+	JobHandler func(ctx context.Context, id string) error
+
+	scheduledJob struct {
+		Schedule Schedule  `json:"schedule"`
+		NextRun  time.Time `json:"next_run"`
+	}
+
+	// scheduler runs recurring jobs. Jobs are kept in storage, so they survive restarts.
+	// Job id has format kind:key, e.g. digest:123, and is handled by handler registered for its kind.
+	scheduler struct {
+		storage  Storage
+		now      func() time.Time
+		mu       sync.Mutex
+		handlers map[string]JobHandler
+	}
+)
+
+// ParseSchedule parses schedule in format "daily|weekly [weekday] HH:MM [timezone]", e.g. "daily 08:00 Europe/Moscow".
+// Weekly schedule is delivered on Monday if weekday isn't set. Default timezone is UTC.
+func ParseSchedule(value string) (Schedule, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return Schedule{}, fmt.Errorf("schedule is empty")
+	}
+
+	schedule := Schedule{Period: strings.ToLower(fields[0]), Timezone: "UTC"}
+	fields = fields[1:]
+
+	switch schedule.Period {
+	case PeriodDaily:
+	case PeriodWeekly:
+		schedule.Weekday = time.Monday
+
+		if len(fields) > 0 {
+			if weekday, ok := weekdays[strings.ToLower(fields[0])]; ok {
+				schedule.Weekday = weekday
+				fields = fields[1:]
+			}
+		}
+	default:
+		return Schedule{}, fmt.Errorf("unknown period: %s", schedule.Period)
+	}
+
+	if len(fields) == 0 {
+		return Schedule{}, fmt.Errorf("time is not set")
+	}
+
+	hour, minute, ok := strings.Cut(fields[0], ":")
+	if !ok {
+		return Schedule{}, fmt.Errorf("invalid time: %s", fields[0])
+	}
+
+	var err error
+
+	if schedule.Hour, err = strconv.Atoi(hour); err != nil || schedule.Hour < 0 || schedule.Hour > 23 {
+		return Schedule{}, fmt.Errorf("invalid hour: %s", hour)
+	}
+
+	if schedule.Minute, err = strconv.Atoi(minute); err != nil || schedule.Minute < 0 || schedule.Minute > 59 {
+		return Schedule{}, fmt.Errorf("invalid minute: %s", minute)
+	}
+
+	if len(fields) > 1 {
+		schedule.Timezone = fields[1]
+
+		if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+			return Schedule{}, fmt.Errorf("invalid timezone: %s", schedule.Timezone)
+		}
+	}
+
+	if len(fields) > 2 {
+		return Schedule{}, fmt.Errorf("too many arguments")
+	}
+
+	return schedule, nil
+}
+
+// Next returns first scheduled time after given time.
+func (s Schedule) Next(after time.Time) time.Time {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	t := after.In(loc)
+	next := time.Date(t.Year(), t.Month(), t.Day(), s.Hour, s.Minute, 0, 0, loc)
+
+	days := 1
+	if s.Period == PeriodWeekly {
+		days = 7
+		next = next.AddDate(0, 0, (int(s.Weekday)-int(next.Weekday())+7)%7)
+	}
+
+	for !next.After(after) {
+		next = next.AddDate(0, 0, days)
+	}
+
+	return next
+}
+
+func (s Schedule) String() string {
+	text := s.Period
+	if s.Period == PeriodWeekly {
+		text += " on " + s.Weekday.String()
+	}
+
+	return fmt.Sprintf("%s at %02d:%02d %s", text, s.Hour, s.Minute, s.Timezone)
+}
+
+func newScheduler(storage Storage, now func() time.Time) *scheduler {
+	return &scheduler{
+		storage:  storage,
+		now:      now,
+		handlers: make(map[string]JobHandler),
+	}
+}
+
+// Handle registers handler for jobs of given kind.
+func (s *scheduler) Handle(kind string, handler JobHandler) {
+	s.handlers[kind] = handler
+}
+
+func (s *scheduler) Get(ctx context.Context, id string) (Schedule, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.load(ctx)
+	if err != nil {
+		return Schedule{}, false, err
+	}
+
+	job, ok := jobs[id]
+
+	return job.Schedule, ok, nil
+}
+
+func (s *scheduler) Set(ctx context.Context, id string, schedule Schedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	jobs[id] = scheduledJob{
+		Schedule: schedule,
+		NextRun:  schedule.Next(s.now()),
+	}
+
+	return s.save(ctx, jobs)
+}
+
+func (s *scheduler) Remove(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	delete(jobs, id)
+
+	return s.save(ctx, jobs)
+}
+
+// Run runs due jobs every interval until context is done.
+func (s *scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.RunDue(ctx); err != nil {
+			log.Printf("[ERROR] Run scheduled jobs failed: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue runs jobs which next run time has come. Jobs missed while bot was down are run once.
+// Next run time is saved before job is run, so restart doesn't run the same job twice.
+func (s *scheduler) RunDue(ctx context.Context) error {
+	s.mu.Lock()
+
+	jobs, err := s.load(ctx)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	now := s.now()
+
+	var due []string
+
+	for id, job := range jobs {
+		if job.NextRun.After(now) {
+			continue
+		}
+
+		job.NextRun = job.Schedule.Next(now)
+		jobs[id] = job
+		due = append(due, id)
+	}
+
+	if len(due) > 0 {
+		if err := s.save(ctx, jobs); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+
+	s.mu.Unlock()
+
+	var errs []error
+
+	for _, id := range due {
+		kind, _, _ := strings.Cut(id, ":")
+
+		handler, ok := s.handlers[kind]
+		if !ok {
+			errs = append(errs, fmt.Errorf("handler not found for job: %s", id))
+			continue
+		}
+
+		if err := handler(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("run job %s failed: %s", id, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *scheduler) load(ctx context.Context) (map[string]scheduledJob, error) {
+	jobsCached, err := s.storage.Get(ctx, schedulesKey)
+	if err != nil {
+		return nil, fmt.Errorf("get schedules failed: %s", err)
+	}
+
+	jobs := make(map[string]scheduledJob)
+
+	if jobsCached != "" {
+		if err := json.Unmarshal([]byte(jobsCached), &jobs); err != nil {
+			return nil, fmt.Errorf("unmarshal schedules failed: %s", err)
+		}
+	}
+
+	return jobs, nil
+}
+
+func (s *scheduler) save(ctx context.Context, jobs map[string]scheduledJob) error {
+	jobsCache, err := json.Marshal(jobs)
+	if err != nil {
+		return fmt.Errorf("marshal schedules failed: %s", err)
+	}
+
+	if err := s.storage.Set(ctx, schedulesKey, string(jobsCache), 0); err != nil {
+		return fmt.Errorf("set schedules failed: %s", err)
+	}
+
+	return nil
+}
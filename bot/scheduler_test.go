@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Schedule
+		wantErr string
+	}{
+		{
+			name:  "Should parse daily schedule with timezone",
+			value: "daily 08:00 Europe/Moscow",
+			want:  Schedule{Period: PeriodDaily, Hour: 8, Minute: 0, Timezone: "Europe/Moscow"},
+		},
+		{
+			name:  "Should parse daily schedule in UTC by default",
+			value: "Daily 21:45",
+			want:  Schedule{Period: PeriodDaily, Hour: 21, Minute: 45, Timezone: "UTC"},
+		},
+		{
+			name:  "Should parse weekly schedule on Monday by default",
+			value: "weekly 9:05 America/New_York",
+			want:  Schedule{Period: PeriodWeekly, Weekday: time.Monday, Hour: 9, Minute: 5, Timezone: "America/New_York"},
+		},
+		{
+			name:  "Should parse weekly schedule with weekday",
+			value: "weekly Sat 10:30",
+			want:  Schedule{Period: PeriodWeekly, Weekday: time.Saturday, Hour: 10, Minute: 30, Timezone: "UTC"},
+		},
+		{
+			name:    "Should fail on unknown period",
+			value:   "monthly 08:00",
+			wantErr: "unknown period: monthly",
+		},
+		{
+			name:    "Should fail without time",
+			value:   "daily",
+			wantErr: "time is not set",
+		},
+		{
+			name:    "Should fail on invalid hour",
+			value:   "daily 24:00",
+			wantErr: "invalid hour: 24",
+		},
+		{
+			name:    "Should fail on invalid minute",
+			value:   "daily 08:60",
+			wantErr: "invalid minute: 60",
+		},
+		{
+			name:    "Should fail on invalid timezone",
+			value:   "daily 08:00 Mars/Olympus",
+			wantErr: "invalid timezone: Mars/Olympus",
+		},
+		{
+			name:    "Should fail on extra arguments",
+			value:   "daily 08:00 UTC please",
+			wantErr: "too many arguments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSchedule(tt.value)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		after    time.Time
+		want     time.Time
+	}{
+		{
+			name:     "Should return today if time hasn't come yet",
+			schedule: Schedule{Period: PeriodDaily, Hour: 8, Timezone: "Europe/Moscow"},
+			after:    time.Date(2021, 5, 1, 4, 0, 0, 0, time.UTC),
+			want:     time.Date(2021, 5, 1, 8, 0, 0, 0, moscow),
+		},
+		{
+			name:     "Should return tomorrow if time has passed",
+			schedule: Schedule{Period: PeriodDaily, Hour: 8, Timezone: "Europe/Moscow"},
+			after:    time.Date(2021, 5, 1, 5, 0, 0, 0, time.UTC),
+			want:     time.Date(2021, 5, 2, 8, 0, 0, 0, moscow),
+		},
+		{
+			name:     "Should keep local time after daylight saving change",
+			schedule: Schedule{Period: PeriodDaily, Hour: 8, Timezone: "America/New_York"},
+			after:    time.Date(2021, 3, 13, 14, 0, 0, 0, time.UTC),
+			want:     time.Date(2021, 3, 14, 8, 0, 0, 0, newYork),
+		},
+		{
+			name:     "Should return next weekday",
+			schedule: Schedule{Period: PeriodWeekly, Weekday: time.Monday, Hour: 10, Timezone: "UTC"},
+			after:    time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Should return next week if time has passed on the same weekday",
+			schedule: Schedule{Period: PeriodWeekly, Weekday: time.Saturday, Hour: 10, Timezone: "UTC"},
+			after:    time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC),
+			want:     time.Date(2021, 5, 8, 10, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.schedule.Next(tt.after)
+			require.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	now := time.Date(2021, 5, 1, 7, 0, 0, 0, time.UTC)
+	storage := memory.NewStorage()
+
+	var runs []string
+
+	newScheduler := func() *scheduler {
+		s := newScheduler(storage, func() time.Time { return now })
+		s.Handle("test", func(_ context.Context, id string) error {
+			runs = append(runs, id)
+			return nil
+		})
+
+		return s
+	}
+
+	s := newScheduler()
+
+	err := s.Set(context.TODO(), "test:1", Schedule{Period: PeriodDaily, Hour: 8, Timezone: "UTC"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.RunDue(context.TODO()))
+	require.Empty(t, runs)
+
+	now = now.Add(time.Hour)
+
+	require.NoError(t, s.RunDue(context.TODO()))
+	require.Equal(t, []string{"test:1"}, runs)
+
+	// Job isn't run again after restart.
+	require.NoError(t, newScheduler().RunDue(context.TODO()))
+	require.Len(t, runs, 1)
+
+	// Missed runs are run only once.
+	now = now.Add(72 * time.Hour)
+
+	require.NoError(t, newScheduler().RunDue(context.TODO()))
+	require.NoError(t, newScheduler().RunDue(context.TODO()))
+	require.Len(t, runs, 2)
+
+	require.NoError(t, s.Remove(context.TODO(), "test:1"))
+
+	_, ok, err := s.Get(context.TODO(), "test:1")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
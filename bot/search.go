@@ -0,0 +1,361 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"golang.org/x/net/html"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+const MessageSearchUsage = `Usage: /search <query>
+
+Example:
+
+/search moloch`
+
+const (
+	// searchCallbackPrefix marks callback data of search navigation buttons in format search:<key>:<page>.
+	searchCallbackPrefix = "search:"
+	// previewCallbackPrefix marks callback data of search result buttons in format preview:<post key>.
+	previewCallbackPrefix = "preview:"
+)
+
+type (
+	// Searchable is implemented by sources which have own search, e.g. when List returns only latest posts.
+	Searchable interface {
+		// Search returns posts matching query ordered by relevance.
+		Search(ctx context.Context, query string) ([]models.Post, error)
+	}
+
+	// searchResults are ranked results cached for pagination.
+	searchResults struct {
+		Query   string         `json:"query"`
+		Results []searchResult `json:"results"`
+	}
+
+	searchResult struct {
+		Title  string `json:"title"`
+		URL    string `json:"url"`
+		Source string `json:"source"`
+		// Snippet is Telegram HTML with part of post body around query words.
+		Snippet string `json:"snippet,omitempty"`
+	}
+)
+
+// Search returns first page of posts matching query from all sources.
+func (b *Bot) Search(ctx context.Context, query string) (string, interface{}, error) {
+	query = strings.Join(strings.Fields(query), " ")
+	if query == "" {
+		return renderer.Escape(MessageSearchUsage), nil, nil
+	}
+
+	hash := sha256.Sum256([]byte(strings.ToLower(query)))
+	key := hex.EncodeToString(hash[:8])
+
+	results := searchResults{
+		Query:   query,
+		Results: b.search(ctx, query),
+	}
+
+	resultsCache, err := json.Marshal(results)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal search results failed: %s, query: %s", err, query)
+	}
+
+	if err := b.storage.Set(ctx, "search:"+key, string(resultsCache), b.config.CacheExpire); err != nil {
+		return "", nil, fmt.Errorf("set search results failed: %s, query: %s", err, query)
+	}
+
+	return b.SearchPage(ctx, key, 0)
+}
+
+// SearchPage returns page of cached search results. Page is zero based and is clamped to pages count.
+func (b *Bot) SearchPage(ctx context.Context, key string, page int) (string, interface{}, error) {
+	resultsKey := "search:" + key
+
+	resultsCached, err := b.storage.Get(ctx, resultsKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("get search results failed: %s, key: %s", err, resultsKey)
+	}
+
+	if resultsCached == "" {
+		return "Search results are expired, please search again", nil, nil
+	}
+
+	var results searchResults
+
+	if err := json.Unmarshal([]byte(resultsCached), &results); err != nil {
+		return "", nil, fmt.Errorf("unmarshal search results failed: %s, key: %s", err, resultsKey)
+	}
+
+	if len(results.Results) == 0 {
+		return renderer.Escape(fmt.Sprintf("Nothing found for %q", results.Query)), nil, nil
+	}
+
+	pages := (len(results.Results) + models.SearchPageSize - 1) / models.SearchPageSize
+
+	if page >= pages {
+		page = pages - 1
+	}
+
+	if page < 0 {
+		page = 0
+	}
+
+	start := page * models.SearchPageSize
+	end := min(start+models.SearchPageSize, len(results.Results))
+
+	var (
+		text    strings.Builder
+		buttons []tgbotapi.InlineKeyboardButton
+	)
+
+	text.WriteString(fmt.Sprintf("🔍 Found %d posts for <b>%s</b>:\n\n", len(results.Results), renderer.Escape(results.Query)))
+
+	for i, result := range results.Results[start:end] {
+		n := strconv.Itoa(start + i + 1)
+
+		text.WriteString(fmt.Sprintf("%s. %s (%s)\n", n, renderer.Link(result.URL, result.Title), renderer.Escape(result.Source)))
+
+		if result.Snippet != "" {
+			text.WriteString(result.Snippet + "\n")
+		}
+
+		text.WriteString("\n")
+
+		postKey, err := b.readKey(ctx, result.URL)
+		if err != nil {
+			return "", nil, err
+		}
+
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(n, previewCallbackPrefix+postKey))
+	}
+
+	text.WriteString(fmt.Sprintf("Page %d/%d", page+1, pages))
+
+	rows := [][]tgbotapi.InlineKeyboardButton{buttons}
+
+	var navigation []tgbotapi.InlineKeyboardButton
+
+	if page > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("◀ Prev", searchCallbackData(key, page-1)))
+	}
+
+	if page < pages-1 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("Next ▶", searchCallbackData(key, page+1)))
+	}
+
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// PreviewPost returns preview of post by post key with button to read it.
+func (b *Bot) PreviewPost(ctx context.Context, key string) (string, interface{}, error) {
+	postURL, err := b.storage.Get(ctx, "post:"+key)
+	if err != nil {
+		return "", nil, fmt.Errorf("get post url failed: %s, key: %s", err, key)
+	}
+
+	source, ok := b.sources.Find(postURL)
+	if !ok {
+		return "", nil, fmt.Errorf("source not found for post: %q, key: %s", postURL, key)
+	}
+
+	post, err := source.Post(ctx, postURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	text, err := b.previewPost(source, post)
+	if err != nil {
+		return "", nil, err
+	}
+
+	keyboard, err := b.postKeyboard(ctx, post)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return text, keyboard, nil
+}
+
+// search returns posts found by full-text index followed by posts matching all query words in title.
+// Sources which fail are skipped, so search works when some sites are down.
+func (b *Bot) search(ctx context.Context, query string) []searchResult {
+	type scored struct {
+		result searchResult
+		score  int
+	}
+
+	var (
+		results []searchResult
+		matches []scored
+		seen    = make(map[string]bool)
+	)
+
+	indexed, err := b.index.Search(ctx, query, models.SearchMaxResults)
+	if err != nil {
+		log.Printf("[ERROR] Search index failed: %s", err)
+	}
+
+	for _, result := range indexed {
+		name := result.Source
+		if source, ok := b.sources.Get(models.Source(result.Source)); ok {
+			name = source.Name()
+		}
+
+		seen[result.URL] = true
+
+		results = append(results, searchResult{
+			Title:   result.Title,
+			URL:     result.URL,
+			Source:  name,
+			Snippet: result.Snippet,
+		})
+	}
+
+	// Phrases are matched by words in titles.
+	words := strings.Fields(strings.ToLower(strings.ReplaceAll(query, `"`, " ")))
+
+	for _, source := range b.sources.All() {
+		var posts []models.Post
+
+		searchable, isSearchable := source.(Searchable)
+		if isSearchable {
+			posts, err = searchable.Search(ctx, query)
+		} else {
+			posts, err = source.List(ctx)
+		}
+
+		if err != nil {
+			log.Printf("[ERROR] Search %s posts failed: %s", source.Domain(), err)
+			continue
+		}
+
+		for _, post := range posts {
+			if seen[post.URL] {
+				continue
+			}
+
+			score := matchScore(post, words)
+
+			// Posts found by source search may match query only by body which isn't returned.
+			if isSearchable && score == 0 {
+				score = 1
+			}
+
+			if score == 0 {
+				continue
+			}
+
+			seen[post.URL] = true
+
+			matches = append(matches, scored{
+				result: searchResult{Title: post.Title, URL: post.URL, Source: source.Name()},
+				score:  score,
+			})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	for _, match := range matches {
+		results = append(results, match.result)
+	}
+
+	return results[:min(len(results), models.SearchMaxResults)]
+}
+
+// matchScore returns 0 if post doesn't contain every word. Otherwise words in title
+// are scored higher than words in body and whole query in title gets extra score.
+func matchScore(post models.Post, words []string) int {
+	if len(words) == 0 {
+		return 0
+	}
+
+	title := strings.ToLower(post.Title)
+
+	var body string
+
+	score := 0
+
+	for _, word := range words {
+		if strings.Contains(title, word) {
+			score += 2
+			continue
+		}
+
+		if body == "" && post.HTML != "" {
+			body = strings.ToLower(plainText(post.HTML))
+		}
+
+		if !strings.Contains(body, word) {
+			return 0
+		}
+
+		score++
+	}
+
+	if len(words) > 1 && strings.Contains(title, strings.Join(words, " ")) {
+		score += 2
+	}
+
+	return score
+}
+
+// plainText returns text of html without tags.
+func plainText(text string) string {
+	var plain strings.Builder
+
+	tokenizer := html.NewTokenizer(strings.NewReader(text))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return plain.String()
+		case html.TextToken:
+			plain.Write(tokenizer.Text())
+			plain.WriteString(" ")
+		}
+	}
+}
+
+// parseSearchCallback parses callback data in format search:<key>:<page>.
+func parseSearchCallback(data string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(data, searchCallbackPrefix)
+	if !ok {
+		return "", 0, false
+	}
+
+	key, pageText, ok := strings.Cut(rest, ":")
+	if !ok || key == "" {
+		return "", 0, false
+	}
+
+	page, err := strconv.Atoi(pageText)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return key, page, true
+}
+
+func searchCallbackData(key string, page int) string {
+	return fmt.Sprintf("%s%s:%d", searchCallbackPrefix, key, page)
+}
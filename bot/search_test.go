@@ -0,0 +1,240 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestSearch(t *testing.T) {
+	httpClient := &mocks.HTTPClient{}
+
+	// Query is passed in GraphQL variables.
+	request := mock.MatchedBy(func(body *bytes.Buffer) bool {
+		var req graphQLRequest
+		return json.Unmarshal(body.Bytes(), &req) == nil && req.Variables["query"] == "Moloch"
+	})
+
+	mockSearch := func(baseURL, body string) {
+		httpClient.On("Post", context.TODO(), baseURL+"/graphql", "application/json", request).Return(
+			&http.Response{Body: io.NopCloser(bytes.NewBufferString(body))},
+			nil,
+		).Once()
+	}
+
+	mockSearch("https://www.lesswrong.com", `{"data":{"posts":{"results":[
+		{"title":"Inadequate Equilibria","pageUrl":"https://www.lesswrong.com/posts/1/inadequate-equilibria"},
+		{"title":"Moloch's Toolbox","pageUrl":"https://www.lesswrong.com/posts/2/moloch-s-toolbox"}
+	]}}}`)
+	mockSearch("https://forum.effectivealtruism.org", `{"data":{"posts":{"results":[]}}}`)
+	// Failed source is skipped.
+	mockSearch("https://www.alignmentforum.org", `<html>Internal Server Error</html>`)
+
+	astralPost, err := json.Marshal(models.AstralPost{
+		Slug:         "moloch-1",
+		Title:        "Moloch 1",
+		CanonicalURL: "https://astralcodexten.substack.com/p/moloch-1",
+		BodyHTML:     "<p>Moloch whose mind is pure machinery!</p>",
+	})
+	require.NoError(t, err)
+
+	httpClient.On("Get", context.TODO(), "https://astralcodexten.substack.com/api/v1/posts/moloch-1").Return(
+		&http.Response{Body: io.NopCloser(bytes.NewBuffer(astralPost))},
+		nil,
+	).Once()
+
+	telegram, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{BotAPI: botAPI, HTTPClient: httpClient})
+	require.NoError(t, err)
+
+	setPosts := func(name string, posts []models.Post) {
+		postsCache, err := json.Marshal(posts)
+		require.NoError(t, err)
+		require.NoError(t, tgbot.storage.Set(context.TODO(), "posts:"+name, string(postsCache), 0))
+	}
+
+	setPosts("lesswrong.ru", []models.Post{
+		{Title: "Размышления о Молохе", URL: "https://lesswrong.ru/w/moloch"},
+	})
+	setPosts("slatestarcodex", []models.Post{
+		{Title: "Beware The Man Of One Study", URL: "https://slatestarcodex.com/2014/12/12/beware-the-man-of-one-study/"},
+		{Title: "Meditations on Moloch", URL: "https://slatestarcodex.com/2014/07/30/meditations-on-moloch/"},
+	})
+
+	var astralPosts []models.Post
+
+	for i := 1; i <= 5; i++ {
+		astralPosts = append(astralPosts, models.Post{
+			Title: fmt.Sprintf("Moloch %d", i),
+			URL:   fmt.Sprintf("https://astralcodexten.substack.com/p/moloch-%d", i),
+			Slug:  fmt.Sprintf("moloch-%d", i),
+		})
+	}
+
+	setPosts("astralcodexten", astralPosts)
+
+	text, keyboard, err := tgbot.Search(context.TODO(), "  ")
+	require.NoError(t, err)
+	require.Nil(t, keyboard)
+	require.Equal(t, "Usage: /search &lt;query&gt;", strings.SplitN(text, "\n", 2)[0])
+
+	sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 1},
+			Text:     "/search Moloch",
+			Entities: &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len("/search")}},
+		},
+	})
+	require.NoError(t, err)
+
+	want := `🔍 Found 8 posts for <b>Moloch</b>:
+
+1. <a href="https://slatestarcodex.com/2014/07/30/meditations-on-moloch/">Meditations on Moloch</a> (Slate Star Codex)
+
+2. <a href="https://astralcodexten.substack.com/p/moloch-1">Moloch 1</a> (Astral Codex Ten)
+
+3. <a href="https://astralcodexten.substack.com/p/moloch-2">Moloch 2</a> (Astral Codex Ten)
+
+4. <a href="https://astralcodexten.substack.com/p/moloch-3">Moloch 3</a> (Astral Codex Ten)
+
+5. <a href="https://astralcodexten.substack.com/p/moloch-4">Moloch 4</a> (Astral Codex Ten)
+
+Page 1/2`
+	require.Equal(t, want, sent.Text)
+
+	messages := telegram.Sent("sendMessage")
+	require.Len(t, messages, 1)
+	require.Equal(t, "HTML", messages[0].Get("parse_mode"))
+	require.Contains(t, messages[0].Get("reply_markup"), "Next ▶")
+
+	var markup tgbotapi.InlineKeyboardMarkup
+	require.NoError(t, json.Unmarshal([]byte(messages[0].Get("reply_markup")), &markup))
+	require.Len(t, markup.InlineKeyboard, 2)
+	require.Len(t, markup.InlineKeyboard[0], models.SearchPageSize)
+
+	sent, err = tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "callback",
+			From:    &tgbotapi.User{ID: 1},
+			Message: &tgbotapi.Message{MessageID: sent.MessageID, Chat: &tgbotapi.Chat{ID: 1}},
+			Data:    *markup.InlineKeyboard[1][0].CallbackData,
+		},
+	})
+	require.NoError(t, err)
+
+	want = `🔍 Found 8 posts for <b>Moloch</b>:
+
+6. <a href="https://astralcodexten.substack.com/p/moloch-5">Moloch 5</a> (Astral Codex Ten)
+
+7. <a href="https://www.lesswrong.com/posts/2/moloch-s-toolbox">Moloch's Toolbox</a> (Lesswrong.com)
+
+8. <a href="https://www.lesswrong.com/posts/1/inadequate-equilibria">Inadequate Equilibria</a> (Lesswrong.com)
+
+Page 2/2`
+	require.Equal(t, want, sent.Text)
+
+	edits := telegram.Sent("editMessageText")
+	require.Len(t, edits, 1)
+	require.Contains(t, edits[0].Get("reply_markup"), "◀ Prev")
+	require.NotContains(t, edits[0].Get("reply_markup"), "Next ▶")
+
+	// Result button sends post preview.
+	sent, err = tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "callback",
+			From:    &tgbotapi.User{ID: 1},
+			Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}},
+			Data:    *markup.InlineKeyboard[0][1].CallbackData,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "📝 <a href=\"https://astralcodexten.substack.com/p/moloch-1\">Moloch 1</a>\n\nMoloch whose mind is pure machinery!\n\nhttps://astralcodexten.substack.com/p/moloch-1", sent.Text)
+
+	messages = telegram.Sent("sendMessage")
+	require.Len(t, messages, 2)
+	require.Contains(t, messages[1].Get("reply_markup"), "📖 Read")
+
+	httpClient.AssertExpectations(t)
+}
+
+func TestSearch_NothingFound(t *testing.T) {
+	httpClient := &mocks.HTTPClient{}
+
+	httpClient.On("Post", context.TODO(), mock.Anything, "application/json", mock.Anything).Return(
+		func(context.Context, string, string, io.Reader) *http.Response {
+			return &http.Response{Body: io.NopCloser(bytes.NewBufferString(`{"data":{"posts":{"results":[]}}}`))}
+		},
+		nil,
+	)
+
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}, HTTPClient: httpClient})
+	require.NoError(t, err)
+
+	for _, name := range []string{"lesswrong.ru", "slatestarcodex", "astralcodexten"} {
+		require.NoError(t, tgbot.storage.Set(context.TODO(), "posts:"+name, `[{"Title":"Post","URL":"https://example.com"}]`, 0))
+	}
+
+	text, keyboard, err := tgbot.Search(context.TODO(), "moloch")
+	require.NoError(t, err)
+	require.Nil(t, keyboard)
+	require.Equal(t, `Nothing found for "moloch"`, text)
+
+	text, keyboard, err = tgbot.SearchPage(context.TODO(), "expired", 0)
+	require.NoError(t, err)
+	require.Nil(t, keyboard)
+	require.Equal(t, "Search results are expired, please search again", text)
+}
+
+func TestMatchScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		post  models.Post
+		query string
+		want  int
+	}{
+		{
+			name:  "Should not match post without every word",
+			post:  models.Post{Title: "Meditations on Moloch"},
+			query: "moloch toolbox",
+			want:  0,
+		},
+		{
+			name:  "Should score words in title higher",
+			post:  models.Post{Title: "Meditations on Moloch", HTML: "<p>Ginsberg poem</p>"},
+			query: "moloch poem",
+			want:  3,
+		},
+		{
+			name:  "Should not match tags in body",
+			post:  models.Post{Title: "Meditations on Moloch", HTML: "<div>Ginsberg poem</div>"},
+			query: "moloch div",
+			want:  0,
+		},
+		{
+			name:  "Should score whole query in title",
+			post:  models.Post{Title: "Meditations on Moloch"},
+			query: "on moloch",
+			want:  6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matchScore(tt.post, strings.Fields(tt.query)))
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+// sequenceCallbackPrefix marks callback data of sequence buttons in format seq:<source id>:<sequence id>.
+const sequenceCallbackPrefix = "seq:"
+
+type (
+	// Sequential is implemented by sources which have series of posts to read in order.
+	Sequential interface {
+		// Sequences returns sequences to choose from. Posts may be omitted.
+		Sequences(ctx context.Context) ([]models.Sequence, error)
+		// Sequence returns sequence with posts in reading order.
+		Sequence(ctx context.Context, id string) (models.Sequence, error)
+	}
+
+	// sequencePosition is a sequence user reads and index of the last sent post.
+	sequencePosition struct {
+		Source   models.Source `json:"source"`
+		ID       string        `json:"id"`
+		Position int           `json:"position"`
+	}
+)
+
+// Sequences returns keyboard with sequences of user source.
+func (b *Bot) Sequences(ctx context.Context, userID int) (string, interface{}, error) {
+	source := b.userSource(ctx, userID)
+
+	sequential, ok := source.(Sequential)
+	if !ok {
+		return "Sequences are not supported for " + renderer.Escape(sourceURL(source)), nil, nil
+	}
+
+	sequences, err := b.sequences(ctx, source.ID(), sequential)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(sequences) == 0 {
+		return "Sequences not found for " + renderer.Escape(sourceURL(source)), nil, nil
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	for _, sequence := range sequences {
+		data := sequenceCallbackPrefix + source.ID().Value() + ":" + sequence.ID
+
+		// Telegram limits inline keyboard callback data to 64 bytes.
+		if len(data) > 64 {
+			continue
+		}
+
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(sequence.Title, data)))
+	}
+
+	text := "📚 Choose a sequence from " + renderer.Escape(sourceURL(source)) + " and read it post by post with /next"
+
+	position, err := b.sequencePosition(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if position.ID != "" {
+		if current, err := b.sequence(ctx, position.Source, position.ID); err == nil {
+			text += fmt.Sprintf("\n\nYou're reading %s, post %d/%d", renderer.Escape(current.Title), position.Position+1, len(current.Posts))
+		}
+	}
+
+	return text, tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// StartSequence remembers sequence chosen by user and returns its first post.
+func (b *Bot) StartSequence(ctx context.Context, userID int, sourceID models.Source, id string) (string, interface{}, error) {
+	return b.sequencePost(ctx, userID, sequencePosition{Source: sourceID, ID: id, Position: 0})
+}
+
+// NextPost returns next post of sequence user reads.
+func (b *Bot) NextPost(ctx context.Context, userID int) (string, interface{}, error) {
+	position, err := b.sequencePosition(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if position.ID == "" {
+		return "Choose a sequence with /sequence first", nil, nil
+	}
+
+	position.Position++
+
+	return b.sequencePost(ctx, userID, position)
+}
+
+// sequencePost returns preview of post at position and saves position.
+func (b *Bot) sequencePost(ctx context.Context, userID int, position sequencePosition) (string, interface{}, error) {
+	sequence, err := b.sequence(ctx, position.Source, position.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if position.Position >= len(sequence.Posts) {
+		return fmt.Sprintf("🎉 You've finished %s. Choose another one with /sequence", renderer.Escape(sequence.Title)), nil, nil
+	}
+
+	if err := b.setSequencePosition(ctx, userID, position); err != nil {
+		return "", nil, err
+	}
+
+	preview, keyboard, err := b.sequencePostPreview(ctx, userID, position.Source, sequence.Posts[position.Position])
+	if err != nil {
+		return "", nil, err
+	}
+
+	text := fmt.Sprintf("📚 %s, %d/%d\n\n%s", renderer.Escape(sequence.Title), position.Position+1, len(sequence.Posts), preview)
+
+	if position.Position < len(sequence.Posts)-1 {
+		text += "\n\nSend /next to read the next post"
+	}
+
+	return text, keyboard, nil
+}
+
+// sequencePostPreview fetches sequence post, adds it to user history and returns its preview with post keyboard.
+func (b *Bot) sequencePostPreview(ctx context.Context, userID int, sourceID models.Source, post models.Post) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	source, ok := b.sources.Get(sourceID)
+	if !ok {
+		return "", tgbotapi.InlineKeyboardMarkup{}, fmt.Errorf("source not found: %s", sourceID)
+	}
+
+	post, err := source.Post(ctx, post.URL)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	if err := b.addHistory(ctx, userID, source, post); err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	preview, err := b.previewPost(source, post)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	keyboard, err := b.postKeyboard(ctx, post)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	return preview, keyboard, nil
+}
+
+// sequences returns cached sequences of source.
+func (b *Bot) sequences(ctx context.Context, sourceID models.Source, sequential Sequential) ([]models.Sequence, error) {
+	var sequences []models.Sequence
+
+	key := "sequences:" + sourceID.Value()
+
+	err := b.cached(ctx, key, &sequences, func() (err error) {
+		sequences, err = sequential.Sequences(ctx)
+		return err
+	})
+
+	return sequences, err
+}
+
+// sequence returns cached sequence with posts.
+func (b *Bot) sequence(ctx context.Context, sourceID models.Source, id string) (models.Sequence, error) {
+	source, ok := b.sources.Get(sourceID)
+	if !ok {
+		return models.Sequence{}, fmt.Errorf("source not found: %s", sourceID)
+	}
+
+	sequential, ok := source.(Sequential)
+	if !ok {
+		return models.Sequence{}, fmt.Errorf("sequences are not supported for %s", source.Domain())
+	}
+
+	var sequence models.Sequence
+
+	key := "sequences:" + sourceID.Value() + ":" + id
+
+	err := b.cached(ctx, key, &sequence, func() (err error) {
+		sequence, err = sequential.Sequence(ctx, id)
+		return err
+	})
+
+	return sequence, err
+}
+
+func (b *Bot) sequencePosition(ctx context.Context, userID int) (sequencePosition, error) {
+	key := fmt.Sprintf("sequence:%d", userID)
+
+	positionCached, err := b.storage.Get(ctx, key)
+	if err != nil {
+		return sequencePosition{}, fmt.Errorf("get sequence position failed: %s, key: %s", err, key)
+	}
+
+	var position sequencePosition
+
+	if positionCached == "" {
+		return position, nil
+	}
+
+	if err := json.Unmarshal([]byte(positionCached), &position); err != nil {
+		return sequencePosition{}, fmt.Errorf("unmarshal sequence position failed: %s, key: %s", err, key)
+	}
+
+	return position, nil
+}
+
+func (b *Bot) setSequencePosition(ctx context.Context, userID int, position sequencePosition) error {
+	key := fmt.Sprintf("sequence:%d", userID)
+
+	positionCache, err := json.Marshal(position)
+	if err != nil {
+		return fmt.Errorf("marshal sequence position failed: %s, key: %s", err, key)
+	}
+
+	if err := b.storage.Set(ctx, key, string(positionCache), 0); err != nil {
+		return fmt.Errorf("set sequence position failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestSequence(t *testing.T) {
+	const userID = 1
+
+	httpClient := &mocks.HTTPClient{}
+
+	mockQuery := func(body string) {
+		httpClient.On("Post", context.TODO(), "https://www.lesswrong.com/graphql", "application/json", mock.Anything).Return(
+			&http.Response{Body: io.NopCloser(bytes.NewBufferString(body))},
+			nil,
+		).Once()
+	}
+
+	mockQuery(`{"data":{"sequences":{"results":[{"_id":"seq1","title":"Map and Territory"}]}}}`)
+	mockQuery(`{"data":{"sequence":{"result":{"_id":"seq1","title":"Map and Territory","chapters":[
+		{"posts":[{"title":"What Do We Mean By Rationality?","pageUrl":"https://www.lesswrong.com/posts/p1/rationality"}]},
+		{"posts":[{"title":"Feeling Rational","pageUrl":"https://www.lesswrong.com/posts/p2/feeling-rational"}]}
+	]}}}}`)
+	mockQuery(`{"data":{"post":{"result":{"title":"What Do We Mean By Rationality?","pageUrl":"https://www.lesswrong.com/posts/p1/rationality","htmlBody":"<p>Epistemic rationality.</p>"}}}}`)
+	mockQuery(`{"data":{"post":{"result":{"title":"Feeling Rational","pageUrl":"https://www.lesswrong.com/posts/p2/feeling-rational","htmlBody":"<p>Emotions.</p>"}}}}`)
+
+	telegram, botAPI := newTelegramStub(t)
+
+	tgbot, err := New(Options{BotAPI: botAPI, HTTPClient: httpClient})
+	require.NoError(t, err)
+
+	command := func(text string) string {
+		sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: userID},
+				Chat:     &tgbotapi.Chat{ID: userID},
+				Text:     text,
+				Entities: &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len(text)}},
+			},
+		})
+		require.NoError(t, err)
+
+		return sent.Text
+	}
+
+	_, _, err = tgbot.ChangeSource(context.TODO(), userID, models.SourceSlate)
+	require.NoError(t, err)
+
+	require.Equal(t, "Sequences are not supported for https://slatestarcodex.com", command("/sequence"))
+
+	require.Equal(t, "Choose a sequence with /sequence first", command("/next"))
+
+	_, _, err = tgbot.ChangeSource(context.TODO(), userID, models.SourceLesswrong)
+	require.NoError(t, err)
+
+	require.Equal(t, "📚 Choose a sequence from https://lesswrong.com and read it post by post with /next", command("/sequence"))
+
+	messages := telegram.Sent("sendMessage")
+
+	var markup tgbotapi.InlineKeyboardMarkup
+	require.NoError(t, json.Unmarshal([]byte(messages[len(messages)-1].Get("reply_markup")), &markup))
+	require.Len(t, markup.InlineKeyboard, 1)
+	require.Equal(t, "Map and Territory", markup.InlineKeyboard[0][0].Text)
+	require.Equal(t, sequenceCallbackPrefix+"4:seq1", *markup.InlineKeyboard[0][0].CallbackData)
+
+	sent, err := tgbot.MessageHandler(context.TODO(), tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "callback",
+			From:    &tgbotapi.User{ID: userID},
+			Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: userID}},
+			Data:    *markup.InlineKeyboard[0][0].CallbackData,
+		},
+	})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(sent.Text, "📚 Map and Territory, 1/2\n\n"), sent.Text)
+	require.Contains(t, sent.Text, "What Do We Mean By Rationality?")
+	require.True(t, strings.HasSuffix(sent.Text, "Send /next to read the next post"), sent.Text)
+
+	// Position is remembered, sequence is cached.
+	require.Contains(t, command("/sequence"), "You're reading Map and Territory, post 1/2")
+
+	text := command("/next")
+	require.True(t, strings.HasPrefix(text, "📚 Map and Territory, 2/2\n\n"), text)
+	require.Contains(t, text, "Feeling Rational")
+	require.NotContains(t, text, "/next")
+
+	require.Equal(t, "🎉 You've finished Map and Territory. Choose another one with /sequence", command("/next"))
+
+	history, err := tgbot.History(context.TODO(), userID)
+	require.NoError(t, err)
+	require.Contains(t, history, "🕘 Last 2 read posts:")
+
+	httpClient.AssertExpectations(t)
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gocolly/colly"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+// As https://slatestarcodex.com top posts won't change anymore it's much more effecient to return hardcoded list.
+const MessageTopSlate = `🏆 Top posts from https://slatestarcodex.com
+
+1. <a href="https://slatestarcodex.com/2014/12/12/beware-the-man-of-one-study/">Beware The Man Of One Study</a>
+
+2. <a href="https://slatestarcodex.com/2014/07/30/meditations-on-moloch/">Meditations on Moloch</a>
+
+3. <a href="https://slatestarcodex.com/2014/09/30/i-can-tolerate-anything-except-the-outgroup/">I Can Tolerate Anything Except The Outgroup</a>
+
+4. <a href="https://slatestarcodex.com/2016/04/27/book-review-albions-seed/">Book Review: Albion's Seed</a>
+
+5. <a href="https://slatestarcodex.com/2014/12/19/nobody-is-perfect-everything-is-commensurable/">Nobody Is Perfect, Everything Is Commensurable</a>
+
+6. <a href="https://slatestarcodex.com/2014/04/28/the-control-group-is-out-of-control/">The Control Group Is Out Of Control</a>
+
+7. <a href="https://slatestarcodex.com/2017/02/09/considerations-on-cost-disease/">Considerations On Cost Disease</a>
+
+8. <a href="https://slatestarcodex.com/2014/06/07/archipelago-and-atomic-communitarianism/">Archipelago And Atomic Communitarianism</a>
+
+9. <a href="https://slatestarcodex.com/2014/11/21/the-categories-were-made-for-man-not-man-for-the-categories/">The Categories Were Made For Man, Not Man For The Categories</a>
+
+10. <a href="https://slatestarcodex.com/2013/07/17/who-by-very-slow-decay/">Who By Very Slow Decay</a>`
+
+type slateSource struct {
+	bot *Bot
+}
+
+func (s *slateSource) ID() models.Source {
+	return models.SourceSlate
+}
+
+func (s *slateSource) Name() string {
+	return "Slate Star Codex"
+}
+
+func (s *slateSource) Domain() string {
+	return models.DomainSlate
+}
+
+func (s *slateSource) List(ctx context.Context) ([]models.Post, error) {
+	return s.bot.cachedPosts(ctx, "slatestarcodex", func() ([]models.Post, error) {
+		var posts []models.Post
+
+		archivesCollector := s.bot.newCollector()
+
+		archivesCollector.OnHTML("a[href][rel=bookmark]", func(e *colly.HTMLElement) {
+			posts = append(posts, models.Post{
+				Title: e.Text,
+				URL:   e.Attr("href"),
+			})
+		})
+
+		if err := archivesCollector.Visit("https://slatestarcodex.com/archives/"); err != nil {
+			return nil, fmt.Errorf("get slatestarcodex posts failed: %s", err)
+		}
+
+		return posts, nil
+	})
+}
+
+func (s *slateSource) Top(_ context.Context) (string, error) {
+	return MessageTopSlate, nil
+}
+
+func (s *slateSource) Random(ctx context.Context) (models.Post, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	i := s.bot.randomInt(len(posts))
+
+	return s.Fetch(ctx, posts[i])
+}
+
+func (s *slateSource) Post(ctx context.Context, url string) (models.Post, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return s.Fetch(ctx, findPost(posts, url))
+}
+
+// Fetch returns post from List with content.
+func (s *slateSource) Fetch(_ context.Context, post models.Post) (models.Post, error) {
+	postCollector := s.bot.newCollector()
+
+	postCollector.OnHTML("div.pjgm-postcontent", func(e *colly.HTMLElement) {
+		post.HTML, _ = e.DOM.Html()
+	})
+
+	if err := postCollector.Visit(post.URL); err != nil {
+		return models.Post{}, fmt.Errorf("get slatestarcodex post failed: %s", err)
+	}
+
+	return post, nil
+}
@@ -1,54 +1,179 @@
 package bot
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"log"
+	"net/url"
+	"strings"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 
 	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
 )
 
-var sourceKeyboard = tgbotapi.NewInlineKeyboardMarkup(
-	tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Lesswrong.ru", "1"),
-	),
-	tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Slate Start Codex", "2"),
-	),
-	tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Astral Codex Ten", "3"),
-	),
-	tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("Lesswrong.com", "4"),
-	),
+type (
+	// Source is a site the bot reads posts from.
+	Source interface {
+		// ID is stored in user settings and used as inline keyboard data, so it must never change.
+		ID() models.Source
+		// Name is a human readable name shown in keyboards and help.
+		Name() string
+		// Domain is a site domain without scheme.
+		Domain() string
+		// Top returns formatted message with top posts.
+		Top(ctx context.Context) (string, error)
+		// Random returns random post with content.
+		Random(ctx context.Context) (models.Post, error)
+		// Post returns post with content by its url.
+		Post(ctx context.Context, url string) (models.Post, error)
+		// List returns known posts without their content.
+		List(ctx context.Context) ([]models.Post, error)
+	}
+
+	// textLinker is implemented by sources which post urls are unreadable (e.g. urlencoded cyrillic),
+	// so post url is shown as link with post title.
+	textLinker interface {
+		TextLink() bool
+	}
+
+	// Registry keeps sources in registration order. First registered source is the default one.
+	Registry struct {
+		sources []Source
+		byID    map[models.Source]Source
+	}
 )
 
-func (b *Bot) ChangeSource(ctx context.Context, userID int, newSource models.Source) (string, interface{}, error) {
-	key := fmt.Sprintf("source:%d", userID)
+func NewRegistry() *Registry {
+	return &Registry{
+		byID: make(map[models.Source]Source),
+	}
+}
 
-	sourceValue, err := b.storage.Get(ctx, key)
+func (r *Registry) Register(source Source) error {
+	if source.ID() == "" {
+		return fmt.Errorf("source id is empty, domain: %s", source.Domain())
+	}
+
+	// Telegram limits inline keyboard callback data to 64 bytes.
+	if len(source.ID()) > 64 {
+		return fmt.Errorf("source id is too long: %s", source.ID())
+	}
+
+	if _, ok := r.byID[source.ID()]; ok {
+		return fmt.Errorf("source already registered: %s", source.ID())
+	}
+
+	r.sources = append(r.sources, source)
+	r.byID[source.ID()] = source
+
+	return nil
+}
+
+func (r *Registry) Get(id models.Source) (Source, bool) {
+	source, ok := r.byID[id]
+	return source, ok
+}
+
+// Find returns source which post url belongs to.
+func (r *Registry) Find(postURL string) (Source, bool) {
+	u, err := url.Parse(postURL)
 	if err != nil {
-		log.Printf("[ERROR] Get source failed: %s, key: %s", err, key)
+		return nil, false
 	}
 
-	source := models.Source(sourceValue)
-	if !source.IsValid() {
-		source = models.SourceLesswrongRu
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+
+	for _, source := range r.sources {
+		if strings.TrimPrefix(source.Domain(), "www.") == host {
+			return source, true
+		}
 	}
 
+	return nil, false
+}
+
+func (r *Registry) All() []Source {
+	return r.sources
+}
+
+func (r *Registry) Default() Source {
+	if len(r.sources) == 0 {
+		return nil
+	}
+
+	return r.sources[0]
+}
+
+func sourceURL(source Source) string {
+	return "https://" + source.Domain()
+}
+
+func (b *Bot) ChangeSource(ctx context.Context, userID int, newSource models.Source) (string, interface{}, error) {
+	key := fmt.Sprintf("source:%d", userID)
+	source := b.userSource(ctx, userID)
+
 	if newSource == "" {
-		return "Current source is " + source.String(), sourceKeyboard, nil
+		return "Current source is " + sourceURL(source), b.sourceKeyboard(), nil
 	}
 
-	if !newSource.IsValid() {
-		return "New source is invalid. Current source is " + source.String(), sourceKeyboard, nil
+	changed, ok := b.sources.Get(newSource)
+	if !ok {
+		return "New source is invalid. Current source is " + sourceURL(source), b.sourceKeyboard(), nil
 	}
 
 	if err := b.storage.Set(ctx, key, newSource.Value(), 0); err != nil {
 		return "", nil, fmt.Errorf("set source failed: %s, key: %s, source: %s", err, key, newSource)
 	}
 
-	return "Changed source to " + newSource.String(), nil, nil
+	return "Changed source to " + sourceURL(changed), nil, nil
+}
+
+// userSource returns source chosen by user or default one.
+func (b *Bot) userSource(ctx context.Context, userID int) Source {
+	key := fmt.Sprintf("source:%d", userID)
+
+	sourceValue, err := b.storage.Get(ctx, key)
+	if err != nil {
+		log.Printf("[ERROR] Get source failed: %s, key: %s", err, key)
+	}
+
+	source, ok := b.sources.Get(models.Source(sourceValue))
+	if !ok {
+		return b.sources.Default()
+	}
+
+	return source
+}
+
+func (b *Bot) sourceKeyboard() tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	for _, source := range b.sources.All() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(source.Name(), source.ID().Value()),
+		))
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+func (b *Bot) helpMessage() string {
+	text := bytes.NewBufferString("🤖 I'm a bot for reading posts:\n\nCommands:\n\n/top - Top posts\n\n/random - Read random post\n\n/read - Read full post by url page by page\n\n/search - Search posts across all sources\n\n/saved - Saved posts\n\n/history - Last read posts\n\n/sequence - Read sequence post by post, /next - next post\n\n/course - Get one post of a sequence per day\n\n/instantview - Send random posts as Telegra.ph Instant View\n\n/source - Change source:\n\n")
+
+	for i, source := range b.sources.All() {
+		text.WriteString(fmt.Sprintf("  %d. %s", i+1, renderer.Link(sourceURL(source), source.Name())))
+
+		if i == 0 {
+			text.WriteString(" (default)")
+		}
+
+		text.WriteString("\n")
+	}
+
+	text.WriteString("\n/subscribe - Subscribe to new posts from current source\n\n/unsubscribe - Unsubscribe from new posts\n\n/digest - Bundle new posts into daily or weekly digest\n\n/autopost - Publish new posts to channel automatically\n\n/help - Help")
+
+	return text.String()
 }
@@ -32,8 +32,8 @@ func TestChangeSource(t *testing.T) {
 				newSource: "",
 			},
 			want: func(t *testing.T, text string, keyboard interface{}) {
-				require.Equal(t, "Current source is "+models.SourceLesswrongRu.String(), text)
-				require.Equal(t, sourceKeyboard, keyboard)
+				require.Equal(t, "Current source is https://lesswrong.ru", text)
+				require.Equal(t, tgbot.sourceKeyboard(), keyboard)
 
 				source, err := tgbot.storage.Get(context.TODO(), fmt.Sprintf("source:%d", userID))
 				require.NoError(t, err)
@@ -47,8 +47,8 @@ func TestChangeSource(t *testing.T) {
 				newSource: "invalid",
 			},
 			want: func(t *testing.T, text string, keyboard interface{}) {
-				require.Equal(t, "New source is invalid. Current source is "+models.SourceLesswrongRu.String(), text)
-				require.Equal(t, sourceKeyboard, keyboard)
+				require.Equal(t, "New source is invalid. Current source is https://lesswrong.ru", text)
+				require.Equal(t, tgbot.sourceKeyboard(), keyboard)
 
 				source, err := tgbot.storage.Get(context.TODO(), fmt.Sprintf("source:%d", userID))
 				require.NoError(t, err)
@@ -62,7 +62,7 @@ func TestChangeSource(t *testing.T) {
 				newSource: models.SourceAstral,
 			},
 			want: func(t *testing.T, text string, keyboard interface{}) {
-				require.Equal(t, "Changed source to "+models.SourceAstral.String(), text)
+				require.Equal(t, "Changed source to https://astralcodexten.substack.com", text)
 				require.Nil(t, keyboard)
 
 				source, err := tgbot.storage.Get(context.TODO(), fmt.Sprintf("source:%d", userID))
@@ -81,3 +81,83 @@ func TestChangeSource(t *testing.T) {
 		})
 	}
 }
+
+func TestRegistry(t *testing.T) {
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}})
+	require.NoError(t, err)
+
+	registry := NewRegistry()
+	require.Nil(t, registry.Default())
+
+	err = registry.Register(&slateSource{bot: tgbot})
+	require.NoError(t, err)
+
+	err = registry.Register(newSubstackSource(tgbot, models.SourceAstral, "Astral Codex Ten", models.DomainAstral))
+	require.NoError(t, err)
+
+	err = registry.Register(&slateSource{bot: tgbot})
+	require.EqualError(t, err, "source already registered: 2")
+
+	source, ok := registry.Get(models.SourceAstral)
+	require.True(t, ok)
+	require.Equal(t, models.DomainAstral, source.Domain())
+
+	_, ok = registry.Get(models.SourceLesswrong)
+	require.False(t, ok)
+
+	require.Equal(t, models.SourceSlate, registry.Default().ID())
+	require.Len(t, registry.All(), 2)
+}
+
+func TestHelpMessage(t *testing.T) {
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}})
+	require.NoError(t, err)
+
+	want := `🤖 I'm a bot for reading posts:
+
+Commands:
+
+/top - Top posts
+
+/random - Read random post
+
+/read - Read full post by url page by page
+
+/search - Search posts across all sources
+
+/saved - Saved posts
+
+/history - Last read posts
+
+/sequence - Read sequence post by post, /next - next post
+
+/course - Get one post of a sequence per day
+
+/instantview - Send random posts as Telegra.ph Instant View
+
+/source - Change source:
+
+  1. <a href="https://lesswrong.ru">Lesswrong.ru</a> (default)
+  2. <a href="https://slatestarcodex.com">Slate Star Codex</a>
+  3. <a href="https://astralcodexten.substack.com">Astral Codex Ten</a>
+  4. <a href="https://lesswrong.com">Lesswrong.com</a>
+  5. <a href="https://forum.effectivealtruism.org">EA Forum</a>
+  6. <a href="https://alignmentforum.org">Alignment Forum</a>
+
+/subscribe - Subscribe to new posts from current source
+
+/unsubscribe - Unsubscribe from new posts
+
+/digest - Bundle new posts into daily or weekly digest
+
+/autopost - Publish new posts to channel automatically
+
+/help - Help`
+
+	require.Equal(t, want, tgbot.helpMessage())
+
+	keyboard := tgbot.sourceKeyboard()
+	require.Len(t, keyboard.InlineKeyboard, 6)
+	require.Equal(t, "Astral Codex Ten", keyboard.InlineKeyboard[2][0].Text)
+	require.Equal(t, "3", *keyboard.InlineKeyboard[2][0].CallbackData)
+}
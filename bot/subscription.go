@@ -0,0 +1,292 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+// seenRetention is how long post url is kept in dedupe state after it disappeared from latest posts.
+const seenRetention = 30 * 24 * time.Hour
+
+// Subscribable is implemented by sources which can be polled for new posts.
+type Subscribable interface {
+	// Latest returns newest posts ordered from newest to oldest bypassing cache.
+	Latest(ctx context.Context) ([]models.Post, error)
+}
+
+func (b *Bot) Subscribe(ctx context.Context, userID int, chatID int64, sourceID models.Source) (string, error) {
+	source := b.userSource(ctx, userID)
+
+	if sourceID != "" {
+		var ok bool
+
+		source, ok = b.sources.Get(sourceID)
+		if !ok {
+			return "Source is invalid", nil
+		}
+	}
+
+	if _, ok := source.(Subscribable); !ok {
+		return "Subscriptions are not supported for " + sourceURL(source), nil
+	}
+
+	key := subscribersKey(source.ID())
+	member := strconv.FormatInt(chatID, 10)
+
+	subscribed, err := b.storage.SIsMember(ctx, key, member)
+	if err != nil {
+		return "", fmt.Errorf("check subscriber failed: %s, key: %s", err, key)
+	}
+
+	if subscribed {
+		return "Already subscribed to " + sourceURL(source), nil
+	}
+
+	if err := b.storage.SAdd(ctx, key, member); err != nil {
+		return "", fmt.Errorf("add subscriber failed: %s, key: %s", err, key)
+	}
+
+	return "Subscribed to new posts from " + sourceURL(source), nil
+}
+
+// Unsubscribe removes chat from subscribers of source or from all sources if source is empty.
+func (b *Bot) Unsubscribe(ctx context.Context, chatID int64, sourceID models.Source) (string, error) {
+	sources := b.sources.All()
+
+	if sourceID != "" {
+		source, ok := b.sources.Get(sourceID)
+		if !ok {
+			return "Source is invalid", nil
+		}
+
+		sources = []Source{source}
+	}
+
+	var unsubscribed []Source
+
+	for _, source := range sources {
+		if _, ok := source.(Subscribable); !ok {
+			continue
+		}
+
+		key := subscribersKey(source.ID())
+		member := strconv.FormatInt(chatID, 10)
+
+		subscribed, err := b.storage.SIsMember(ctx, key, member)
+		if err != nil {
+			return "", fmt.Errorf("check subscriber failed: %s, key: %s", err, key)
+		}
+
+		if !subscribed {
+			continue
+		}
+
+		if err := b.storage.SRem(ctx, key, member); err != nil {
+			return "", fmt.Errorf("remove subscriber failed: %s, key: %s", err, key)
+		}
+
+		unsubscribed = append(unsubscribed, source)
+	}
+
+	switch {
+	case len(unsubscribed) == 0:
+		return "You have no subscriptions", nil
+	case sourceID == "":
+		return "Unsubscribed from all sources", nil
+	default:
+		return "Unsubscribed from " + sourceURL(unsubscribed[0]), nil
+	}
+}
+
+// RunPoller polls subscribed sources for new posts every interval until context is done.
+func (b *Bot) RunPoller(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pollCtx, cancel := context.WithTimeout(ctx, interval)
+
+		if err := b.PollSubscriptions(pollCtx); err != nil {
+			log.Printf("[ERROR] Poll subscriptions failed: %s", err)
+		}
+
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PollSubscriptions delivers posts which weren't seen before to subscribers of each source.
+// Autopost channels get the same new posts.
+func (b *Bot) PollSubscriptions(ctx context.Context) error {
+	var errs []error
+
+	channels, err := b.autopostChannels(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, source := range b.sources.All() {
+		subscribable, ok := source.(Subscribable)
+		if !ok {
+			continue
+		}
+
+		subscribers, err := b.subscribers(ctx, source.ID())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		autopost := autopostSubscribers(channels, source.ID())
+
+		if len(subscribers) == 0 && len(autopost) == 0 {
+			continue
+		}
+
+		posts, err := b.unseenPosts(ctx, source, subscribable)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, post := range posts {
+			for _, chatID := range subscribers {
+				if err := b.deliverNewPost(ctx, chatID, source, post); err != nil {
+					errs = append(errs, err)
+				}
+			}
+
+			for _, channel := range autopost {
+				if err := b.autopostNewPost(ctx, channel, source, post); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+
+	errs = append(errs, b.flushAutopostQueues(ctx, channels)...)
+
+	return errors.Join(errs...)
+}
+
+// unseenPosts returns latest posts which weren't seen before ordered from oldest to newest.
+// Dedupe state is saved before posts are sent, so restart never sends the same post twice.
+func (b *Bot) unseenPosts(ctx context.Context, source Source, subscribable Subscribable) ([]models.Post, error) {
+	key := "seen:" + source.ID().Value()
+
+	seenCached, err := b.storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("get seen posts failed: %s, key: %s", err, key)
+	}
+
+	seen := make(map[string]time.Time)
+
+	if seenCached != "" {
+		if err := json.Unmarshal([]byte(seenCached), &seen); err != nil {
+			return nil, fmt.Errorf("unmarshal seen posts failed: %s, key: %s", err, key)
+		}
+	}
+
+	latest, err := subscribable.Latest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get %s latest posts failed: %s", source.Domain(), err)
+	}
+
+	// Don't flood subscribers with old posts on the first poll, just remember them.
+	firstPoll := len(seen) == 0
+	now := b.now()
+
+	var unseen []models.Post
+
+	for _, post := range latest {
+		if _, ok := seen[post.URL]; !ok {
+			unseen = append([]models.Post{post}, unseen...)
+		}
+
+		seen[post.URL] = now
+	}
+
+	for url, seenAt := range seen {
+		if now.Sub(seenAt) > seenRetention {
+			delete(seen, url)
+		}
+	}
+
+	seenCache, err := json.Marshal(seen)
+	if err != nil {
+		return nil, fmt.Errorf("marshal seen posts failed: %s, key: %s", err, key)
+	}
+
+	if err := b.storage.Set(ctx, key, string(seenCache), 0); err != nil {
+		return nil, fmt.Errorf("set seen posts failed: %s, key: %s", err, key)
+	}
+
+	if firstPoll {
+		return nil, nil
+	}
+
+	return unseen, nil
+}
+
+func (b *Bot) sendNewPost(ctx context.Context, chatID int64, source Source, post models.Post) error {
+	keyboard, err := b.postKeyboard(ctx, post)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🔔 New post from %s\n\n%s", renderer.Escape(sourceURL(source)), renderer.Link(post.URL, post.Title)))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+
+	if _, err := b.botAPI.Send(msg); err != nil {
+		return fmt.Errorf("send new post failed: %s, chat: %d, url: %s", err, chatID, post.URL)
+	}
+
+	return nil
+}
+
+func (b *Bot) subscribers(ctx context.Context, sourceID models.Source) ([]int64, error) {
+	key := subscribersKey(sourceID)
+
+	members, err := b.storage.SMembers(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("get subscribers failed: %s, key: %s", err, key)
+	}
+
+	subscribers := make([]int64, 0, len(members))
+
+	for _, member := range members {
+		chatID, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse subscriber failed: %s, key: %s", err, key)
+		}
+
+		subscribers = append(subscribers, chatID)
+	}
+
+	// Set members have no order, so sort them to broadcast in a stable order.
+	sort.Slice(subscribers, func(i, j int) bool { return subscribers[i] < subscribers[j] })
+
+	return subscribers, nil
+}
+
+// subscribersKey is a key of set with ids of chats subscribed to source.
+func subscribersKey(sourceID models.Source) string {
+	return "subscribers:" + sourceID.Value()
+}
@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+func TestSubscriptions(t *testing.T) {
+	file, err := os.ReadFile("testdata/astral_new_posts.json")
+	require.NoError(t, err)
+
+	var archive []models.AstralPost
+	require.NoError(t, json.Unmarshal(file, &archive))
+
+	latest := archive
+
+	httpClient := &mocks.HTTPClient{}
+
+	httpClient.On("Get", context.TODO(), "https://astralcodexten.substack.com/api/v1/archive?sort=new&limit=12&offset=0").Return(
+		func(context.Context, string) *http.Response {
+			body, err := json.Marshal(latest)
+			require.NoError(t, err)
+
+			return &http.Response{Body: io.NopCloser(bytes.NewBuffer(body))}
+		},
+		nil,
+	)
+
+	now := time.Date(2021, 5, 1, 10, 0, 0, 0, time.UTC)
+	storage := memory.NewStorage()
+	telegram, botAPI := newTelegramStub(t)
+
+	newBot := func() *Bot {
+		tgbot, err := New(Options{
+			BotAPI:     botAPI,
+			HTTPClient: httpClient,
+			Storage:    storage,
+			Now:        func() time.Time { return now },
+		})
+		require.NoError(t, err)
+
+		return tgbot
+	}
+
+	tgbot := newBot()
+
+	text, err := tgbot.Subscribe(context.TODO(), 10, 10, models.SourceAstral)
+	require.NoError(t, err)
+	require.Equal(t, "Subscribed to new posts from https://astralcodexten.substack.com", text)
+
+	text, err = tgbot.Subscribe(context.TODO(), 10, 10, models.SourceAstral)
+	require.NoError(t, err)
+	require.Equal(t, "Already subscribed to https://astralcodexten.substack.com", text)
+
+	text, err = tgbot.Subscribe(context.TODO(), 10, 10, models.SourceSlate)
+	require.NoError(t, err)
+	require.Equal(t, "Subscriptions are not supported for https://slatestarcodex.com", text)
+
+	text, err = tgbot.Subscribe(context.TODO(), 10, 10, "invalid")
+	require.NoError(t, err)
+	require.Equal(t, "Source is invalid", text)
+
+	// User 20 has Astral Codex Ten as current source.
+	require.NoError(t, storage.Set(context.TODO(), "source:20", models.SourceAstral.Value(), 0))
+
+	text, err = tgbot.Subscribe(context.TODO(), 20, 20, "")
+	require.NoError(t, err)
+	require.Equal(t, "Subscribed to new posts from https://astralcodexten.substack.com", text)
+
+	t.Run("Should only remember posts on the first poll", func(t *testing.T) {
+		require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+		require.Empty(t, telegram.Sent("sendMessage"))
+	})
+
+	t.Run("Should send new post to all subscribers", func(t *testing.T) {
+		latest = append([]models.AstralPost{{
+			Title:        "Still Alive",
+			CanonicalURL: "https://astralcodexten.substack.com/p/still-alive",
+			Audience:     "everyone",
+		}}, archive...)
+
+		require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+
+		sent := telegram.Sent("sendMessage")
+		require.Len(t, sent, 2)
+		require.Equal(t, "10", sent[0].Get("chat_id"))
+		require.Equal(t, "20", sent[1].Get("chat_id"))
+		require.Equal(t, "🔔 New post from https://astralcodexten.substack.com\n\n<a href=\"https://astralcodexten.substack.com/p/still-alive\">Still Alive</a>", sent[0].Get("text"))
+		require.Equal(t, "HTML", sent[0].Get("parse_mode"))
+		telegram.Reset()
+	})
+
+	t.Run("Should not send the same post after restart", func(t *testing.T) {
+		require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+		require.NoError(t, newBot().PollSubscriptions(context.TODO()))
+		require.Empty(t, telegram.Sent("sendMessage"))
+	})
+
+	t.Run("Should forget posts which are gone from latest posts for too long", func(t *testing.T) {
+		latest = latest[:1]
+		now = now.Add(seenRetention + time.Hour)
+
+		require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+		require.Empty(t, telegram.Sent("sendMessage"))
+
+		seen, err := storage.Get(context.TODO(), "seen:"+models.SourceAstral.Value())
+		require.NoError(t, err)
+		require.JSONEq(t, `{"https://astralcodexten.substack.com/p/still-alive":"2021-05-31T11:00:00Z"}`, seen)
+	})
+
+	t.Run("Should not send posts to unsubscribed chat", func(t *testing.T) {
+		text, err := tgbot.Unsubscribe(context.TODO(), 10, "")
+		require.NoError(t, err)
+		require.Equal(t, "Unsubscribed from all sources", text)
+
+		text, err = tgbot.Unsubscribe(context.TODO(), 10, models.SourceAstral)
+		require.NoError(t, err)
+		require.Equal(t, "You have no subscriptions", text)
+
+		latest = append([]models.AstralPost{archive[0]}, latest...)
+
+		require.NoError(t, tgbot.PollSubscriptions(context.TODO()))
+
+		sent := telegram.Sent("sendMessage")
+		require.Len(t, sent, 1)
+		require.Equal(t, "20", sent[0].Get("chat_id"))
+	})
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+var errRateLimited = errors.New("rate limited")
+
+// substackSource reads posts from any Substack publication using its public API.
+type substackSource struct {
+	bot  *Bot
+	id   models.Source
+	name string
+	host string
+}
+
+func newSubstackSource(b *Bot, id models.Source, name, host string) *substackSource {
+	return &substackSource{
+		bot:  b,
+		id:   id,
+		name: name,
+		host: host,
+	}
+}
+
+func (s *substackSource) ID() models.Source {
+	return s.id
+}
+
+func (s *substackSource) Name() string {
+	return s.name
+}
+
+func (s *substackSource) Domain() string {
+	return s.host
+}
+
+// publication returns short publication name used in cache keys and logs, e.g. astralcodexten.
+func (s *substackSource) publication() string {
+	return strings.TrimSuffix(s.host, ".substack.com")
+}
+
+func (s *substackSource) List(ctx context.Context) ([]models.Post, error) {
+	publication := s.publication()
+
+	return s.bot.cachedPosts(ctx, publication, func() ([]models.Post, error) {
+		var posts []models.Post
+
+		// As substack limits list to 12 posts in one request we fetch all posts using offset.
+		for offset := 0; true; offset += models.DefaultLimit {
+			uri := fmt.Sprintf("https://%s/api/v1/archive?sort=new&limit=%d&offset=%d",
+				s.host,
+				models.DefaultLimit,
+				offset,
+			)
+
+			httpResponse, err := s.bot.httpClient.Get(ctx, uri)
+			if err != nil {
+				log.Printf("[ERROR] Get %s posts failed: %s", publication, err)
+				break
+			}
+
+			var newPosts []models.AstralPost
+
+			if err := s.bot.handleResponse(httpResponse, &newPosts); err != nil {
+				log.Printf("[ERROR] handle %s posts response: %s", publication, err)
+				// If still rate limited after retries and we have no posts yet, return a helpful error
+				if httpResponse.StatusCode == 429 && len(posts) == 0 {
+					return nil, fmt.Errorf("%s API is temporarily rate limited, please try again later", publication)
+				}
+				break
+			}
+
+			if len(newPosts) == 0 {
+				break
+			}
+
+			for _, astralPost := range newPosts {
+				if astralPost.Audience != "only_paid" {
+					posts = append(posts, astralPost.AsPost())
+				}
+			}
+		}
+
+		return posts, nil
+	})
+}
+
+// Latest returns first page of newest posts bypassing cache.
+func (s *substackSource) Latest(ctx context.Context) ([]models.Post, error) {
+	publication := s.publication()
+
+	uri := fmt.Sprintf("https://%s/api/v1/archive?sort=new&limit=%d&offset=0", s.host, models.DefaultLimit)
+
+	httpResponse, err := s.bot.httpClient.Get(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("get %s new posts failed: %s", publication, err)
+	}
+
+	var newPosts []models.AstralPost
+
+	if err := s.bot.handleResponse(httpResponse, &newPosts); err != nil {
+		return nil, fmt.Errorf("handle %s new posts response: %s", publication, err)
+	}
+
+	var posts []models.Post
+
+	for _, astralPost := range newPosts {
+		if astralPost.Audience != "only_paid" {
+			posts = append(posts, astralPost.AsPost())
+		}
+	}
+
+	return posts, nil
+}
+
+func (s *substackSource) Top(ctx context.Context) (string, error) {
+	publication := s.publication()
+
+	httpResponse, err := s.bot.httpClient.Get(ctx, fmt.Sprintf("https://%s/api/v1/archive?sort=top&limit=10", s.host))
+	if err != nil {
+		return "", fmt.Errorf("get %s posts failed: %s", publication, err)
+	}
+
+	var topPosts []models.AstralPost
+
+	if err := s.bot.handleResponse(httpResponse, &topPosts); err != nil {
+		return "", fmt.Errorf("handle %s top posts response: %s", publication, err)
+	}
+
+	text := bytes.NewBufferString(fmt.Sprintf("🏆 Top posts from https://%s\n\n", renderer.Escape(s.host)))
+
+	for i, post := range topPosts {
+		if post.Audience == "only_paid" {
+			continue
+		}
+
+		text.WriteString(fmt.Sprintf("%d. %s\n\n", i+1, renderer.Link(post.CanonicalURL, post.Title)))
+
+		if post.Subtitle != "" && post.Subtitle != "..." {
+			text.WriteString(fmt.Sprintf("    %s\n\n", renderer.Escape(post.Subtitle)))
+		}
+	}
+
+	return text.String(), nil
+}
+
+func (s *substackSource) Random(ctx context.Context) (models.Post, error) {
+	posts, err := s.List(ctx)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	i := s.bot.randomInt(len(posts))
+
+	return s.Fetch(ctx, posts[i])
+}
+
+// Fetch returns post from List with content.
+func (s *substackSource) Fetch(ctx context.Context, post models.Post) (models.Post, error) {
+	astralPost, err := s.fetch(ctx, post.Slug)
+	if err != nil {
+		// Handle rate limiting gracefully - return a basic post with available info
+		if errors.Is(err, errRateLimited) {
+			post.HTML = "<p>Content temporarily unavailable due to API rate limiting. Please visit the link above to read the full post.</p>"
+			return post, nil
+		}
+
+		return models.Post{}, err
+	}
+
+	return astralPost.AsPost(), nil
+}
+
+// Post returns post by url in format https://host/p/slug.
+func (s *substackSource) Post(ctx context.Context, postURL string) (models.Post, error) {
+	u, err := url.Parse(postURL)
+	if err != nil {
+		return models.Post{}, fmt.Errorf("parse %s post url failed: %s", s.publication(), err)
+	}
+
+	slug := strings.TrimPrefix(u.Path, "/p/")
+	if slug == u.Path || slug == "" {
+		return models.Post{}, fmt.Errorf("%s post url has no slug: %s", s.publication(), postURL)
+	}
+
+	astralPost, err := s.fetch(ctx, slug)
+	if err != nil {
+		return models.Post{}, err
+	}
+
+	return astralPost.AsPost(), nil
+}
+
+func (s *substackSource) fetch(ctx context.Context, slug string) (models.AstralPost, error) {
+	publication := s.publication()
+
+	httpResponse, err := s.bot.httpClient.Get(ctx, fmt.Sprintf("https://%s/api/v1/posts/%s", s.host, slug))
+	if err != nil {
+		return models.AstralPost{}, fmt.Errorf("get %s post failed: %s", publication, err)
+	}
+
+	var astralPost models.AstralPost
+
+	if err := s.bot.handleResponse(httpResponse, &astralPost); err != nil {
+		if httpResponse.StatusCode == http.StatusTooManyRequests {
+			return models.AstralPost{}, fmt.Errorf("handle %s post response: %w", publication, errRateLimited)
+		}
+
+		return models.AstralPost{}, fmt.Errorf("handle %s post response: %s", publication, err)
+	}
+
+	return astralPost, nil
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/models"
+)
+
+func TestSubstackSource(t *testing.T) {
+	const host = "thezvi.substack.com"
+
+	fixture := func(t *testing.T, name string) *http.Response {
+		file, err := os.ReadFile(name)
+		require.NoError(t, err)
+
+		return &http.Response{Body: io.NopCloser(bytes.NewBuffer(file))}
+	}
+
+	httpClient := &mocks.HTTPClient{}
+
+	httpClient.On("Get", context.TODO(), "https://thezvi.substack.com/api/v1/archive?sort=top&limit=10").Return(
+		fixture(t, "testdata/astral_top_posts.json"),
+		nil,
+	)
+
+	httpClient.On("Get", context.TODO(), "https://thezvi.substack.com/api/v1/archive?sort=new&limit=12&offset=0").Return(
+		fixture(t, "testdata/astral_new_posts.json"),
+		nil,
+	)
+
+	httpClient.On("Get", context.TODO(), "https://thezvi.substack.com/api/v1/archive?sort=new&limit=12&offset=12").Return(
+		&http.Response{Body: io.NopCloser(bytes.NewBufferString("[]"))},
+		nil,
+	)
+
+	httpClient.On("Get", context.TODO(), "https://thezvi.substack.com/api/v1/posts/a-modest-proposal-for-republicans").Return(
+		fixture(t, "testdata/astral_random_post.json"),
+		nil,
+	)
+
+	tgbot, err := New(Options{
+		BotAPI:     &tgbotapi.BotAPI{},
+		HTTPClient: httpClient,
+		Config: config.Config{
+			Substacks: []config.Substack{{Host: host, Name: "Don't Worry About the Vase"}},
+		},
+	})
+	require.NoError(t, err)
+
+	tgbot.randomInt = func(n int) int {
+		return 0
+	}
+
+	source, ok := tgbot.sources.Get(models.Source(host))
+	require.True(t, ok)
+	require.Equal(t, "Don't Worry About the Vase", source.Name())
+	require.Contains(t, tgbot.helpMessage(), "  7. <a href=\"https://thezvi.substack.com\">Don't Worry About the Vase</a>\n")
+
+	t.Run("Should get top posts from configured publication", func(t *testing.T) {
+		got, err := source.Top(context.TODO())
+		require.NoError(t, err)
+
+		file, err := os.ReadFile("testdata/astral_top_posts.html")
+		require.NoError(t, err)
+
+		want := strings.Replace(string(file), "https://astralcodexten.substack.com\n", "https://thezvi.substack.com\n", 1)
+		require.Equal(t, want, got)
+	})
+
+	t.Run("Should list posts without paid ones and cache them", func(t *testing.T) {
+		posts, err := source.List(context.TODO())
+		require.NoError(t, err)
+		require.Len(t, posts, 14)
+
+		cached, err := tgbot.storage.Get(context.TODO(), "posts:thezvi")
+		require.NoError(t, err)
+		require.NotEmpty(t, cached)
+	})
+
+	t.Run("Should get random post from configured publication", func(t *testing.T) {
+		post, err := source.Random(context.TODO())
+		require.NoError(t, err)
+
+		got, err := tgbot.previewPost(source, post)
+		require.NoError(t, err)
+		requireGolden(t, "testdata/astral_random_post.html", got)
+	})
+}
@@ -0,0 +1,348 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+// telegraphTags are tags allowed by Telegraph. Other tags are unwrapped to their children.
+var telegraphTags = map[string]string{
+	"a": "a", "aside": "aside", "b": "b", "blockquote": "blockquote", "br": "br", "code": "code",
+	"em": "em", "figcaption": "figcaption", "figure": "figure", "hr": "hr", "i": "i", "iframe": "iframe",
+	"img": "img", "li": "li", "ol": "ol", "p": "p", "pre": "pre", "s": "s", "strong": "strong", "u": "u",
+	"ul": "ul", "video": "video", "h1": "h3", "h2": "h3", "h3": "h3", "h4": "h4", "h5": "h4", "h6": "h4",
+	"del": "s", "ins": "u",
+}
+
+// telegraphSkipTags are tags dropped with their content.
+var telegraphSkipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "svg": true, "form": true, "button": true, "input": true,
+}
+
+// TelegraphPage is a page published to Telegraph.
+type TelegraphPage struct {
+	Title      string
+	AuthorName string
+	AuthorURL  string
+	Content    []models.TelegraphNode
+}
+
+// telegraphTokenKey is a key of access token of Telegraph account created by bot.
+const telegraphTokenKey = "telegraph:token"
+
+// TelegraphClient is a client of https://telegra.ph API.
+// Account is created on first page if access token isn't set, its token is kept in storage,
+// so pages are created by the same account after restart.
+// Requests aren't retried by client, as retry of failed request can create the page twice.
+type TelegraphClient struct {
+	httpClient  HTTPClient
+	storage     Storage
+	baseURL     string
+	mu          sync.Mutex
+	accessToken string
+}
+
+func NewTelegraphClient(httpClient HTTPClient, storage Storage, baseURL, accessToken string) *TelegraphClient {
+	return &TelegraphClient{
+		httpClient:  httpClient,
+		storage:     storage,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		accessToken: accessToken,
+	}
+}
+
+// CreatePage creates page and returns its url.
+func (c *TelegraphClient) CreatePage(ctx context.Context, page TelegraphPage) (string, error) {
+	accessToken, err := c.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := json.Marshal(page.Content)
+	if err != nil {
+		return "", fmt.Errorf("marshal telegraph content failed: %s", err)
+	}
+
+	result, err := c.call(ctx, "createPage", url.Values{
+		"access_token": {accessToken},
+		"title":        {page.Title},
+		"author_name":  {page.AuthorName},
+		"author_url":   {page.AuthorURL},
+		"content":      {string(content)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return result.URL, nil
+}
+
+func (c *TelegraphClient) token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.accessToken != "" {
+		return c.accessToken, nil
+	}
+
+	accessToken, err := c.storage.Get(ctx, telegraphTokenKey)
+	if err != nil {
+		return "", fmt.Errorf("get telegraph token failed: %s, key: %s", err, telegraphTokenKey)
+	}
+
+	if accessToken == "" {
+		result, err := c.call(ctx, "createAccount", url.Values{
+			"short_name":  {"LesswrongBot"},
+			"author_name": {"Lesswrong Bot"},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		// Another instance could create account meanwhile, then its token is used.
+		ok, err := c.storage.SetNX(ctx, telegraphTokenKey, result.AccessToken, 0)
+		if err != nil {
+			return "", fmt.Errorf("set telegraph token failed: %s, key: %s", err, telegraphTokenKey)
+		}
+
+		accessToken = result.AccessToken
+
+		if !ok {
+			if accessToken, err = c.storage.Get(ctx, telegraphTokenKey); err != nil {
+				return "", fmt.Errorf("get telegraph token failed: %s, key: %s", err, telegraphTokenKey)
+			}
+		}
+	}
+
+	c.accessToken = accessToken
+
+	return c.accessToken, nil
+}
+
+func (c *TelegraphClient) call(ctx context.Context, method string, params url.Values) (models.TelegraphResult, error) {
+	httpResponse, err := c.httpClient.Post(ctx, c.baseURL+"/"+method, "application/x-www-form-urlencoded", strings.NewReader(params.Encode()))
+	if err != nil {
+		return models.TelegraphResult{}, fmt.Errorf("telegraph %s failed: %s", method, err)
+	}
+
+	defer httpResponse.Body.Close()
+
+	var response models.TelegraphResponse
+
+	if err := json.NewDecoder(httpResponse.Body).Decode(&response); err != nil {
+		return models.TelegraphResult{}, fmt.Errorf("decode telegraph %s response failed: %s", method, err)
+	}
+
+	if !response.OK {
+		return models.TelegraphResult{}, fmt.Errorf("telegraph %s response contains error: %s", method, response.Error)
+	}
+
+	return response.Result, nil
+}
+
+// InstantView changes whether random posts are published to Telegraph and sent as Instant View.
+func (b *Bot) InstantView(ctx context.Context, userID int, args string) (string, error) {
+	key := "instantview:" + strconv.Itoa(userID)
+
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "":
+		if b.instantViewEnabled(ctx, userID) {
+			return "Instant View is on. Use /instantview off to get previews", nil
+		}
+
+		return "Instant View is off. Use /instantview on to read random posts on https://telegra.ph", nil
+	case "on":
+		if err := b.storage.Set(ctx, key, "true", 0); err != nil {
+			return "", fmt.Errorf("set instant view failed: %s, key: %s", err, key)
+		}
+
+		return "Instant View is on. Random posts will be sent as https://telegra.ph pages", nil
+	case "off":
+		if err := b.storage.Del(ctx, key); err != nil {
+			return "", fmt.Errorf("delete instant view failed: %s, key: %s", err, key)
+		}
+
+		return "Instant View is off. Random posts will be sent as previews", nil
+	default:
+		return "Usage: /instantview on|off", nil
+	}
+}
+
+func (b *Bot) instantViewEnabled(ctx context.Context, userID int) bool {
+	enabled, err := b.storage.Get(ctx, "instantview:"+strconv.Itoa(userID))
+	if err != nil {
+		return false
+	}
+
+	return enabled == "true"
+}
+
+// instantViewPost publishes post to Telegraph and returns message with link to it.
+func (b *Bot) instantViewPost(ctx context.Context, source Source, post models.Post) (string, error) {
+	pageURL, err := b.publishPost(ctx, source, post)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("📝 %s\n\n%s", renderer.Link(pageURL, post.Title), renderer.Escape(post.URL)), nil
+}
+
+// publishPost creates Telegraph page for post. Pages are cached by post url, so post is published once.
+func (b *Bot) publishPost(ctx context.Context, source Source, post models.Post) (string, error) {
+	key := "telegraph:" + post.URL
+
+	pageURL, err := b.storage.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("get telegraph page failed: %s, key: %s", err, key)
+	}
+
+	if pageURL != "" {
+		return pageURL, nil
+	}
+
+	content, err := htmlToTelegraph(post.HTML, post.URL)
+	if err != nil {
+		return "", err
+	}
+
+	pageURL, err = b.telegraph.CreatePage(ctx, TelegraphPage{
+		Title:      post.Title,
+		AuthorName: source.Name(),
+		AuthorURL:  post.URL,
+		Content:    content,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if err := b.storage.Set(ctx, key, pageURL, 0); err != nil {
+		return "", fmt.Errorf("set telegraph page failed: %s, key: %s", err, key)
+	}
+
+	return pageURL, nil
+}
+
+// htmlToTelegraph converts html into Telegraph nodes resolving relative links against post url.
+// Content which doesn't fit into Telegraph limit is replaced with link to the post.
+func htmlToTelegraph(body, postURL string) ([]models.TelegraphNode, error) {
+	base, err := url.Parse(postURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse post url failed: %s", err)
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(body), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return nil, fmt.Errorf("parse post html failed: %s", err)
+	}
+
+	var content []models.TelegraphNode
+
+	for _, node := range nodes {
+		for _, node := range telegraphNodes(node, base) {
+			// Skip whitespace between blocks.
+			if text, ok := node.(string); ok && strings.TrimSpace(text) == "" {
+				continue
+			}
+
+			content = append(content, node)
+		}
+	}
+
+	return fitTelegraphContent(content, postURL), nil
+}
+
+func telegraphNodes(node *html.Node, base *url.URL) []models.TelegraphNode {
+	switch node.Type {
+	case html.TextNode:
+		return []models.TelegraphNode{node.Data}
+	case html.ElementNode:
+	default:
+		return nil
+	}
+
+	if telegraphSkipTags[node.Data] {
+		return nil
+	}
+
+	var children []models.TelegraphNode
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, telegraphNodes(child, base)...)
+	}
+
+	tag, ok := telegraphTags[node.Data]
+	if !ok {
+		return children
+	}
+
+	element := models.TelegraphElement{Tag: tag, Children: children}
+
+	for _, attr := range node.Attr {
+		if attr.Key != "href" && attr.Key != "src" {
+			continue
+		}
+
+		if ref, err := base.Parse(attr.Val); err == nil {
+			if element.Attrs == nil {
+				element.Attrs = make(map[string]string)
+			}
+
+			element.Attrs[attr.Key] = ref.String()
+		}
+	}
+
+	return []models.TelegraphNode{element}
+}
+
+// fitTelegraphContent drops trailing nodes which don't fit into Telegraph content limit.
+func fitTelegraphContent(content []models.TelegraphNode, postURL string) []models.TelegraphNode {
+	sizes := make([]int, len(content))
+
+	// Size of brackets around content.
+	size := 2
+
+	for i, node := range content {
+		sizes[i] = telegraphSize(node) + len(",")
+		size += sizes[i]
+	}
+
+	if size <= models.TelegraphMaxContent {
+		return content
+	}
+
+	more := models.TelegraphElement{
+		Tag: "p",
+		Children: []models.TelegraphNode{
+			models.TelegraphElement{Tag: "a", Attrs: map[string]string{"href": postURL}, Children: []models.TelegraphNode{"Read the full post"}},
+		},
+	}
+
+	size = 2 + telegraphSize(more)
+
+	for i := range content {
+		if size+sizes[i] > models.TelegraphMaxContent {
+			return append(content[:i:i], more)
+		}
+
+		size += sizes[i]
+	}
+
+	return content
+}
+
+func telegraphSize(node models.TelegraphNode) int {
+	data, _ := json.Marshal(node)
+
+	return len(data)
+}
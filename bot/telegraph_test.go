@@ -0,0 +1,244 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot/mocks"
+	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/models"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+// telegraphServer is a local stand-in for Telegraph API.
+type telegraphServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	pages []map[string]string
+}
+
+func newTelegraphServer(t *testing.T) *telegraphServer {
+	server := &telegraphServer{}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+
+		var result models.TelegraphResult
+
+		switch r.URL.Path {
+		case "/createAccount":
+			require.Equal(t, "LesswrongBot", r.PostForm.Get("short_name"))
+			result.AccessToken = "telegraph-token"
+		case "/createPage":
+			require.Equal(t, "telegraph-token", r.PostForm.Get("access_token"))
+
+			server.mu.Lock()
+			server.pages = append(server.pages, map[string]string{
+				"title":       r.PostForm.Get("title"),
+				"author_name": r.PostForm.Get("author_name"),
+				"author_url":  r.PostForm.Get("author_url"),
+				"content":     r.PostForm.Get("content"),
+			})
+			server.mu.Unlock()
+
+			result.URL = "https://telegra.ph/Page-05-06"
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(models.TelegraphResponse{OK: true, Result: result}))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (s *telegraphServer) Pages() []map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pages
+}
+
+func TestInstantView(t *testing.T) {
+	const (
+		userID  = 1
+		rssFeed = "https://www.overcomingbias.com/feed"
+	)
+
+	file, err := os.ReadFile("testdata/feed_rss.xml")
+	require.NoError(t, err)
+
+	httpClient := &mocks.HTTPClient{}
+	httpClient.On("Get", context.TODO(), rssFeed).Return(
+		&http.Response{Body: io.NopCloser(bytes.NewBuffer(file))},
+		nil,
+	).Once()
+
+	server := newTelegraphServer(t)
+
+	tgbot, err := New(Options{
+		BotAPI:     &tgbotapi.BotAPI{},
+		HTTPClient: httpClient,
+		Config:     config.Config{Feeds: []config.Feed{{URL: rssFeed, Name: "Overcoming Bias"}}},
+		Telegraph:  NewTelegraphClient(NewHTTPClient(), memory.NewStorage(), server.URL, ""),
+		RandomInt:  func(n int) int { return 0 },
+	})
+	require.NoError(t, err)
+
+	_, _, err = tgbot.ChangeSource(context.TODO(), userID, "feed-747e26f77f")
+	require.NoError(t, err)
+
+	text, err := tgbot.InstantView(context.TODO(), userID, "")
+	require.NoError(t, err)
+	require.Equal(t, "Instant View is off. Use /instantview on to read random posts on https://telegra.ph", text)
+
+	text, _, err = tgbot.RandomPost(context.TODO(), userID)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(text, `📝 <a href="https://www.overcomingbias.com/p/beware-consistency-checks">Beware Consistency Checks</a>`))
+	require.Empty(t, server.Pages())
+
+	text, err = tgbot.InstantView(context.TODO(), userID, "on")
+	require.NoError(t, err)
+	require.Equal(t, "Instant View is on. Random posts will be sent as https://telegra.ph pages", text)
+
+	want := "📝 <a href=\"https://telegra.ph/Page-05-06\">Beware Consistency Checks</a>\n\nhttps://www.overcomingbias.com/p/beware-consistency-checks"
+
+	// Page is created once and then served from cache.
+	for i := 0; i < 2; i++ {
+		// History is reset, so the same post is picked again.
+		_, err = tgbot.ResetHistory(context.TODO(), userID, "feed-747e26f77f")
+		require.NoError(t, err)
+
+		text, _, err = tgbot.RandomPost(context.TODO(), userID)
+		require.NoError(t, err)
+		require.Equal(t, want, text)
+	}
+
+	pages := server.Pages()
+	require.Len(t, pages, 1)
+	require.Equal(t, "Beware Consistency Checks", pages[0]["title"])
+	require.Equal(t, "Overcoming Bias", pages[0]["author_name"])
+	require.Equal(t, "https://www.overcomingbias.com/p/beware-consistency-checks", pages[0]["author_url"])
+	require.JSONEq(t, `[
+		{"tag": "p", "children": ["Consistency checks look cheap, but they are ", {"tag": "em", "children": ["not"]}, " free."]},
+		{"tag": "p", "children": ["See ", {"tag": "a", "attrs": {"href": "https://www.overcomingbias.com/p/what-is-signaling"}, "children": ["what signaling is"]}, " for details."]}
+	]`, pages[0]["content"])
+
+	text, err = tgbot.InstantView(context.TODO(), userID, "off")
+	require.NoError(t, err)
+	require.Equal(t, "Instant View is off. Random posts will be sent as previews", text)
+	require.False(t, tgbot.instantViewEnabled(context.TODO(), userID))
+
+	httpClient.AssertExpectations(t)
+}
+
+func TestTelegraphClient(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		accounts int
+		pages    int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var result models.TelegraphResult
+
+		switch r.URL.Path {
+		case "/createAccount":
+			accounts++
+			result.AccessToken = fmt.Sprintf("telegraph-token-%d", accounts)
+		case "/createPage":
+			pages++
+
+			// The first page is created, but response is lost.
+			if pages == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			require.Equal(t, "telegraph-token-1", r.FormValue("access_token"))
+			result.URL = "https://telegra.ph/Page-05-06"
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(models.TelegraphResponse{OK: true, Result: result}))
+	}))
+	defer server.Close()
+
+	storage := memory.NewStorage()
+	httpClient := NewHTTPClientWithOptions(HTTPClientOptions{MaxRetries: -1})
+
+	// Failed page isn't created again by retry.
+	_, err := NewTelegraphClient(httpClient, storage, server.URL, "").CreatePage(context.TODO(), TelegraphPage{Title: "Moloch"})
+	require.Error(t, err)
+	require.Equal(t, 1, pages)
+
+	// Account is kept after restart.
+	pageURL, err := NewTelegraphClient(httpClient, storage, server.URL, "").CreatePage(context.TODO(), TelegraphPage{Title: "Moloch"})
+	require.NoError(t, err)
+	require.Equal(t, "https://telegra.ph/Page-05-06", pageURL)
+	require.Equal(t, 1, accounts)
+
+	// Bot creates Telegraph client which doesn't retry requests.
+	tgbot, err := New(Options{BotAPI: &tgbotapi.BotAPI{}})
+	require.NoError(t, err)
+	require.Equal(t, 0, tgbot.telegraph.(*TelegraphClient).httpClient.(*DefaultHTTPClient).options.MaxRetries)
+}
+
+func TestHTMLToTelegraph(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "Should unwrap unsupported tags and map headers",
+			html: `<div class="post"><h1>Title</h1> <span>Text <b>bold</b></span></div>`,
+			want: `[{"tag": "h3", "children": ["Title"]}, "Text ", {"tag": "b", "children": ["bold"]}]`,
+		},
+		{
+			name: "Should drop scripts and keep only href and src attributes",
+			html: `<script>alert(1)</script><p><img src="/image.png" alt="image" width="10"></p>`,
+			want: `[{"tag": "p", "children": [{"tag": "img", "attrs": {"src": "https://example.com/image.png"}}]}]`,
+		},
+		{
+			name: "Should replace content over limit with link to the post",
+			html: strings.Repeat("<p>"+strings.Repeat("a", 1000)+"</p>", 100),
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := htmlToTelegraph(tt.html, "https://example.com/post")
+			require.NoError(t, err)
+
+			content, err := json.Marshal(got)
+			require.NoError(t, err)
+
+			if tt.want == "" {
+				require.LessOrEqual(t, len(content), models.TelegraphMaxContent)
+				require.Contains(t, string(content), `"Read the full post"`)
+				return
+			}
+
+			require.JSONEq(t, tt.want, string(content))
+		})
+	}
+}
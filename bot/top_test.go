@@ -82,7 +82,7 @@ func TestTopPosts(t *testing.T) {
 				randomPost: 2,
 			},
 			want: func(t *testing.T, got string) {
-				file, err := os.ReadFile("testdata/lesswrong_ru_top_posts.md")
+				file, err := os.ReadFile("testdata/lesswrong_ru_top_posts.html")
 				require.NoError(t, err)
 				require.Equal(t, string(file), got)
 			},
@@ -104,7 +104,7 @@ func TestTopPosts(t *testing.T) {
 				source: models.SourceAstral,
 			},
 			want: func(t *testing.T, got string) {
-				file, err := os.ReadFile("testdata/astral_top_posts.md")
+				file, err := os.ReadFile("testdata/astral_top_posts.html")
 				require.NoError(t, err)
 				require.Equal(t, string(file), got)
 			},
@@ -117,7 +117,7 @@ func TestTopPosts(t *testing.T) {
 				source:     models.SourceLesswrongRu,
 			},
 			want: func(t *testing.T, got string) {
-				file, err := os.ReadFile("testdata/lesswrong_ru_top_posts.md")
+				file, err := os.ReadFile("testdata/lesswrong_ru_top_posts.html")
 				require.NoError(t, err)
 				require.Equal(t, string(file), got)
 			},
@@ -129,7 +129,7 @@ func TestTopPosts(t *testing.T) {
 				source: models.SourceLesswrong,
 			},
 			want: func(t *testing.T, got string) {
-				file, err := os.ReadFile("testdata/lesswrong_top_posts.md")
+				file, err := os.ReadFile("testdata/lesswrong_top_posts.html")
 				require.NoError(t, err)
 				require.Equal(t, string(file), got)
 			},
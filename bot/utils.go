@@ -1,10 +1,15 @@
 package bot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/gocolly/colly"
+
+	"github.com/ndrewnee/lesswrong-bot/models"
 )
 
 // min returns the minimum of two integers
@@ -15,20 +20,30 @@ func min(a, b int) int {
 	return b
 }
 
-// handleResponse handles the common logic for API responses and unmarshals JSON
-func (b *Bot) handleResponse(httpResponse *http.Response, target interface{}) error {
+// readResponse checks response status and reads its body
+func (b *Bot) readResponse(httpResponse *http.Response) ([]byte, error) {
 	defer httpResponse.Body.Close()
 
 	// Check if response is successful
 	if httpResponse.StatusCode != 0 && httpResponse.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(httpResponse.Body)
-		return fmt.Errorf("API returned status %d: %s", httpResponse.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("API returned status %d: %s", httpResponse.StatusCode, string(bodyBytes))
 	}
 
-	// Read the response body to check if it's valid JSON
 	bodyBytes, err := io.ReadAll(httpResponse.Body)
 	if err != nil {
-		return fmt.Errorf("read response body failed: %s", err)
+		return nil, fmt.Errorf("read response body failed: %s", err)
+	}
+
+	return bodyBytes, nil
+}
+
+// handleResponse handles the common logic for API responses and unmarshals JSON
+func (b *Bot) handleResponse(httpResponse *http.Response, target interface{}) error {
+	// Read the response body to check if it's valid JSON
+	bodyBytes, err := b.readResponse(httpResponse)
+	if err != nil {
+		return err
 	}
 
 	// Check if response starts with HTML (error page)
@@ -43,3 +58,94 @@ func (b *Bot) handleResponse(httpResponse *http.Response, target interface{}) er
 
 	return nil
 }
+
+// cachedPosts returns posts cached under "posts:<name>" key, loading and caching them on cache miss.
+func (b *Bot) cachedPosts(ctx context.Context, name string, load func() ([]models.Post, error)) ([]models.Post, error) {
+	key := "posts:" + name
+
+	postsCached, err := b.storage.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("get %s cached posts failed: %s", name, err)
+	}
+
+	var posts []models.Post
+
+	if postsCached != "" {
+		if err := json.Unmarshal([]byte(postsCached), &posts); err != nil {
+			return nil, fmt.Errorf("unmarshal %s cached posts failed: %s", name, err)
+		}
+	}
+
+	// Load posts for the first time.
+	if len(posts) == 0 {
+		posts, err = load()
+		if err != nil {
+			return nil, err
+		}
+
+		postsCache, err := json.Marshal(posts)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s posts failed: %s", name, err)
+		}
+
+		if err := b.storage.Set(ctx, key, string(postsCache), b.config.CacheExpire); err != nil {
+			return nil, fmt.Errorf("cache %s posts failed: %s", name, err)
+		}
+	}
+
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("%s posts not found", name)
+	}
+
+	return posts, nil
+}
+
+// findPost returns post with given url from list or post with url as title if it's not in list.
+func findPost(posts []models.Post, url string) models.Post {
+	for _, post := range posts {
+		if post.URL == url {
+			return post
+		}
+	}
+
+	return models.Post{Title: url, URL: url}
+}
+
+// cached unmarshals value cached under key into target, calling load to fill target and caching it on cache miss.
+func (b *Bot) cached(ctx context.Context, key string, target interface{}, load func() error) error {
+	valueCached, err := b.storage.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("get cached value failed: %s, key: %s", err, key)
+	}
+
+	if valueCached != "" {
+		if err := json.Unmarshal([]byte(valueCached), target); err != nil {
+			return fmt.Errorf("unmarshal cached value failed: %s, key: %s", err, key)
+		}
+
+		return nil
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+
+	valueCache, err := json.Marshal(target)
+	if err != nil {
+		return fmt.Errorf("marshal value failed: %s, key: %s", err, key)
+	}
+
+	if err := b.storage.Set(ctx, key, string(valueCache), b.config.CacheExpire); err != nil {
+		return fmt.Errorf("cache value failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// newCollector returns scraper which sends requests with observed transport.
+func (b *Bot) newCollector() *colly.Collector {
+	collector := colly.NewCollector()
+	collector.WithTransport(b.transport)
+
+	return collector
+}
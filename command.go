@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ndrewnee/lesswrong-bot/bot"
+	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/storage/backup"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+const storageUsage = `Usage:
+  lesswrong-bot storage export [file]    Dump all keys of STORAGE to JSON lines file or stdout
+  lesswrong-bot storage import [file]    Load keys from JSON lines file or stdin into STORAGE
+  lesswrong-bot storage migrate FROM TO  Copy all keys between storages, e.g. file://bot.db redis://localhost:6379/0`
+
+var errUsage = errors.New(storageUsage)
+
+// runStorageCommand runs storage subcommand with args.
+func runStorageCommand(ctx context.Context, config config.Config, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	switch command, args := args[0], args[1:]; command {
+	case "export":
+		if len(args) > 1 {
+			return errUsage
+		}
+
+		return exportStorage(ctx, config, optionalArg(args), stdout)
+	case "import":
+		if len(args) > 1 {
+			return errUsage
+		}
+
+		return importStorage(ctx, config, optionalArg(args), stdin)
+	case "migrate":
+		if len(args) != 2 {
+			return errUsage
+		}
+
+		return migrateStorage(ctx, config, args[0], args[1])
+	}
+
+	return errUsage
+}
+
+func exportStorage(ctx context.Context, config config.Config, path string, stdout io.Writer) error {
+	storage, err := openStorage(config, config.Storage)
+	if err != nil {
+		return err
+	}
+
+	defer closeStorage(storage)
+
+	scanner, ok := storage.(backup.Scanner)
+	if !ok {
+		return fmt.Errorf("storage can't be exported: %s", config.Storage)
+	}
+
+	w := stdout
+
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("create dump file failed: %s", err)
+		}
+
+		defer file.Close()
+
+		w = file
+	}
+
+	count, err := backup.Export(ctx, scanner, w)
+	if err != nil {
+		return fmt.Errorf("export storage failed: %s", err)
+	}
+
+	log.Printf("Exported %d keys from %s", count, config.Storage)
+
+	return nil
+}
+
+func importStorage(ctx context.Context, config config.Config, path string, stdin io.Reader) error {
+	storage, err := openStorage(config, config.Storage)
+	if err != nil {
+		return err
+	}
+
+	defer closeStorage(storage)
+
+	r := stdin
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("open dump file failed: %s", err)
+		}
+
+		defer file.Close()
+
+		r = file
+	}
+
+	count, err := backup.Import(ctx, storage, r, time.Now())
+	if err != nil {
+		return fmt.Errorf("import storage failed: %s", err)
+	}
+
+	log.Printf("Imported %d keys to %s", count, config.Storage)
+
+	return nil
+}
+
+func migrateStorage(ctx context.Context, config config.Config, fromSpec, toSpec string) error {
+	from, err := openStorage(config, fromSpec)
+	if err != nil {
+		return err
+	}
+
+	defer closeStorage(from)
+
+	scanner, ok := from.(backup.Scanner)
+	if !ok {
+		return fmt.Errorf("storage can't be exported: %s", fromSpec)
+	}
+
+	to, err := openStorage(config, toSpec)
+	if err != nil {
+		return err
+	}
+
+	defer closeStorage(to)
+
+	count, err := backup.Migrate(ctx, scanner, to, time.Now())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Migrated %d keys from %s to %s", count, fromSpec, toSpec)
+
+	return nil
+}
+
+// openStorage returns storage by spec. Memory storage is rejected as it's empty in new process.
+func openStorage(config config.Config, spec string) (bot.Storage, error) {
+	storage, err := newStorage(config, spec)
+	if err != nil {
+		return nil, fmt.Errorf("init storage failed: %s", err)
+	}
+
+	if _, ok := storage.(*memory.Storage); ok {
+		closeStorage(storage)
+		return nil, fmt.Errorf("memory storage can't be exported or imported: %s", spec)
+	}
+
+	return storage, nil
+}
+
+func closeStorage(storage bot.Storage) {
+	closer, ok := storage.(io.Closer)
+	if !ok {
+		return
+	}
+
+	if err := closer.Close(); err != nil {
+		log.Printf("[ERROR] Close storage failed: %s", err)
+	}
+}
+
+func optionalArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	return args[0]
+}
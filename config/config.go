@@ -3,19 +3,51 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
-type Config struct {
-	RedisURL    string
-	Address     string
-	Token       string
-	WebhookHost string
-	Webhook     bool
-	Debug       bool
-	Timeout     time.Duration
-	CacheExpire time.Duration
-}
+type (
+	Config struct {
+		RedisURL     string
+		Address      string
+		Token        string
+		WebhookHost  string
+		Webhook      bool
+		Debug        bool
+		Timeout      time.Duration
+		CacheExpire  time.Duration
+		PollInterval time.Duration
+		// Storage is redis, memory, bolt or file:// URL of BoltDB database.
+		Storage string
+		// MemoryMaxKeys limits number of keys in memory storage selected with STORAGE=memory. Zero means no limit.
+		MemoryMaxKeys int
+		// Workers is number of updates processed concurrently.
+		Workers int
+		// QueueSize is number of updates waiting for each worker before new updates are blocked.
+		QueueSize int
+		// CrawlInterval is interval between indexing batches of post bodies for full-text search.
+		CrawlInterval time.Duration
+		Substacks     []Substack
+		Feeds         []Feed
+		// AutopostFile is path to JSON file with channels which new posts are published to automatically.
+		AutopostFile string
+		// TelegraphToken is access token of Telegraph account. If it isn't set, account is created on first page and kept in storage.
+		TelegraphToken string
+	}
+
+	// Substack is a publication registered as additional source.
+	Substack struct {
+		Host string
+		Name string
+	}
+
+	// Feed is RSS or Atom feed registered as additional source.
+	Feed struct {
+		URL  string
+		Name string
+	}
+)
 
 func Parse() Config {
 	port, err := strconv.Atoi(os.Getenv("PORT"))
@@ -43,14 +75,110 @@ func Parse() Config {
 		expire = 24 * time.Hour
 	}
 
+	pollInterval, err := time.ParseDuration(os.Getenv("POLL_INTERVAL"))
+	if err != nil {
+		pollInterval = 30 * time.Minute
+	}
+
+	crawlInterval, err := time.ParseDuration(os.Getenv("CRAWL_INTERVAL"))
+	if err != nil {
+		crawlInterval = time.Minute
+	}
+
+	storage := os.Getenv("STORAGE")
+	if storage == "" {
+		storage = "redis"
+	}
+
+	memoryMaxKeys, err := strconv.Atoi(os.Getenv("MEMORY_MAX_KEYS"))
+	if err != nil || memoryMaxKeys < 0 {
+		memoryMaxKeys = 0
+	}
+
+	workers, err := strconv.Atoi(os.Getenv("WORKERS"))
+	if err != nil || workers < 1 {
+		workers = 8
+	}
+
+	queueSize, err := strconv.Atoi(os.Getenv("QUEUE_SIZE"))
+	if err != nil || queueSize < 0 {
+		queueSize = 100
+	}
+
 	return Config{
-		RedisURL:    redisURL,
-		Address:     ":" + strconv.Itoa(port),
-		WebhookHost: webhookHost,
-		Token:       os.Getenv("TOKEN"),
-		Webhook:     os.Getenv("WEBHOOK") == "true",
-		Debug:       os.Getenv("DEBUG") == "true",
-		Timeout:     timeout,
-		CacheExpire: expire,
+		RedisURL:       redisURL,
+		Storage:        storage,
+		MemoryMaxKeys:  memoryMaxKeys,
+		Address:        ":" + strconv.Itoa(port),
+		WebhookHost:    webhookHost,
+		Token:          os.Getenv("TOKEN"),
+		Webhook:        os.Getenv("WEBHOOK") == "true",
+		Debug:          os.Getenv("DEBUG") == "true",
+		Timeout:        timeout,
+		CacheExpire:    expire,
+		PollInterval:   pollInterval,
+		CrawlInterval:  crawlInterval,
+		Workers:        workers,
+		QueueSize:      queueSize,
+		Substacks:      parseSubstacks(os.Getenv("SUBSTACKS")),
+		Feeds:          parseFeeds(os.Getenv("FEEDS")),
+		AutopostFile:   os.Getenv("AUTOPOST_FILE"),
+		TelegraphToken: os.Getenv("TELEGRAPH_TOKEN"),
 	}
 }
+
+// parseSubstacks parses comma separated list of publications in format host=Name.
+func parseSubstacks(value string) []Substack {
+	var substacks []Substack
+
+	for _, item := range parseNamed(value) {
+		substacks = append(substacks, Substack{Host: item.value, Name: item.name})
+	}
+
+	return substacks
+}
+
+// parseFeeds parses comma separated list of feeds in format url=Name.
+func parseFeeds(value string) []Feed {
+	var feeds []Feed
+
+	for _, item := range parseNamed(value) {
+		feeds = append(feeds, Feed{URL: item.value, Name: item.name})
+	}
+
+	return feeds
+}
+
+type named struct {
+	value string
+	name  string
+}
+
+// parseNamed parses comma separated list in format value=Name. Name is optional and defaults to value.
+// Item is split by the last "=", so values containing "=" (e.g. URLs with query) must have a name.
+func parseNamed(value string) []named {
+	var items []named
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		value, name := item, ""
+		if i := strings.LastIndex(item, "="); i != -1 {
+			value, name = item[:i], item[i+1:]
+		}
+
+		value = strings.TrimSpace(value)
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			name = value
+		}
+
+		items = append(items, named{value: value, name: name})
+	}
+
+	return items
+}
@@ -0,0 +1,320 @@
+// Package index is a full-text inverted index of posts kept in key-value storage.
+package index
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lengthsKey is a key of hash with lengths of all documents.
+const lengthsKey = "index:lengths"
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type (
+	// Storage is a key-value storage of index. Values are stored without expiration.
+	Storage interface {
+		Get(ctx context.Context, key string) (string, error)
+		Set(ctx context.Context, key, value string, expire time.Duration) error
+		HSet(ctx context.Context, key, field, value string) error
+		HGetAll(ctx context.Context, key string) (map[string]string, error)
+	}
+
+	// Index keeps documents under "index:doc:<id>", hash of term positions by document id under "index:term:<term>"
+	// and hash of document lengths by id under "index:lengths". Documents are added by setting hash fields,
+	// so concurrent writers don't overwrite each other.
+	Index struct {
+		storage Storage
+	}
+
+	// Document is an indexed post. Title and text are indexed, text is used for snippets.
+	Document struct {
+		URL    string `json:"url"`
+		Title  string `json:"title"`
+		Source string `json:"source"`
+		Text   string `json:"text"`
+	}
+
+	// Result is a document found by query.
+	Result struct {
+		Document
+		Score float64
+		// Snippet is Telegram HTML with part of text around query terms highlighted in bold.
+		Snippet string
+	}
+)
+
+func New(storage Storage) *Index {
+	return &Index{storage: storage}
+}
+
+// Has checks if document with url is indexed.
+func (i *Index) Has(ctx context.Context, url string) (bool, error) {
+	doc, err := i.storage.Get(ctx, docKey(docID(url)))
+	if err != nil {
+		return false, fmt.Errorf("get document failed: %s, url: %s", err, url)
+	}
+
+	return doc != "", nil
+}
+
+// Add indexes document. Document is written last, so partially indexed document is indexed again.
+func (i *Index) Add(ctx context.Context, doc Document) error {
+	id := docID(doc.URL)
+
+	positions := make(map[string][]int)
+	length := 0
+
+	// Title and text are separated by one position, so phrase doesn't match across them.
+	for _, text := range []string{doc.Title, doc.Text} {
+		tokens := tokenize(text)
+
+		for n, token := range tokens {
+			positions[token.term] = append(positions[token.term], length+n)
+		}
+
+		length += len(tokens) + 1
+	}
+
+	for term, termPositions := range positions {
+		positionsCache, err := json.Marshal(termPositions)
+		if err != nil {
+			return fmt.Errorf("marshal positions failed: %s, term: %s", err, term)
+		}
+
+		if err := i.storage.HSet(ctx, termKey(term), id, string(positionsCache)); err != nil {
+			return fmt.Errorf("set postings failed: %s, key: %s", err, termKey(term))
+		}
+	}
+
+	if err := i.storage.HSet(ctx, lengthsKey, id, strconv.Itoa(length)); err != nil {
+		return fmt.Errorf("set document length failed: %s, key: %s", err, lengthsKey)
+	}
+
+	return i.setJSON(ctx, docKey(id), doc)
+}
+
+// Search returns documents matching query ranked by BM25. Query is a list of terms and quoted phrases,
+// e.g. `moloch "cost disease"`. Document matches if it contains any term and every phrase.
+func (i *Index) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	q := parseQuery(query)
+	if len(q.terms) == 0 {
+		return nil, nil
+	}
+
+	lengths, err := i.lengths(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lengths) == 0 {
+		return nil, nil
+	}
+
+	total := 0
+	for _, length := range lengths {
+		total += length
+	}
+
+	avgLength := float64(total) / float64(len(lengths))
+
+	postings := make(map[string]map[string][]int, len(q.terms))
+	scores := make(map[string]float64)
+
+	for _, term := range q.terms {
+		termPostings, err := i.postings(ctx, term)
+		if err != nil {
+			return nil, err
+		}
+
+		postings[term] = termPostings
+
+		idf := math.Log(1 + (float64(len(lengths))-float64(len(termPostings))+0.5)/(float64(len(termPostings))+0.5))
+
+		for id, positions := range termPostings {
+			tf := float64(len(positions))
+			norm := k1 * (1 - b + b*float64(lengths[id])/avgLength)
+			scores[id] += idf * tf * (k1 + 1) / (tf + norm)
+		}
+	}
+
+	ids := make([]string, 0, len(scores))
+
+	for id := range scores {
+		if hasPhrases(id, q.phrases, postings) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(a, c int) bool {
+		if scores[ids[a]] != scores[ids[c]] {
+			return scores[ids[a]] > scores[ids[c]]
+		}
+
+		return ids[a] < ids[c]
+	})
+
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	results := make([]Result, 0, len(ids))
+
+	for _, id := range ids {
+		var doc Document
+
+		found, err := i.getJSON(ctx, docKey(id), &doc)
+		if err != nil {
+			return nil, err
+		}
+
+		// Document is still being indexed.
+		if !found {
+			continue
+		}
+
+		results = append(results, Result{
+			Document: doc,
+			Score:    scores[id],
+			Snippet:  snippet(doc.Text, q),
+		})
+	}
+
+	return results, nil
+}
+
+// hasPhrases checks if document contains every phrase as consecutive terms.
+func hasPhrases(id string, phrases [][]string, postings map[string]map[string][]int) bool {
+	for _, phrase := range phrases {
+		found := false
+
+		for _, start := range postings[phrase[0]][id] {
+			found = true
+
+			for n, term := range phrase[1:] {
+				if !contains(postings[term][id], start+n+1) {
+					found = false
+					break
+				}
+			}
+
+			if found {
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(positions []int, position int) bool {
+	for _, p := range positions {
+		if p == position {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (i *Index) postings(ctx context.Context, term string) (map[string][]int, error) {
+	key := termKey(term)
+
+	postingsCached, err := i.storage.HGetAll(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("get postings failed: %s, key: %s", err, key)
+	}
+
+	postings := make(map[string][]int, len(postingsCached))
+
+	for id, positionsCached := range postingsCached {
+		var positions []int
+
+		if err := json.Unmarshal([]byte(positionsCached), &positions); err != nil {
+			return nil, fmt.Errorf("unmarshal positions failed: %s, key: %s", err, key)
+		}
+
+		postings[id] = positions
+	}
+
+	return postings, nil
+}
+
+func (i *Index) lengths(ctx context.Context) (map[string]int, error) {
+	lengthsCached, err := i.storage.HGetAll(ctx, lengthsKey)
+	if err != nil {
+		return nil, fmt.Errorf("get document lengths failed: %s, key: %s", err, lengthsKey)
+	}
+
+	lengths := make(map[string]int, len(lengthsCached))
+
+	for id, lengthCached := range lengthsCached {
+		length, err := strconv.Atoi(lengthCached)
+		if err != nil {
+			return nil, fmt.Errorf("parse document length failed: %s, key: %s", err, lengthsKey)
+		}
+
+		lengths[id] = length
+	}
+
+	return lengths, nil
+}
+
+func (i *Index) getJSON(ctx context.Context, key string, value interface{}) (bool, error) {
+	cached, err := i.storage.Get(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("get index failed: %s, key: %s", err, key)
+	}
+
+	if cached == "" {
+		return false, nil
+	}
+
+	if err := json.Unmarshal([]byte(cached), value); err != nil {
+		return false, fmt.Errorf("unmarshal index failed: %s, key: %s", err, key)
+	}
+
+	return true, nil
+}
+
+func (i *Index) setJSON(ctx context.Context, key string, value interface{}) error {
+	cache, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal index failed: %s, key: %s", err, key)
+	}
+
+	if err := i.storage.Set(ctx, key, string(cache), 0); err != nil {
+		return fmt.Errorf("set index failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// docID returns short id of document url to keep postings small.
+func docID(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(hash[:8])
+}
+
+func docKey(id string) string {
+	return "index:doc:" + id
+}
+
+func termKey(term string) string {
+	return "index:term:" + term
+}
@@ -0,0 +1,164 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+func TestIndex(t *testing.T) {
+	index := New(memory.NewStorage())
+
+	docs := []Document{
+		{
+			URL:    "https://slatestarcodex.com/2014/07/30/meditations-on-moloch/",
+			Title:  "Meditations on Moloch",
+			Source: "2",
+			Text:   "Allen Ginsberg's famous poem on Moloch: what sphinx of cement and aluminum bashed open their skulls and ate up their brains and imagination?",
+		},
+		{
+			URL:    "https://slatestarcodex.com/2017/02/09/considerations-on-cost-disease/",
+			Title:  "Considerations On Cost Disease",
+			Source: "2",
+			Text:   "Why does education cost so much more than it used to? Cost disease is the rising cost of services. Moloch is mentioned once.",
+		},
+		{
+			URL:    "https://astralcodexten.substack.com/p/heuristics-that-almost-always-work",
+			Title:  "Heuristics That Almost Always Work",
+			Source: "3",
+			Text:   "The disease of cost-benefit analysis is that the security guard hears a noise and thinks it's the wind.",
+		},
+	}
+
+	for _, doc := range docs {
+		has, err := index.Has(context.TODO(), doc.URL)
+		require.NoError(t, err)
+		require.False(t, has)
+
+		require.NoError(t, index.Add(context.TODO(), doc))
+
+		has, err = index.Has(context.TODO(), doc.URL)
+		require.NoError(t, err)
+		require.True(t, has)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		limit    int
+		urls     []string
+		snippets []string
+	}{
+		{
+			name:  "Should rank documents with more term occurrences higher",
+			query: "moloch",
+			limit: 10,
+			urls:  []string{docs[0].URL, docs[1].URL},
+			snippets: []string{
+				"Allen Ginsberg's famous poem on <b>Moloch</b>: what sphinx of cement and aluminum bashed open their skulls and ate up their brains and imagination?",
+				"…disease is the rising cost of services. <b>Moloch</b> is mentioned once.",
+			},
+		},
+		{
+			name:  "Should find documents with phrase only",
+			query: `"cost disease"`,
+			limit: 10,
+			urls:  []string{docs[1].URL},
+		},
+		{
+			name:  "Should find documents with any term and every phrase",
+			query: `poem "cost disease"`,
+			limit: 10,
+			urls:  []string{docs[1].URL},
+		},
+		{
+			name:  "Should not match phrase across title and text",
+			query: `"disease why"`,
+			limit: 10,
+		},
+		{
+			name:  "Should limit results",
+			query: "disease",
+			limit: 1,
+			urls:  []string{docs[1].URL},
+		},
+		{
+			name:  "Should ignore case and punctuation",
+			query: "HEURISTICS!",
+			limit: 10,
+			urls:  []string{docs[2].URL},
+		},
+		{
+			name:  "Should return nothing for empty query",
+			query: `" "`,
+			limit: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := index.Search(context.TODO(), tt.query, tt.limit)
+			require.NoError(t, err)
+
+			var urls []string
+			for _, result := range results {
+				urls = append(urls, result.URL)
+			}
+
+			require.Equal(t, tt.urls, urls)
+
+			for i, want := range tt.snippets {
+				require.Equal(t, want, results[i].Snippet)
+			}
+		})
+	}
+}
+
+func TestIndexConcurrentAdd(t *testing.T) {
+	index := New(memory.NewStorage())
+
+	var wg sync.WaitGroup
+
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+
+		go func(n int) {
+			defer wg.Done()
+
+			require.NoError(t, index.Add(context.TODO(), Document{
+				URL:   fmt.Sprintf("https://slatestarcodex.com/%d/", n),
+				Title: "Moloch",
+				Text:  "Moloch whose mind is pure machinery",
+			}))
+		}(n)
+	}
+
+	wg.Wait()
+
+	results, err := index.Search(context.TODO(), "moloch", 20)
+	require.NoError(t, err)
+	require.Len(t, results, 10)
+}
+
+func TestParseQuery(t *testing.T) {
+	q := parseQuery(`Moloch "cost disease" moloch "unclosed phrase`)
+	require.Equal(t, []string{"moloch", "cost", "disease", "unclosed", "phrase"}, q.terms)
+	require.Equal(t, [][]string{{"cost", "disease"}, {"unclosed", "phrase"}}, q.phrases)
+}
+
+func TestSnippet(t *testing.T) {
+	text := "One two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty. " +
+		"Twenty one <twenty two> twenty three twenty four twenty five twenty six twenty seven twenty eight twenty nine thirty thirty one."
+
+	require.Equal(t,
+		"…eleven twelve thirteen fourteen fifteen sixteen seventeen <b>eighteen</b> nineteen twenty. Twenty one &lt;twenty two&gt; twenty three twenty four twenty five twenty six twenty seven twenty eight twenty nine thirty thirty…",
+		snippet(text, parseQuery("eighteen")),
+	)
+	require.Equal(t, "One two", snippet("One two", parseQuery("missing")))
+	require.Empty(t, snippet("", parseQuery("missing")))
+}
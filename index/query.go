@@ -0,0 +1,152 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ndrewnee/lesswrong-bot/renderer"
+)
+
+// snippetLength is number of words in snippet.
+const snippetLength = 30
+
+type (
+	// token is a lowercase word of text with its byte offsets.
+	token struct {
+		term       string
+		start, end int
+	}
+
+	query struct {
+		// terms are unique terms of query including terms of phrases.
+		terms   []string
+		phrases [][]string
+	}
+)
+
+// tokenize splits text into words of letters and digits.
+func tokenize(text string) []token {
+	var (
+		tokens []token
+		start  = -1
+	)
+
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+
+		if word && start == -1 {
+			start = i
+		}
+
+		if !word && start != -1 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+
+	if start != -1 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+
+	return tokens
+}
+
+// parseQuery parses terms and quoted phrases. Unclosed quote lasts till the end of query.
+func parseQuery(text string) query {
+	var (
+		q    query
+		seen = make(map[string]bool)
+	)
+
+	for n, part := range strings.Split(text, `"`) {
+		var terms []string
+
+		for _, token := range tokenize(part) {
+			terms = append(terms, token.term)
+
+			if !seen[token.term] {
+				seen[token.term] = true
+				q.terms = append(q.terms, token.term)
+			}
+		}
+
+		// Odd parts are inside quotes.
+		if n%2 == 1 && len(terms) > 1 {
+			q.phrases = append(q.phrases, terms)
+		}
+	}
+
+	return q
+}
+
+// snippet returns Telegram HTML with words around the first query term in text. Query terms are bold.
+func snippet(text string, q query) string {
+	tokens := tokenize(text)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	terms := make(map[string]bool, len(q.terms))
+	for _, term := range q.terms {
+		terms[term] = true
+	}
+
+	first := 0
+
+	for n, token := range tokens {
+		if terms[token.term] {
+			first = n
+			break
+		}
+	}
+
+	start := max(0, first-snippetLength/4)
+	end := min(len(tokens), start+snippetLength)
+
+	var s strings.Builder
+
+	if start > 0 {
+		s.WriteString("…")
+	} else {
+		s.WriteString(renderer.Escape(strings.TrimSpace(text[:tokens[0].start])))
+	}
+
+	for n := start; n < end; n++ {
+		if n > start {
+			s.WriteString(collapse(text[tokens[n-1].end:tokens[n].start]))
+		}
+
+		word := renderer.Escape(text[tokens[n].start:tokens[n].end])
+
+		if terms[tokens[n].term] {
+			word = "<b>" + word + "</b>"
+		}
+
+		s.WriteString(word)
+	}
+
+	if end < len(tokens) {
+		s.WriteString("…")
+	} else {
+		s.WriteString(renderer.Escape(strings.TrimSpace(text[tokens[end-1].end:])))
+	}
+
+	return s.String()
+}
+
+// collapse replaces whitespaces between words with a single space.
+func collapse(text string) string {
+	fields := strings.Fields(text)
+	collapsed := strings.Join(fields, " ")
+
+	if r, _ := utf8.DecodeRuneInString(text); unicode.IsSpace(r) && len(fields) > 0 {
+		collapsed = " " + collapsed
+	}
+
+	if r, _ := utf8.DecodeLastRuneInString(text); unicode.IsSpace(r) {
+		collapsed += " "
+	}
+
+	return renderer.Escape(collapsed)
+}
@@ -2,26 +2,39 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 
 	"github.com/ndrewnee/lesswrong-bot/bot"
 	"github.com/ndrewnee/lesswrong-bot/config"
+	"github.com/ndrewnee/lesswrong-bot/storage/bolt"
 	"github.com/ndrewnee/lesswrong-bot/storage/memory"
 	"github.com/ndrewnee/lesswrong-bot/storage/redis"
 )
 
+// defaultBoltPath is database file used when storage is set to bolt without path.
+const defaultBoltPath = "lesswrong-bot.db"
+
 func main() {
 	config := config.Parse()
 
-	var (
-		storage bot.Storage
-		err     error
-	)
+	if len(os.Args) > 1 && os.Args[1] == "storage" {
+		if err := runStorageCommand(context.Background(), config, os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
 
-	storage, err = redis.NewStorage(config.RedisURL)
+	storage, err := newStorage(config, config.Storage)
 	if err != nil {
-		log.Printf("Connect to redis failed, using memory storage instead: %s", err)
-		storage = memory.NewStorage()
+		log.Fatal("Init storage failed: ", err)
 	}
 
 	tgbot, err := bot.New(bot.Options{Config: config, Storage: storage})
@@ -29,18 +42,66 @@ func main() {
 		log.Fatal("Init telegram bot failed: ", err)
 	}
 
+	go tgbot.RunPoller(context.Background(), config.PollInterval)
+	go tgbot.RunScheduler(context.Background(), time.Minute)
+	go tgbot.RunCrawler(context.Background(), config.CrawlInterval)
+
 	updates, err := tgbot.GetUpdatesChan()
 	if err != nil {
 		log.Fatal("Get updates chan failed: ", err)
 	}
 
-	for update := range updates {
-		ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	dispatcher := bot.NewDispatcher(config.Workers, config.QueueSize, func(ctx context.Context, update tgbotapi.Update) {
+		ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
 
 		if _, err := tgbot.MessageHandler(ctx, update); err != nil {
 			log.Printf("[ERROR] Message not sent: %s", err)
 		}
+	})
+
+	tgbot.ObserveDispatcher(dispatcher)
+	dispatcher.Start(context.Background())
 
-		cancel()
+	for update := range updates {
+		if err := dispatcher.Dispatch(context.Background(), update); err != nil {
+			log.Printf("[ERROR] Dispatch update failed: %s", err)
+		}
 	}
+
+	dispatcher.Stop()
+}
+
+// newStorage returns storage selected by spec: redis, memory, bolt, redis:// URL or BoltDB file given as file:// URL.
+func newStorage(config config.Config, spec string) (bot.Storage, error) {
+	switch {
+	case strings.HasPrefix(spec, "file://"):
+		return bolt.NewStorage(strings.TrimPrefix(spec, "file://"))
+	case strings.HasPrefix(spec, "redis://"), strings.HasPrefix(spec, "rediss://"):
+		return redis.NewStorage(spec)
+	}
+
+	switch spec {
+	case "bolt":
+		return bolt.NewStorage(defaultBoltPath)
+	case "memory":
+		return newMemoryStorage(config), nil
+	case "redis":
+		storage, err := redis.NewStorage(config.RedisURL)
+		if err != nil {
+			log.Printf("[ERROR] Connect to redis failed, using bolt storage %s instead: %s", defaultBoltPath, err)
+			return bolt.NewStorage(defaultBoltPath)
+		}
+
+		return storage, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage: %s", spec)
+}
+
+func newMemoryStorage(config config.Config) *memory.Storage {
+	return memory.NewStorageWithOptions(memory.Options{
+		MaxKeys:         config.MemoryMaxKeys,
+		CleanupInterval: memory.DefaultCleanupInterval,
+	})
 }
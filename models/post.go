@@ -4,14 +4,39 @@ const (
 	DefaultLimit           = 12
 	PostMaxLength          = 500
 	LesswrongPostsMaxCount = 2000
+	// MessageMaxLength is Telegram limit for message text length.
+	MessageMaxLength = 4096
+	// PageMaxLength is length of post page leaving room for page title and footer.
+	PageMaxLength = 3500
+	// SearchMaxResults is maximum number of search results kept for pagination.
+	SearchMaxResults = 50
+	// SearchPageSize is number of search results on one page.
+	SearchPageSize = 5
+	// InlinePageSize is number of inline query results in one answer.
+	InlinePageSize = 20
+	// InlineCacheTime is time in seconds Telegram caches inline query results.
+	InlineCacheTime = 300
+	// BookmarksPageSize is number of bookmarks on one page.
+	BookmarksPageSize = 5
+	// HistoryMaxLength is number of last served posts kept in user history.
+	HistoryMaxLength = 20
+	// ServedMaxCount is number of last served posts remembered for sources without catalog.
+	ServedMaxCount = 500
+	// PublishedMaxCount is number of last posts published to autopost channel remembered to skip duplicates.
+	PublishedMaxCount = 500
+	// SequencesMaxCount is maximum number of sequences to choose from.
+	SequencesMaxCount = 30
+	// CrawlBatchSize is number of post bodies fetched for full-text index in one crawl.
+	CrawlBatchSize = 10
 )
 
 type (
 	Post struct {
-		Title string
-		URL   string
-		HTML  string
-		Slug  string
+		Title  string
+		URL    string
+		HTML   string
+		Slug   string
+		Author string
 	}
 
 	AstralPost struct {
@@ -30,7 +55,14 @@ type (
 	}
 
 	LesswrongData struct {
-		Posts LesswrongPost `json:"posts"`
+		Posts     LesswrongPost           `json:"posts"`
+		Post      LesswrongSingle         `json:"post"`
+		Sequences LesswrongSequences      `json:"sequences"`
+		Sequence  LesswrongSequenceSingle `json:"sequence"`
+	}
+
+	LesswrongSingle struct {
+		Result LesswrongResult `json:"result"`
 	}
 
 	LesswrongPost struct {
@@ -60,8 +92,9 @@ func (ap AstralPost) AsPost() Post {
 
 func (lr LesswrongResult) AsPost() Post {
 	return Post{
-		Title: lr.Title,
-		URL:   lr.PageURL,
-		HTML:  lr.HTMLBody,
+		Title:  lr.Title,
+		URL:    lr.PageURL,
+		HTML:   lr.HTMLBody,
+		Author: lr.User.DisplayName,
 	}
 }
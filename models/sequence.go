@@ -0,0 +1,43 @@
+package models
+
+type (
+	// Sequence is an ordered series of posts, e.g. a book or a section of lesswrong.ru.
+	Sequence struct {
+		ID    string
+		Title string
+		Posts []Post
+	}
+
+	LesswrongSequences struct {
+		Results []LesswrongSequence `json:"results"`
+	}
+
+	LesswrongSequenceSingle struct {
+		Result LesswrongSequence `json:"result"`
+	}
+
+	LesswrongSequence struct {
+		ID       string             `json:"_id"`
+		Title    string             `json:"title"`
+		Chapters []LesswrongChapter `json:"chapters"`
+	}
+
+	LesswrongChapter struct {
+		Posts []LesswrongResult `json:"posts"`
+	}
+)
+
+func (ls LesswrongSequence) AsSequence() Sequence {
+	sequence := Sequence{
+		ID:    ls.ID,
+		Title: ls.Title,
+	}
+
+	for _, chapter := range ls.Chapters {
+		for _, post := range chapter.Posts {
+			sequence.Posts = append(sequence.Posts, post.AsPost())
+		}
+	}
+
+	return sequence
+}
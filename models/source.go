@@ -5,6 +5,9 @@ const (
 	DomainSlate       = "slatestarcodex.com"
 	DomainAstral      = "astralcodexten.substack.com"
 	DomainLesswrong   = "lesswrong.com"
+
+	DomainEAForum        = "forum.effectivealtruism.org"
+	DomainAlignmentForum = "alignmentforum.org"
 )
 
 const (
@@ -12,29 +15,14 @@ const (
 	SourceSlate       Source = "2"
 	SourceAstral      Source = "3"
 	SourceLesswrong   Source = "4"
+
+	SourceEAForum        Source = "5"
+	SourceAlignmentForum Source = "6"
 )
 
+// Source is an identifier of registered source stored in user settings.
 type Source string
 
-func (s Source) String() string {
-	switch s {
-	case SourceLesswrongRu:
-		return "https://" + DomainLesswrongRu
-	case SourceSlate:
-		return "https://" + DomainSlate
-	case SourceAstral:
-		return "https://" + DomainAstral
-	case SourceLesswrong:
-		return "https://" + DomainLesswrong
-	default:
-		return ""
-	}
-}
-
 func (s Source) Value() string {
 	return string(s)
 }
-
-func (s Source) IsValid() bool {
-	return s.String() != ""
-}
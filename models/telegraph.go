@@ -0,0 +1,30 @@
+package models
+
+const (
+	TelegraphAPI = "https://api.telegra.ph"
+	// TelegraphMaxContent is Telegraph limit for page content size in bytes.
+	TelegraphMaxContent = 64 * 1024
+)
+
+type (
+	// TelegraphNode is either string or TelegraphElement.
+	TelegraphNode interface{}
+
+	TelegraphElement struct {
+		Tag      string            `json:"tag"`
+		Attrs    map[string]string `json:"attrs,omitempty"`
+		Children []TelegraphNode   `json:"children,omitempty"`
+	}
+
+	TelegraphResponse struct {
+		OK     bool            `json:"ok"`
+		Error  string          `json:"error"`
+		Result TelegraphResult `json:"result"`
+	}
+
+	TelegraphResult struct {
+		AccessToken string `json:"access_token"`
+		Path        string `json:"path"`
+		URL         string `json:"url"`
+	}
+)
@@ -0,0 +1,402 @@
+// Package renderer converts post HTML into HTML supported by Telegram.
+// Length of rendered text is counted in UTF-16 code units of visible text as Telegram does, tags aren't counted.
+package renderer
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	escaper     = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+// inlineTags maps html tags to Telegram tags.
+var inlineTags = map[string]string{
+	"b": "b", "strong": "b",
+	"i": "i", "em": "i", "cite": "i",
+	"u": "u", "ins": "u",
+	"s": "s", "strike": "s", "del": "s",
+	"code": "code",
+}
+
+// blockTags are tags separated from surrounding text by empty line.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true, "aside": true,
+	"figure": true, "table": true, "tr": true, "dl": true, "dt": true, "dd": true, "details": true,
+}
+
+// skipTags are tags dropped with their content.
+var skipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "svg": true, "form": true, "button": true,
+	"input": true, "iframe": true, "head": true, "title": true,
+}
+
+// Escape escapes text for Telegram HTML.
+func Escape(text string) string {
+	return escaper.Replace(text)
+}
+
+// Link returns Telegram HTML link.
+func Link(href, text string) string {
+	return fmt.Sprintf(`<a href="%s">%s</a>`, attrEscaper.Replace(href), Escape(text))
+}
+
+// telegramTags are tags supported by Telegram HTML parse mode.
+var telegramTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true, "s": true, "strike": true, "del": true,
+	"a": true, "code": true, "pre": true, "span": true, "tg-spoiler": true, "tg-emoji": true, "blockquote": true,
+}
+
+// Validate returns error if text can't be sent as Telegram HTML: it has unsupported tags or tags aren't closed in the right order.
+func Validate(text string) error {
+	var open []string
+
+	tokenizer := html.NewTokenizer(strings.NewReader(text))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if len(open) > 0 {
+				return fmt.Errorf("tag isn't closed: %s", open[len(open)-1])
+			}
+
+			return nil
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if !telegramTags[string(name)] {
+				return fmt.Errorf("tag isn't supported: %s", name)
+			}
+
+			open = append(open, string(name))
+		case html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			return fmt.Errorf("tag isn't supported: %s", name)
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if len(open) == 0 || open[len(open)-1] != string(name) {
+				return fmt.Errorf("tag isn't opened: %s", name)
+			}
+
+			open = open[:len(open)-1]
+		}
+	}
+}
+
+// Render converts html into Telegram HTML. Relative links are resolved against base url.
+// Headers are rendered bold, lists with bullets and images as links.
+func Render(body, base string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("parse base url failed: %s", err)
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(body), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return "", fmt.Errorf("parse html failed: %s", err)
+	}
+
+	w := &writer{base: baseURL}
+
+	for _, node := range nodes {
+		w.node(node)
+	}
+
+	return w.buf.String(), nil
+}
+
+type list struct {
+	ordered bool
+	n       int
+}
+
+type writer struct {
+	base *url.URL
+	buf  strings.Builder
+	// newlines is count of newlines at the end of text, tags aren't counted.
+	newlines int
+	// pending is count of newlines which should be written before next text.
+	pending int
+	written bool
+	space   bool
+	pre     int
+	links   int
+	lists   []list
+}
+
+func (w *writer) node(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		w.text(node.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	tag := node.Data
+
+	if skipTags[tag] {
+		return
+	}
+
+	switch {
+	case tag == "br":
+		w.space = false
+		w.write("\n")
+	case tag == "hr":
+		w.block()
+		w.write("———")
+		w.block()
+	case tag == "img":
+		src := w.attr(node, "src")
+		if src == "" {
+			return
+		}
+
+		alt := strings.TrimSpace(w.attr(node, "alt"))
+		if alt == "" {
+			alt = "Image"
+		}
+
+		// Telegram doesn't support nested links.
+		if w.links > 0 {
+			w.text(alt)
+			return
+		}
+
+		w.tag(fmt.Sprintf(`<a href="%s">`, attrEscaper.Replace(src)))
+		w.text(alt)
+		w.tag("</a>")
+	case tag == "a":
+		href := w.attr(node, "href")
+		if href == "" || w.pre > 0 || w.links > 0 {
+			w.children(node)
+			return
+		}
+
+		w.tag(fmt.Sprintf(`<a href="%s">`, attrEscaper.Replace(href)))
+		w.links++
+		w.children(node)
+		w.links--
+		w.tag("</a>")
+	case tag == "pre":
+		w.block()
+		w.tag("<pre>")
+		w.pre++
+		w.children(node)
+		w.pre--
+		w.tag("</pre>")
+		w.block()
+	case tag == "blockquote":
+		w.block()
+		w.tag("<blockquote>")
+		w.children(node)
+		w.trim()
+		w.tag("</blockquote>")
+		w.block()
+	case tag == "ul" || tag == "ol":
+		if len(w.lists) == 0 {
+			w.block()
+		} else {
+			w.line()
+		}
+
+		w.lists = append(w.lists, list{ordered: tag == "ol"})
+		w.children(node)
+		w.lists = w.lists[:len(w.lists)-1]
+
+		if len(w.lists) == 0 {
+			w.block()
+		} else {
+			w.line()
+		}
+	case tag == "li":
+		w.line()
+
+		if len(w.lists) > 0 {
+			l := &w.lists[len(w.lists)-1]
+			l.n++
+
+			w.write(strings.Repeat("  ", len(w.lists)-1))
+
+			if l.ordered {
+				w.write(strconv.Itoa(l.n) + ". ")
+			} else {
+				w.write("• ")
+			}
+		}
+
+		w.children(node)
+		w.line()
+	case len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6':
+		w.block()
+		w.tag("<b>")
+		w.children(node)
+		w.trim()
+		w.tag("</b>")
+		w.block()
+	case tag == "figcaption":
+		w.line()
+		w.tag("<i>")
+		w.children(node)
+		w.trim()
+		w.tag("</i>")
+		w.line()
+	case blockTags[tag]:
+		w.block()
+		w.children(node)
+		w.block()
+	case inlineTags[tag] != "" && w.pre == 0:
+		w.tag("<" + inlineTags[tag] + ">")
+		w.children(node)
+		w.tag("</" + inlineTags[tag] + ">")
+	default:
+		w.children(node)
+	}
+}
+
+func (w *writer) children(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		w.node(child)
+	}
+}
+
+// attr returns attribute value. Links are resolved against base url, unsafe links are dropped.
+func (w *writer) attr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key != key {
+			continue
+		}
+
+		if key != "href" && key != "src" {
+			return attr.Val
+		}
+
+		ref, err := w.base.Parse(strings.TrimSpace(attr.Val))
+		if err != nil {
+			return ""
+		}
+
+		switch ref.Scheme {
+		case "http", "https", "mailto", "tg":
+			return ref.String()
+		default:
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// text writes escaped text collapsing whitespaces outside of pre.
+func (w *writer) text(text string) {
+	if w.pre > 0 {
+		w.write(Escape(text))
+		return
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		w.space = w.space || text != ""
+		return
+	}
+
+	if startsWithSpace(text) {
+		w.space = true
+	}
+
+	for i, field := range fields {
+		if i > 0 {
+			w.space = true
+		}
+
+		w.write(Escape(field))
+	}
+
+	if endsWithSpace(text) {
+		w.space = true
+	}
+}
+
+// write writes text prepending pending space if text isn't at line start.
+func (w *writer) write(text string) {
+	if text == "" {
+		return
+	}
+
+	w.flush()
+
+	if w.space && w.written && w.newlines == 0 {
+		w.buf.WriteString(" ")
+	}
+
+	w.space = false
+	w.written = true
+	w.buf.WriteString(text)
+
+	if trimmed := strings.TrimRight(text, "\n"); trimmed == "" {
+		w.newlines += len(text)
+	} else {
+		w.newlines = len(text) - len(trimmed)
+	}
+}
+
+// tag writes tag. Pending newlines and space are written before opening tags to keep them outside of tags.
+func (w *writer) tag(tag string) {
+	if !strings.HasPrefix(tag, "</") {
+		w.flush()
+
+		if w.space && w.written && w.newlines == 0 {
+			w.buf.WriteString(" ")
+			w.space = false
+		}
+	}
+
+	w.buf.WriteString(tag)
+}
+
+// trim drops pending space before closing tag.
+func (w *writer) trim() {
+	w.space = false
+}
+
+func (w *writer) line() {
+	w.breaks(1)
+}
+
+func (w *writer) block() {
+	w.breaks(2)
+}
+
+func (w *writer) breaks(n int) {
+	w.space = false
+
+	if w.written && n > w.pending {
+		w.pending = n
+	}
+}
+
+// flush writes pending newlines.
+func (w *writer) flush() {
+	for w.newlines < w.pending {
+		w.buf.WriteString("\n")
+		w.newlines++
+	}
+
+	w.pending = 0
+}
+
+func startsWithSpace(text string) bool {
+	return text != "" && strings.TrimLeft(text, " \t\n\r\f") != text
+}
+
+func endsWithSpace(text string) bool {
+	return text != "" && strings.TrimRight(text, " \t\n\r\f") != text
+}
@@ -0,0 +1,248 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "Should escape reserved characters",
+			html: `<p>a &lt; b &amp;&amp; c &gt; d, [[link]] _[emphasis]_ *star*</p>`,
+			want: "a &lt; b &amp;&amp; c &gt; d, [[link]] _[emphasis]_ *star*",
+		},
+		{
+			name: "Should map inline tags and collapse whitespaces",
+			html: "<p><strong>Bold  </strong>and\n <em>italic</em> <del>old</del> <code>x := 1</code></p>",
+			want: "<b>Bold</b> and <i>italic</i> <s>old</s> <code>x := 1</code>",
+		},
+		{
+			name: "Should separate paragraphs and render headers bold",
+			html: "<h1>Title</h1><div><p>First.</p><p>Second<br>line.</p></div><hr><p>Third.</p>",
+			want: "<b>Title</b>\n\nFirst.\n\nSecond\nline.\n\n———\n\nThird.",
+		},
+		{
+			name: "Should render lists",
+			html: "<p>List:</p><ul><li>first</li><li>second<ol><li>nested</li></ol></li></ul><p>After.</p>",
+			want: "List:\n\n• first\n• second\n  1. nested\n\nAfter.",
+		},
+		{
+			name: "Should resolve links and escape attributes",
+			html: `<a href="/p/post?a=1&b=&quot;2&quot;">post</a> <a href="javascript:alert(1)">unsafe</a> <a>empty</a>`,
+			want: `<a href="https://example.com/p/post?a=1&amp;b=&quot;2&quot;">post</a> unsafe empty`,
+		},
+		{
+			name: "Should render images as links without nesting links",
+			html: `<p><img src="/image.png"> <a href="https://example.com/full.png"><img src="/thumb.png" alt="Chart"></a></p>`,
+			want: `<a href="https://example.com/image.png">Image</a> <a href="https://example.com/full.png">Chart</a>`,
+		},
+		{
+			name: "Should keep preformatted text",
+			html: "<pre><code>if a &lt; b {\n    return\n}</code></pre><script>alert(1)</script>",
+			want: "<pre>if a &lt; b {\n    return\n}</pre>",
+		},
+		{
+			name: "Should render quotes and figure captions",
+			html: "<blockquote><p>Quote.</p></blockquote><figure><img src=\"/a.png\"><figcaption>Caption</figcaption></figure>",
+			want: "<blockquote>Quote.</blockquote>\n\n<a href=\"https://example.com/a.png\">Image</a>\n<i>Caption</i>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.html, "https://example.com/post")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			requireBalanced(t, got)
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{
+			name:  "Should not truncate short text",
+			text:  "<b>Short</b> text",
+			limit: 10,
+			want:  "<b>Short</b> text",
+		},
+		{
+			name:  "Should truncate between words and close tags",
+			text:  `<b>Bold <a href="https://example.com">long link</a> text</b>`,
+			limit: 12,
+			want:  `<b>Bold <a href="https://example.com">long…</a></b>`,
+		},
+		{
+			name:  "Should truncate at line end in the second half",
+			text:  "First line\nSecond line",
+			limit: 15,
+			want:  "First line…",
+		},
+		{
+			name:  "Should count entity as one character",
+			text:  "a &lt; b &lt; c",
+			limit: 5,
+			want:  "a &lt;…",
+		},
+		{
+			name:  "Should cut long word",
+			text:  "<code>abcdefghij</code>",
+			limit: 5,
+			want:  "<code>abcd…</code>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Truncate(tt.text, tt.limit)
+			require.Equal(t, tt.want, got)
+			require.LessOrEqual(t, Len(got), tt.limit)
+			requireBalanced(t, got)
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "Should return empty part for empty text",
+			text:  "",
+			limit: 20,
+			want:  []string{""},
+		},
+		{
+			name:  "Should split between paragraphs",
+			text:  "First paragraph.\n\nSecond paragraph.\n\nThird.",
+			limit: 30,
+			want:  []string{"First paragraph.", "Second paragraph.\n\nThird."},
+		},
+		{
+			name:  "Should split between list items",
+			text:  "List:\n\n• first item\n• second item\n• third item",
+			limit: 30,
+			want:  []string{"List:\n\n• first item", "• second item\n• third item"},
+		},
+		{
+			name:  "Should reopen tags in the next part",
+			text:  "<pre>first line\nsecond line\nthird line</pre>",
+			limit: 25,
+			want:  []string{"<pre>first line\nsecond line</pre>", "<pre>third line</pre>"},
+		},
+		{
+			name:  "Should not split inside links",
+			text:  `Some words <a href="https://example.com/a-very-long-url">here</a> end`,
+			limit: 12,
+			want:  []string{"Some words", `<a href="https://example.com/a-very-long-url">here</a> end`},
+		},
+		{
+			name:  "Should move link with spaces to the next part",
+			text:  `Read <a href="https://example.com">the long title</a> now`,
+			limit: 15,
+			want:  []string{"Read", `<a href="https://example.com">the long title</a>`, "now"},
+		},
+		{
+			name:  "Should move code to the next part",
+			text:  "Run <code>go test ./...</code> again",
+			limit: 14,
+			want:  []string{"Run", "<code>go test ./...</code>", "again"},
+		},
+		{
+			name:  "Should count characters in UTF-16 code units",
+			text:  "😀😀😀",
+			limit: 4,
+			want:  []string{"😀😀", "😀"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.text, tt.limit)
+			require.Equal(t, tt.want, got)
+
+			for _, part := range got {
+				require.LessOrEqual(t, Len(part), tt.limit)
+				requireBalanced(t, part)
+			}
+		})
+	}
+}
+
+func TestLen(t *testing.T) {
+	require.Equal(t, 0, Len(""))
+	require.Equal(t, 5, Len("<b>a &lt; b</b>"))
+	require.Equal(t, 3, Len("я😀"))
+}
+
+func TestEscape(t *testing.T) {
+	require.Equal(t, "a &amp;&lt;b&gt; \"c\"", Escape(`a &<b> "c"`))
+	require.Equal(t, `<a href="https://example.com/?q=&quot;a&quot;&amp;b">&lt;Title&gt;</a>`, Link(`https://example.com/?q="a"&b`, "<Title>"))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{name: "Should accept Telegram tags", text: `📝 <b>Title</b> <a href="https://example.com">link</a> &lt;3`},
+		{name: "Should reject unclosed tag", text: "<b>Title", wantErr: "tag isn't closed: b"},
+		{name: "Should reject tags closed in wrong order", text: "<b><i>Title</b></i>", wantErr: "tag isn't opened: b"},
+		{name: "Should reject unsupported tag", text: "<h1>Title</h1>", wantErr: "tag isn't supported: h1"},
+		{name: "Should reject line break tag", text: "Title<br/>", wantErr: "tag isn't supported: br"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.text)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+// requireBalanced checks that every tag is closed in the right order.
+func requireBalanced(t *testing.T, text string) {
+	t.Helper()
+
+	var open []string
+
+	tokenizer := html.NewTokenizer(strings.NewReader(text))
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			require.Empty(t, open, "tags aren't closed: %s", text)
+			return
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			open = append(open, string(name))
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			require.NotEmpty(t, open, "tag %s isn't opened: %s", name, text)
+			require.Equal(t, open[len(open)-1], string(name), "tags are closed in wrong order: %s", text)
+			open = open[:len(open)-1]
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package renderer
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// unit is a tag or a single visible character of Telegram HTML.
+type unit struct {
+	raw string
+	// tag is tag name for tags, e.g. "b" for both <b> and </b>.
+	tag     string
+	closing bool
+	// width is length of character in UTF-16 code units, zero for tags.
+	width int
+}
+
+// wholeTags are tags which content isn't split unless it's longer than a part.
+var wholeTags = map[string]bool{"a": true, "pre": true, "code": true}
+
+// Len returns length of visible text of Telegram HTML in UTF-16 code units as Telegram counts message length.
+func Len(text string) int {
+	n := 0
+
+	for _, u := range units(text) {
+		n += u.width
+	}
+
+	return n
+}
+
+// Truncate cuts Telegram HTML to limit characters of visible text between lines or words.
+// Tags opened before the cut are closed, truncated text ends with ellipsis.
+func Truncate(text string, limit int) string {
+	us := units(text)
+
+	if Len(text) <= limit {
+		return text
+	}
+
+	// Leave room for ellipsis.
+	end, open := cut(us, 0, nil, limit-1, false)
+
+	return strings.TrimRight(raw(us[:end]), " \n") + "…" + closeTags(open)
+}
+
+// Split splits Telegram HTML into parts not longer than limit characters of visible text.
+// Text is split between paragraphs, lines or words. Links and code are kept whole unless they are longer than limit,
+// then tags open at the split are closed at the end of the part and opened again at the beginning of the next one.
+func Split(text string, limit int) []string {
+	us := units(text)
+
+	var (
+		parts []string
+		open  []unit
+		start int
+	)
+
+	for start < len(us) {
+		end, next := cut(us, start, open, limit, true)
+
+		part := openTags(open) + strings.TrimRight(raw(us[start:end]), " \n") + closeTags(next)
+		if Len(part) > 0 {
+			parts = append(parts, part)
+		}
+
+		start, open = end, next
+
+		// Skip whitespace at the beginning of the next part.
+		for start < len(us) && (us[start].raw == " " || us[start].raw == "\n") {
+			start++
+		}
+	}
+
+	if len(parts) == 0 {
+		parts = []string{""}
+	}
+
+	return parts
+}
+
+// cut returns end of part starting at start which is not longer than limit and tags open at its end.
+// Part is cut after empty line if it's in the second half of the part, otherwise after line end or space.
+// If whole is true, part is cut inside links and code only if there is no other place to cut.
+func cut(us []unit, start int, open []unit, limit int, whole bool) (int, []unit) {
+	type candidate struct {
+		end   int
+		count int
+		open  []unit
+	}
+
+	var (
+		// candidates are best cuts after empty line, line end and space outside of whole tags.
+		candidates [3]*candidate
+		// inner are best cuts inside whole tags.
+		inner [3]*candidate
+		count int
+		prev  string
+	)
+
+	open = append([]unit(nil), open...)
+
+	for i := start; i < len(us); i++ {
+		u := us[i]
+
+		if u.tag != "" {
+			open = track(open, u)
+			continue
+		}
+
+		// Character wider than limit is put into part anyway, so splitting always moves forward.
+		if count+u.width > limit && count > 0 {
+			for _, cs := range [][3]*candidate{candidates, inner} {
+				for _, c := range cs {
+					if c != nil && c.count >= limit/2 {
+						return c.end, c.open
+					}
+				}
+
+				for _, c := range cs[1:] {
+					if c != nil {
+						return c.end, c.open
+					}
+				}
+			}
+
+			return i, open
+		}
+
+		count += u.width
+
+		rank := -1
+
+		switch {
+		case u.raw == "\n" && prev == "\n":
+			rank = 0
+		case u.raw == "\n":
+			rank = 1
+		case u.raw == " ":
+			rank = 2
+		}
+
+		if rank != -1 {
+			c := &candidate{end: i + 1, count: count, open: append([]unit(nil), open...)}
+
+			if whole && insideWhole(open) {
+				inner[rank] = c
+			} else {
+				candidates[rank] = c
+			}
+		}
+
+		prev = u.raw
+	}
+
+	return len(us), open
+}
+
+func insideWhole(open []unit) bool {
+	for _, u := range open {
+		if wholeTags[u.tag] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// track updates stack of open tags.
+func track(open []unit, u unit) []unit {
+	if !u.closing {
+		return append(open, u)
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i].tag == u.tag {
+			return append(open[:i:i], open[i+1:]...)
+		}
+	}
+
+	return open
+}
+
+func openTags(open []unit) string {
+	var text strings.Builder
+
+	for _, u := range open {
+		text.WriteString(u.raw)
+	}
+
+	return text.String()
+}
+
+func closeTags(open []unit) string {
+	var text strings.Builder
+
+	for i := len(open) - 1; i >= 0; i-- {
+		text.WriteString("</" + open[i].tag + ">")
+	}
+
+	return text.String()
+}
+
+func raw(us []unit) string {
+	var text strings.Builder
+
+	for _, u := range us {
+		text.WriteString(u.raw)
+	}
+
+	return text.String()
+}
+
+// units splits Telegram HTML into tags and visible characters. Entity is a single character.
+func units(text string) []unit {
+	var us []unit
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '<':
+			end := strings.IndexByte(text[i:], '>')
+			if end == -1 {
+				end = len(text) - i - 1
+			}
+
+			tag := text[i : i+end+1]
+			name := strings.TrimPrefix(strings.Trim(tag, "<>"), "/")
+
+			if n := strings.IndexAny(name, " \t\n"); n != -1 {
+				name = name[:n]
+			}
+
+			us = append(us, unit{raw: tag, tag: name, closing: strings.HasPrefix(tag, "</")})
+			i += end + 1
+		case '&':
+			end := strings.IndexByte(text[i:], ';')
+			if end == -1 {
+				end = 0
+			}
+
+			us = append(us, unit{raw: text[i : i+end+1], width: 1})
+			i += end + 1
+		default:
+			r, size := utf8.DecodeRuneInString(text[i:])
+
+			width := 1
+			if r > 0xFFFF {
+				// Characters outside of Basic Multilingual Plane, e.g. emoji, are surrogate pairs in UTF-16.
+				width = 2
+			}
+
+			us = append(us, unit{raw: text[i : i+size], width: width})
+			i += size
+		}
+	}
+
+	return us
+}
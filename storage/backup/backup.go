@@ -0,0 +1,161 @@
+// Package backup exports keys of storage to JSON lines and imports them into any storage,
+// so bot data can be moved between backends.
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Types of record values.
+const (
+	TypeString = "string"
+	TypeSet    = "set"
+	TypeHash   = "hash"
+	TypeList   = "list"
+)
+
+type (
+	// Record is one key of storage. It's a line of dump.
+	Record struct {
+		Key     string            `json:"key"`
+		Type    string            `json:"type"`
+		Value   string            `json:"value,omitempty"`
+		Members []string          `json:"members,omitempty"`
+		Hash    map[string]string `json:"hash,omitempty"`
+		List    []string          `json:"list,omitempty"`
+		// ExpiresAt is set for keys with expire. It's restored for strings only,
+		// as storages have no expire for sets, hashes and lists.
+		ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	}
+
+	// Scanner is implemented by storages which can list all their keys.
+	Scanner interface {
+		Scan(ctx context.Context, fn func(record Record) error) error
+	}
+
+	// Storage is storage which records are imported into.
+	Storage interface {
+		Set(ctx context.Context, key, value string, expire time.Duration) error
+		Del(ctx context.Context, key string) error
+		SAdd(ctx context.Context, key string, members ...string) error
+		HSet(ctx context.Context, key, field, value string) error
+		RPush(ctx context.Context, key string, values ...string) error
+	}
+)
+
+// Export writes all keys of storage to w as JSON lines and returns number of written keys.
+func Export(ctx context.Context, storage Scanner, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	count := 0
+
+	err := storage.Scan(ctx, func(record Record) error {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("encode record failed: %s, key: %s", err, record.Key)
+		}
+
+		count++
+
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("scan storage failed: %s", err)
+	}
+
+	return count, nil
+}
+
+// Import reads JSON lines from r and writes them to storage replacing existing keys.
+// Keys which expired since export are skipped. It returns number of imported keys.
+func Import(ctx context.Context, storage Storage, r io.Reader, now time.Time) (int, error) {
+	scanner := bufio.NewScanner(r)
+	// Cached catalogs are large, so lines can be much longer than default limit.
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+
+	count := 0
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return count, fmt.Errorf("decode record failed: %s, line: %d", err, line)
+		}
+
+		imported, err := Write(ctx, storage, record, now)
+		if err != nil {
+			return count, fmt.Errorf("%s, line: %d", err, line)
+		}
+
+		if imported {
+			count++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, fmt.Errorf("read dump failed: %s", err)
+	}
+
+	return count, nil
+}
+
+// Migrate copies all keys from one storage to another and returns number of copied keys.
+func Migrate(ctx context.Context, from Scanner, to Storage, now time.Time) (int, error) {
+	count := 0
+
+	err := from.Scan(ctx, func(record Record) error {
+		imported, err := Write(ctx, to, record, now)
+		if imported {
+			count++
+		}
+
+		return err
+	})
+	if err != nil {
+		return count, fmt.Errorf("migrate storage failed: %s", err)
+	}
+
+	return count, nil
+}
+
+// Write writes record to storage replacing existing key. It returns false if record expired.
+func Write(ctx context.Context, storage Storage, record Record, now time.Time) (bool, error) {
+	var expire time.Duration
+
+	if record.ExpiresAt != nil {
+		if expire = record.ExpiresAt.Sub(now); expire <= 0 {
+			return false, nil
+		}
+	}
+
+	if err := storage.Del(ctx, record.Key); err != nil {
+		return false, err
+	}
+
+	switch record.Type {
+	case TypeString:
+		return true, storage.Set(ctx, record.Key, record.Value, expire)
+	case TypeSet:
+		return true, storage.SAdd(ctx, record.Key, record.Members...)
+	case TypeHash:
+		for field, value := range record.Hash {
+			if err := storage.HSet(ctx, record.Key, field, value); err != nil {
+				return false, err
+			}
+		}
+
+		return true, nil
+	case TypeList:
+		return true, storage.RPush(ctx, record.Key, record.List...)
+	}
+
+	return false, fmt.Errorf("unknown record type: %s, key: %s", record.Type, record.Key)
+}
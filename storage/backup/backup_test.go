@@ -0,0 +1,152 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot"
+	"github.com/ndrewnee/lesswrong-bot/storage/backup"
+	"github.com/ndrewnee/lesswrong-bot/storage/bolt"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+)
+
+var now = time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.TODO()
+	from := newMemoryStorage(t)
+
+	var dump bytes.Buffer
+
+	count, err := backup.Export(ctx, from, &dump)
+	require.NoError(t, err)
+	require.Equal(t, 6, count)
+	require.Equal(t, 6, strings.Count(dump.String(), "\n"))
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		count int
+		posts string
+	}{
+		{name: "All keys", now: now, count: 6, posts: "[]"},
+		{name: "Expired keys are skipped", now: now.Add(2 * time.Hour), count: 5, posts: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := newBoltStorage(t)
+
+			// Existing key of other type is replaced.
+			require.NoError(t, to.RPush(ctx, "settings:1", "stale"))
+
+			count, err := backup.Import(ctx, to, bytes.NewReader(dump.Bytes()), tt.now)
+			require.NoError(t, err)
+			require.Equal(t, tt.count, count)
+
+			requireCopied(t, to)
+
+			posts, err := to.Get(ctx, "posts:astralcodexten")
+			require.NoError(t, err)
+			require.Equal(t, tt.posts, posts)
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		dump    string
+		count   int
+		wantErr string
+	}{
+		{
+			name:    "Invalid JSON",
+			dump:    `{"key":"source:1","type":"string","value":"2"}` + "\n\n{",
+			count:   1,
+			wantErr: "decode record failed: unexpected end of JSON input, line: 3",
+		},
+		{
+			name:    "Unknown type",
+			dump:    `{"key":"stream:1","type":"stream"}`,
+			wantErr: "unknown record type: stream, key: stream:1, line: 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := backup.Import(context.TODO(), memory.NewStorage(), strings.NewReader(tt.dump), now)
+			require.EqualError(t, err, tt.wantErr)
+			require.Equal(t, tt.count, count)
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.TODO()
+	to := newBoltStorage(t)
+
+	count, err := backup.Migrate(ctx, newMemoryStorage(t), to, now)
+	require.NoError(t, err)
+	require.Equal(t, 6, count)
+
+	requireCopied(t, to)
+
+	posts, err := to.Get(ctx, "posts:astralcodexten")
+	require.NoError(t, err)
+	require.Equal(t, "[]", posts)
+}
+
+func newMemoryStorage(t *testing.T) *memory.Storage {
+	ctx := context.TODO()
+	storage := memory.NewStorageWithOptions(memory.Options{Now: func() time.Time { return now }})
+
+	require.NoError(t, storage.Set(ctx, "source:1", "2", 0))
+	require.NoError(t, storage.Set(ctx, "posts:astralcodexten", "[]", time.Hour))
+	require.NoError(t, storage.SAdd(ctx, "served:1:1", "a", "b"))
+	require.NoError(t, storage.HSet(ctx, "settings:1", "source", "2"))
+	require.NoError(t, storage.HSet(ctx, "settings:1", "instantview", "true"))
+	require.NoError(t, storage.RPush(ctx, "history:1", "b", "a"))
+	require.NoError(t, storage.Set(ctx, "title", `<a href="https://lesswrong.ru">Ссылка</a>`, 0))
+
+	return storage
+}
+
+func newBoltStorage(t *testing.T) *bolt.Storage {
+	storage, err := bolt.NewStorage(filepath.Join(t.TempDir(), "bot.db"))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+	return storage
+}
+
+func requireCopied(t *testing.T, storage bot.Storage) {
+	ctx := context.TODO()
+
+	source, err := storage.Get(ctx, "source:1")
+	require.NoError(t, err)
+	require.Equal(t, "2", source)
+
+	title, err := storage.Get(ctx, "title")
+	require.NoError(t, err)
+	require.Equal(t, `<a href="https://lesswrong.ru">Ссылка</a>`, title)
+
+	members, err := storage.SMembers(ctx, "served:1:1")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b"}, members)
+
+	settings, err := storage.HGetAll(ctx, "settings:1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"source": "2", "instantview": "true"}, settings)
+
+	history, err := storage.LRange(ctx, "history:1", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "a"}, history)
+}
@@ -0,0 +1,632 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	bbolt "go.etcd.io/bbolt"
+
+	"github.com/ndrewnee/lesswrong-bot/storage/backup"
+)
+
+// DefaultCleanupInterval is interval between removals of expired keys in storage opened by NewStorage.
+const DefaultCleanupInterval = time.Minute
+
+var (
+	// ErrWrongType is returned like in redis when operation is applied to key holding value of another type.
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	// ErrNotInteger is returned by Incr when value isn't an integer.
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+)
+
+var (
+	metaBucket = []byte("meta")
+	keysBucket = []byte("keys")
+	versionKey = []byte("version")
+)
+
+// migrations upgrade database schema. Applied migrations are never changed, new ones are appended.
+var migrations = []func(tx *bbolt.Tx) error{
+	// 1: values of all types are kept as JSON records by key.
+	func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(keysBucket)
+		return err
+	},
+}
+
+type (
+	// Storage keeps strings, sets, hashes and lists in BoltDB file, so they survive restarts.
+	// Like in redis keys with expire are removed after it passes and values of all types share keys.
+	Storage struct {
+		db   *bbolt.DB
+		now  func() time.Time
+		stop chan struct{}
+		once sync.Once
+	}
+
+	Options struct {
+		// CleanupInterval is interval between removals of expired keys in background.
+		// Zero disables background cleanup, expired keys are skipped on access anyway.
+		CleanupInterval time.Duration
+		Now             func() time.Time
+	}
+
+	kind string
+
+	// record is value of key stored as JSON.
+	record struct {
+		Kind  kind              `json:"kind"`
+		Value string            `json:"value,omitempty"`
+		Set   map[string]bool   `json:"set,omitempty"`
+		Hash  map[string]string `json:"hash,omitempty"`
+		List  []string          `json:"list,omitempty"`
+		// ExpiresAt is unix time in nanoseconds when key expires. Zero means key never expires.
+		ExpiresAt int64 `json:"expires_at,omitempty"`
+	}
+)
+
+const (
+	kindString kind = "string"
+	kindSet    kind = "set"
+	kindHash   kind = "hash"
+	kindList   kind = "list"
+)
+
+// NewStorage opens database file creating it if it doesn't exist.
+func NewStorage(path string) (*Storage, error) {
+	return NewStorageWithOptions(path, Options{CleanupInterval: DefaultCleanupInterval})
+}
+
+func NewStorageWithOptions(path string, opts Options) (*Storage, error) {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt database failed: %s, path: %s", err, path)
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate bolt database failed: %s, path: %s", err, path)
+	}
+
+	s := &Storage{
+		db:   db,
+		now:  opts.Now,
+		stop: make(chan struct{}),
+	}
+
+	if opts.CleanupInterval > 0 {
+		go s.runJanitor(opts.CleanupInterval)
+	}
+
+	return s, nil
+}
+
+// Close stops background cleanup and closes database.
+func (s *Storage) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return s.db.Close()
+}
+
+// Version returns number of applied migrations.
+func (s *Storage) Version() (int, error) {
+	var version int
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		version, err = schemaVersion(tx)
+		return err
+	})
+
+	return version, err
+}
+
+func (s *Storage) Get(_ context.Context, key string) (string, error) {
+	var value string
+
+	err := s.view("get", key, func(tx *bbolt.Tx) error {
+		r, err := s.get(tx, key, kindString)
+		if r != nil {
+			value = r.Value
+		}
+
+		return err
+	})
+
+	return value, err
+}
+
+// Set sets value of key replacing value of any type. Zero expire means key never expires.
+func (s *Storage) Set(_ context.Context, key, value string, expire time.Duration) error {
+	return s.update("set", key, func(tx *bbolt.Tx) error {
+		return s.put(tx, key, &record{Kind: kindString, Value: value, ExpiresAt: s.expiresAt(expire)})
+	})
+}
+
+// SetNX sets value of key only if it doesn't exist. It returns true if value was set.
+func (s *Storage) SetNX(_ context.Context, key, value string, expire time.Duration) (bool, error) {
+	var ok bool
+
+	err := s.update("setnx", key, func(tx *bbolt.Tx) error {
+		r, err := s.lookup(tx, key)
+		if err != nil || r != nil {
+			return err
+		}
+
+		ok = true
+
+		return s.put(tx, key, &record{Kind: kindString, Value: value, ExpiresAt: s.expiresAt(expire)})
+	})
+
+	return ok, err
+}
+
+func (s *Storage) Exists(_ context.Context, key string) (bool, error) {
+	var ok bool
+
+	err := s.view("exists", key, func(tx *bbolt.Tx) error {
+		r, err := s.lookup(tx, key)
+		ok = r != nil
+
+		return err
+	})
+
+	return ok, err
+}
+
+func (s *Storage) Del(_ context.Context, key string) error {
+	return s.update("del", key, func(tx *bbolt.Tx) error {
+		return tx.Bucket(keysBucket).Delete([]byte(key))
+	})
+}
+
+// Incr increments integer value of key and returns new value. Missing key is set to 1. Expire of key is kept.
+func (s *Storage) Incr(_ context.Context, key string) (int64, error) {
+	var value int64
+
+	err := s.update("incr", key, func(tx *bbolt.Tx) error {
+		r, err := s.get(tx, key, kindString)
+		if err != nil {
+			return err
+		}
+
+		if r == nil {
+			r = &record{Kind: kindString, Value: "0"}
+		}
+
+		value, err = strconv.ParseInt(r.Value, 10, 64)
+		if err != nil {
+			return ErrNotInteger
+		}
+
+		value++
+		r.Value = strconv.FormatInt(value, 10)
+
+		return s.put(tx, key, r)
+	})
+
+	return value, err
+}
+
+func (s *Storage) HSet(_ context.Context, key, field, value string) error {
+	return s.modify("hset", key, kindHash, func(r *record) {
+		if r.Hash == nil {
+			r.Hash = make(map[string]string)
+		}
+
+		r.Hash[field] = value
+	})
+}
+
+// HGet returns value of hash field or empty string if there is no such field.
+func (s *Storage) HGet(_ context.Context, key, field string) (string, error) {
+	var value string
+
+	err := s.view("hget", key, func(tx *bbolt.Tx) error {
+		r, err := s.get(tx, key, kindHash)
+		if r != nil {
+			value = r.Hash[field]
+		}
+
+		return err
+	})
+
+	return value, err
+}
+
+func (s *Storage) HGetAll(_ context.Context, key string) (map[string]string, error) {
+	hash := make(map[string]string)
+
+	err := s.view("hgetall", key, func(tx *bbolt.Tx) error {
+		r, err := s.get(tx, key, kindHash)
+		if r != nil {
+			hash = r.Hash
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hash, nil
+}
+
+func (s *Storage) HDel(_ context.Context, key string, fields ...string) error {
+	return s.modify("hdel", key, kindHash, func(r *record) {
+		for _, field := range fields {
+			delete(r.Hash, field)
+		}
+	})
+}
+
+func (s *Storage) SAdd(_ context.Context, key string, members ...string) error {
+	return s.modify("sadd", key, kindSet, func(r *record) {
+		if r.Set == nil {
+			r.Set = make(map[string]bool)
+		}
+
+		for _, member := range members {
+			r.Set[member] = true
+		}
+	})
+}
+
+func (s *Storage) SRem(_ context.Context, key string, members ...string) error {
+	return s.modify("srem", key, kindSet, func(r *record) {
+		for _, member := range members {
+			delete(r.Set, member)
+		}
+	})
+}
+
+func (s *Storage) SIsMember(_ context.Context, key, member string) (bool, error) {
+	var ok bool
+
+	err := s.view("sismember", key, func(tx *bbolt.Tx) error {
+		r, err := s.get(tx, key, kindSet)
+		if r != nil {
+			ok = r.Set[member]
+		}
+
+		return err
+	})
+
+	return ok, err
+}
+
+func (s *Storage) SMembers(_ context.Context, key string) ([]string, error) {
+	members := []string{}
+
+	err := s.view("smembers", key, func(tx *bbolt.Tx) error {
+		r, err := s.get(tx, key, kindSet)
+		if r != nil {
+			for member := range r.Set {
+				members = append(members, member)
+			}
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// LPush inserts values at the head of list one by one, so the last value becomes the first.
+func (s *Storage) LPush(_ context.Context, key string, values ...string) error {
+	return s.modify("lpush", key, kindList, func(r *record) {
+		head := make([]string, 0, len(values)+len(r.List))
+		for i := len(values) - 1; i >= 0; i-- {
+			head = append(head, values[i])
+		}
+
+		r.List = append(head, r.List...)
+	})
+}
+
+func (s *Storage) RPush(_ context.Context, key string, values ...string) error {
+	return s.modify("rpush", key, kindList, func(r *record) {
+		r.List = append(r.List, values...)
+	})
+}
+
+// LRange returns list elements from start to stop inclusive. Negative indexes count from the end like in redis.
+func (s *Storage) LRange(_ context.Context, key string, start, stop int64) ([]string, error) {
+	values := []string{}
+
+	err := s.view("lrange", key, func(tx *bbolt.Tx) error {
+		r, err := s.get(tx, key, kindList)
+		if r != nil {
+			from, to := listRange(len(r.List), start, stop)
+			values = append(values, r.List[from:to]...)
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// LTrim keeps only list elements from start to stop inclusive.
+func (s *Storage) LTrim(_ context.Context, key string, start, stop int64) error {
+	return s.modify("ltrim", key, kindList, func(r *record) {
+		from, to := listRange(len(r.List), start, stop)
+		r.List = r.List[from:to]
+	})
+}
+
+// Scan calls fn for each key which isn't expired. Database is read in one transaction, so fn must not write to it.
+func (s *Storage) Scan(ctx context.Context, fn func(record backup.Record) error) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(keysBucket).ForEach(func(key, data []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var r record
+			if err := json.Unmarshal(data, &r); err != nil {
+				return fmt.Errorf("unmarshal record failed: %s, key: %s", err, key)
+			}
+
+			if s.expired(&r) {
+				return nil
+			}
+
+			return fn(r.backup(string(key)))
+		})
+	})
+}
+
+func (s *Storage) view(command, key string, fn func(tx *bbolt.Tx) error) error {
+	if err := s.db.View(fn); err != nil {
+		return fmt.Errorf("%s bolt key failed: %w, key: %s", command, err, key)
+	}
+
+	return nil
+}
+
+func (s *Storage) update(command, key string, fn func(tx *bbolt.Tx) error) error {
+	if err := s.db.Update(fn); err != nil {
+		return fmt.Errorf("%s bolt key failed: %w, key: %s", command, err, key)
+	}
+
+	return nil
+}
+
+// modify changes set, hash or list of key. Key of empty set, hash or list is removed like in redis.
+func (s *Storage) modify(command, key string, kind kind, fn func(r *record)) error {
+	return s.update(command, key, func(tx *bbolt.Tx) error {
+		r, err := s.get(tx, key, kind)
+		if err != nil {
+			return err
+		}
+
+		if r == nil {
+			r = &record{Kind: kind}
+		}
+
+		fn(r)
+
+		if len(r.Set) == 0 && len(r.Hash) == 0 && len(r.List) == 0 {
+			return tx.Bucket(keysBucket).Delete([]byte(key))
+		}
+
+		return s.put(tx, key, r)
+	})
+}
+
+// lookup returns record of key or nil if it doesn't exist or expired.
+func (s *Storage) lookup(tx *bbolt.Tx, key string) (*record, error) {
+	data := tx.Bucket(keysBucket).Get([]byte(key))
+	if data == nil {
+		return nil, nil
+	}
+
+	var r record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("unmarshal record failed: %s", err)
+	}
+
+	if s.expired(&r) {
+		return nil, nil
+	}
+
+	return &r, nil
+}
+
+// get returns record of key if it holds value of kind.
+func (s *Storage) get(tx *bbolt.Tx, key string, kind kind) (*record, error) {
+	r, err := s.lookup(tx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if r != nil && r.Kind != kind {
+		return nil, ErrWrongType
+	}
+
+	return r, nil
+}
+
+func (s *Storage) put(tx *bbolt.Tx, key string, r *record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal record failed: %s", err)
+	}
+
+	return tx.Bucket(keysBucket).Put([]byte(key), data)
+}
+
+func (s *Storage) expiresAt(expire time.Duration) int64 {
+	if expire <= 0 {
+		return 0
+	}
+
+	return s.now().Add(expire).UnixNano()
+}
+
+func (s *Storage) expired(r *record) bool {
+	return r.ExpiresAt != 0 && s.now().UnixNano() >= r.ExpiresAt
+}
+
+// removeExpired removes all expired keys.
+func (s *Storage) removeExpired() error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		var expired [][]byte
+
+		err := tx.Bucket(keysBucket).ForEach(func(key, data []byte) error {
+			var r record
+			if err := json.Unmarshal(data, &r); err != nil {
+				return fmt.Errorf("unmarshal record failed: %s, key: %s", err, key)
+			}
+
+			if s.expired(&r) {
+				expired = append(expired, key)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := tx.Bucket(keysBucket).Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *Storage) runJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			_ = s.removeExpired()
+		}
+	}
+}
+
+// backup returns record of key for backup.
+func (r *record) backup(key string) backup.Record {
+	record := backup.Record{
+		Key:   key,
+		Type:  string(r.Kind),
+		Value: r.Value,
+		Hash:  r.Hash,
+		List:  r.List,
+	}
+
+	for member := range r.Set {
+		record.Members = append(record.Members, member)
+	}
+
+	if r.ExpiresAt != 0 {
+		expiresAt := time.Unix(0, r.ExpiresAt)
+		record.ExpiresAt = &expiresAt
+	}
+
+	return record
+}
+
+// migrate applies migrations which weren't applied yet, each in its own transaction.
+func migrate(db *bbolt.DB) error {
+	for {
+		done := false
+
+		err := db.Update(func(tx *bbolt.Tx) error {
+			version, err := schemaVersion(tx)
+			if err != nil {
+				return err
+			}
+
+			if version > len(migrations) {
+				return fmt.Errorf("database version %d is newer than supported %d", version, len(migrations))
+			}
+
+			if version == len(migrations) {
+				done = true
+				return nil
+			}
+
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("migration %d failed: %s", version+1, err)
+			}
+
+			return tx.Bucket(metaBucket).Put(versionKey, []byte(strconv.Itoa(version+1)))
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// schemaVersion returns number of applied migrations creating meta bucket if it doesn't exist.
+func schemaVersion(tx *bbolt.Tx) (int, error) {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		if !tx.Writable() {
+			return 0, nil
+		}
+
+		var err error
+		if meta, err = tx.CreateBucket(metaBucket); err != nil {
+			return 0, err
+		}
+	}
+
+	value := meta.Get(versionKey)
+	if value == nil {
+		return 0, nil
+	}
+
+	return strconv.Atoi(string(value))
+}
+
+// listRange converts redis style inclusive range with negative indexes to slice bounds.
+func listRange(length int, start, stop int64) (int, int) {
+	n := int64(length)
+
+	if start < 0 {
+		start += n
+	}
+
+	if stop < 0 {
+		stop += n
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if stop >= n {
+		stop = n - 1
+	}
+
+	if start > stop {
+		return 0, 0
+	}
+
+	return int(start), int(stop) + 1
+}
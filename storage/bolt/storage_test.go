@@ -0,0 +1,107 @@
+package bolt
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	bbolt "go.etcd.io/bbolt"
+
+	"github.com/ndrewnee/lesswrong-bot/bot"
+	"github.com/ndrewnee/lesswrong-bot/storage/storagetest"
+)
+
+func TestStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (bot.Storage, func(d time.Duration)) {
+		var (
+			mu  sync.Mutex
+			now = time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+		)
+
+		storage, err := NewStorageWithOptions(filepath.Join(t.TempDir(), "bot.db"), Options{
+			Now: func() time.Time {
+				mu.Lock()
+				defer mu.Unlock()
+
+				return now
+			},
+		})
+		require.NoError(t, err)
+
+		t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+		return storage, func(d time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			now = now.Add(d)
+		}
+	})
+}
+
+func TestStoragePersistence(t *testing.T) {
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "bot.db")
+
+	storage, err := NewStorage(path)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.Set(ctx, "source:1", "2", 0))
+	require.NoError(t, storage.Set(ctx, "posts:astralcodexten", "[]", time.Millisecond))
+	require.NoError(t, storage.SAdd(ctx, "served:1:1", "https://lesswrong.ru/w/moloch"))
+	require.NoError(t, storage.Close())
+
+	time.Sleep(time.Millisecond)
+
+	storage, err = NewStorage(path)
+	require.NoError(t, err)
+
+	defer storage.Close()
+
+	version, err := storage.Version()
+	require.NoError(t, err)
+	require.Equal(t, len(migrations), version)
+
+	value, err := storage.Get(ctx, "source:1")
+	require.NoError(t, err)
+	require.Equal(t, "2", value)
+
+	value, err = storage.Get(ctx, "posts:astralcodexten")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	ok, err := storage.SIsMember(ctx, "served:1:1", "https://lesswrong.ru/w/moloch")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// Expired keys are removed from file.
+	require.NoError(t, storage.removeExpired())
+	require.NoError(t, storage.db.View(func(tx *bbolt.Tx) error {
+		require.Equal(t, 2, tx.Bucket(keysBucket).Stats().KeyN)
+		return nil
+	}))
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+
+	db, err := bbolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+
+	// Database from newer version of bot isn't opened.
+	require.NoError(t, db.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucket(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		return meta.Put(versionKey, []byte("100"))
+	}))
+	require.NoError(t, db.Close())
+
+	_, err = NewStorage(path)
+	require.EqualError(t, err, "migrate bolt database failed: database version 100 is newer than supported 1, path: "+path)
+}
@@ -0,0 +1,36 @@
+package memory_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ndrewnee/lesswrong-bot/bot"
+	"github.com/ndrewnee/lesswrong-bot/storage/memory"
+	"github.com/ndrewnee/lesswrong-bot/storage/storagetest"
+)
+
+func TestStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (bot.Storage, func(d time.Duration)) {
+		var (
+			mu  sync.Mutex
+			now = time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)
+		)
+
+		storage := memory.NewStorageWithOptions(memory.Options{
+			Now: func() time.Time {
+				mu.Lock()
+				defer mu.Unlock()
+
+				return now
+			},
+		})
+
+		return storage, func(d time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			now = now.Add(d)
+		}
+	})
+}
@@ -1,25 +1,613 @@
 package memory
 
 import (
+	"container/list"
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/ndrewnee/lesswrong-bot/storage/backup"
 )
 
-type Storage struct {
-	cache map[string]string
-}
+// DefaultCleanupInterval is interval between removals of expired keys in storage created by NewStorage.
+const DefaultCleanupInterval = time.Minute
+
+var (
+	// ErrWrongType is returned like in redis when operation is applied to key holding value of another type.
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	// ErrNotInteger is returned by Incr when value isn't an integer.
+	ErrNotInteger = errors.New("value is not an integer or out of range")
+)
+
+type (
+	// Storage keeps strings, sets, hashes and lists in memory. Like in redis keys with expire are removed
+	// after it passes and values of all types share keys. If MaxKeys is set, the least recently used keys are evicted.
+	Storage struct {
+		mu      sync.Mutex
+		entries map[string]*list.Element
+		// lru has the most recently used entries in front.
+		lru     *list.List
+		maxKeys int
+		now     func() time.Time
+		stop    chan struct{}
+		once    sync.Once
+	}
+
+	Options struct {
+		// MaxKeys is max number of keys in storage. Zero means no limit.
+		MaxKeys int
+		// CleanupInterval is interval between removals of expired keys in background.
+		// Zero disables background cleanup, expired keys are removed on access anyway.
+		CleanupInterval time.Duration
+		Now             func() time.Time
+	}
+
+	kind int
+
+	entry struct {
+		key       string
+		kind      kind
+		value     string
+		set       map[string]struct{}
+		hash      map[string]string
+		list      []string
+		expiresAt time.Time
+	}
+)
+
+const (
+	kindString kind = iota
+	kindSet
+	kindHash
+	kindList
+)
 
 func NewStorage() *Storage {
-	return &Storage{
-		cache: make(map[string]string),
+	return NewStorageWithOptions(Options{CleanupInterval: DefaultCleanupInterval})
+}
+
+func NewStorageWithOptions(opts Options) *Storage {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	s := &Storage{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		maxKeys: opts.MaxKeys,
+		now:     opts.Now,
+		stop:    make(chan struct{}),
+	}
+
+	if opts.CleanupInterval > 0 {
+		go s.runJanitor(opts.CleanupInterval)
 	}
+
+	return s
+}
+
+// Close stops background cleanup.
+func (s *Storage) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	return nil
 }
 
 func (s *Storage) Get(_ context.Context, key string) (string, error) {
-	return s.cache[key], nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindString)
+	if err != nil || e == nil {
+		return "", wrapError("get", key, err)
+	}
+
+	return e.value, nil
+}
+
+// Set sets value of key replacing value of any type. Zero expire means key never expires.
+func (s *Storage) Set(_ context.Context, key, value string, expire time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(&entry{key: key, kind: kindString, value: value, expiresAt: s.expiresAt(expire)})
+
+	return nil
+}
+
+// SetNX sets value of key only if it doesn't exist. It returns true if value was set.
+func (s *Storage) SetNX(_ context.Context, key, value string, expire time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookup(key) != nil {
+		return false, nil
+	}
+
+	s.put(&entry{key: key, kind: kindString, value: value, expiresAt: s.expiresAt(expire)})
+
+	return true, nil
+}
+
+func (s *Storage) Exists(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lookup(key) != nil, nil
+}
+
+func (s *Storage) Del(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+
+	return nil
+}
+
+// Incr increments integer value of key and returns new value. Missing key is set to 1. Expire of key is kept.
+func (s *Storage) Incr(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindString)
+	if err != nil {
+		return 0, wrapError("incr", key, err)
+	}
+
+	if e == nil {
+		e = &entry{key: key, kind: kindString, value: "0"}
+		s.put(e)
+	}
+
+	value, err := strconv.ParseInt(e.value, 10, 64)
+	if err != nil {
+		return 0, wrapError("incr", key, ErrNotInteger)
+	}
+
+	value++
+	e.value = strconv.FormatInt(value, 10)
+
+	return value, nil
+}
+
+func (s *Storage) HSet(_ context.Context, key, field, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.getOrCreate(key, kindHash)
+	if err != nil {
+		return wrapError("hset", key, err)
+	}
+
+	e.hash[field] = value
+
+	return nil
+}
+
+// HGet returns value of hash field or empty string if there is no such field.
+func (s *Storage) HGet(_ context.Context, key, field string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindHash)
+	if err != nil || e == nil {
+		return "", wrapError("hget", key, err)
+	}
+
+	return e.hash[field], nil
+}
+
+func (s *Storage) HGetAll(_ context.Context, key string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := make(map[string]string)
+
+	e, err := s.get(key, kindHash)
+	if err != nil {
+		return nil, wrapError("hgetall", key, err)
+	}
+
+	if e != nil {
+		for field, value := range e.hash {
+			hash[field] = value
+		}
+	}
+
+	return hash, nil
+}
+
+func (s *Storage) HDel(_ context.Context, key string, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindHash)
+	if err != nil || e == nil {
+		return wrapError("hdel", key, err)
+	}
+
+	for _, field := range fields {
+		delete(e.hash, field)
+	}
+
+	s.removeEmpty(e)
+
+	return nil
+}
+
+func (s *Storage) SAdd(_ context.Context, key string, members ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.getOrCreate(key, kindSet)
+	if err != nil {
+		return wrapError("sadd", key, err)
+	}
+
+	for _, member := range members {
+		e.set[member] = struct{}{}
+	}
+
+	s.removeEmpty(e)
+
+	return nil
+}
+
+func (s *Storage) SRem(_ context.Context, key string, members ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindSet)
+	if err != nil || e == nil {
+		return wrapError("srem", key, err)
+	}
+
+	for _, member := range members {
+		delete(e.set, member)
+	}
+
+	s.removeEmpty(e)
+
+	return nil
+}
+
+func (s *Storage) SIsMember(_ context.Context, key, member string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindSet)
+	if err != nil || e == nil {
+		return false, wrapError("sismember", key, err)
+	}
+
+	_, ok := e.set[member]
+
+	return ok, nil
+}
+
+func (s *Storage) SMembers(_ context.Context, key string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindSet)
+	if err != nil {
+		return nil, wrapError("smembers", key, err)
+	}
+
+	members := []string{}
+
+	if e != nil {
+		for member := range e.set {
+			members = append(members, member)
+		}
+	}
+
+	return members, nil
 }
 
-func (s *Storage) Set(_ context.Context, key, value string, _ time.Duration) error {
-	s.cache[key] = value
+// LPush inserts values at the head of list one by one, so the last value becomes the first.
+func (s *Storage) LPush(_ context.Context, key string, values ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.getOrCreate(key, kindList)
+	if err != nil {
+		return wrapError("lpush", key, err)
+	}
+
+	head := make([]string, 0, len(values)+len(e.list))
+	for i := len(values) - 1; i >= 0; i-- {
+		head = append(head, values[i])
+	}
+
+	e.list = append(head, e.list...)
+
+	s.removeEmpty(e)
+
+	return nil
+}
+
+func (s *Storage) RPush(_ context.Context, key string, values ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.getOrCreate(key, kindList)
+	if err != nil {
+		return wrapError("rpush", key, err)
+	}
+
+	e.list = append(e.list, values...)
+
+	s.removeEmpty(e)
+
 	return nil
 }
+
+// LRange returns list elements from start to stop inclusive. Negative indexes count from the end like in redis.
+func (s *Storage) LRange(_ context.Context, key string, start, stop int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindList)
+	if err != nil {
+		return nil, wrapError("lrange", key, err)
+	}
+
+	values := []string{}
+
+	if e != nil {
+		from, to := listRange(len(e.list), start, stop)
+		values = append(values, e.list[from:to]...)
+	}
+
+	return values, nil
+}
+
+// LTrim keeps only list elements from start to stop inclusive.
+func (s *Storage) LTrim(_ context.Context, key string, start, stop int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, err := s.get(key, kindList)
+	if err != nil || e == nil {
+		return wrapError("ltrim", key, err)
+	}
+
+	from, to := listRange(len(e.list), start, stop)
+	e.list = append([]string(nil), e.list[from:to]...)
+
+	s.removeEmpty(e)
+
+	return nil
+}
+
+// Scan calls fn for each key which isn't expired. Storage isn't locked while fn is called.
+func (s *Storage) Scan(ctx context.Context, fn func(record backup.Record) error) error {
+	s.mu.Lock()
+
+	records := make([]backup.Record, 0, len(s.entries))
+
+	for element := s.lru.Back(); element != nil; element = element.Prev() {
+		e := element.Value.(*entry)
+		if !s.expired(e) {
+			records = append(records, e.record())
+		}
+	}
+
+	s.mu.Unlock()
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Len returns number of keys including expired ones which weren't removed yet.
+func (s *Storage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// lookup returns entry of key marking it as recently used. Expired entry is removed.
+func (s *Storage) lookup(key string) *entry {
+	element, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+
+	e := element.Value.(*entry)
+	if s.expired(e) {
+		s.remove(element)
+		return nil
+	}
+
+	s.lru.MoveToFront(element)
+
+	return e
+}
+
+// get returns entry of key if it holds value of kind.
+func (s *Storage) get(key string, kind kind) (*entry, error) {
+	e := s.lookup(key)
+	if e != nil && e.kind != kind {
+		return nil, ErrWrongType
+	}
+
+	return e, nil
+}
+
+// getOrCreate returns entry of key adding empty one if it doesn't exist.
+func (s *Storage) getOrCreate(key string, kind kind) (*entry, error) {
+	e, err := s.get(key, kind)
+	if err != nil || e != nil {
+		return e, err
+	}
+
+	e = &entry{key: key, kind: kind}
+
+	switch kind {
+	case kindSet:
+		e.set = make(map[string]struct{})
+	case kindHash:
+		e.hash = make(map[string]string)
+	}
+
+	s.put(e)
+
+	return e, nil
+}
+
+// put adds or replaces entry and evicts the least recently used entries if storage is full.
+func (s *Storage) put(e *entry) {
+	if element, ok := s.entries[e.key]; ok {
+		element.Value = e
+		s.lru.MoveToFront(element)
+
+		return
+	}
+
+	s.entries[e.key] = s.lru.PushFront(e)
+
+	for s.maxKeys > 0 && len(s.entries) > s.maxKeys {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *Storage) remove(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.entries, element.Value.(*entry).key)
+}
+
+// removeEmpty removes key of empty set, hash or list like redis does.
+func (s *Storage) removeEmpty(e *entry) {
+	if len(e.set) > 0 || len(e.hash) > 0 || len(e.list) > 0 {
+		return
+	}
+
+	if element, ok := s.entries[e.key]; ok {
+		s.remove(element)
+	}
+}
+
+func (s *Storage) expiresAt(expire time.Duration) time.Time {
+	if expire <= 0 {
+		return time.Time{}
+	}
+
+	return s.now().Add(expire)
+}
+
+func (s *Storage) expired(e *entry) bool {
+	return !e.expiresAt.IsZero() && !s.now().Before(e.expiresAt)
+}
+
+// removeExpired removes all expired entries.
+func (s *Storage) removeExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for element := s.lru.Front(); element != nil; {
+		next := element.Next()
+
+		if s.expired(element.Value.(*entry)) {
+			s.remove(element)
+		}
+
+		element = next
+	}
+}
+
+func (s *Storage) runJanitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.removeExpired()
+		}
+	}
+}
+
+// record returns copy of entry for backup.
+func (e *entry) record() backup.Record {
+	record := backup.Record{Key: e.key}
+
+	if !e.expiresAt.IsZero() {
+		expiresAt := e.expiresAt
+		record.ExpiresAt = &expiresAt
+	}
+
+	switch e.kind {
+	case kindString:
+		record.Type = backup.TypeString
+		record.Value = e.value
+	case kindSet:
+		record.Type = backup.TypeSet
+		for member := range e.set {
+			record.Members = append(record.Members, member)
+		}
+	case kindHash:
+		record.Type = backup.TypeHash
+		record.Hash = make(map[string]string, len(e.hash))
+		for field, value := range e.hash {
+			record.Hash[field] = value
+		}
+	case kindList:
+		record.Type = backup.TypeList
+		record.List = append([]string(nil), e.list...)
+	}
+
+	return record
+}
+
+// listRange converts redis style inclusive range with negative indexes to slice bounds.
+func listRange(length int, start, stop int64) (int, int) {
+	n := int64(length)
+
+	if start < 0 {
+		start += n
+	}
+
+	if stop < 0 {
+		stop += n
+	}
+
+	if start < 0 {
+		start = 0
+	}
+
+	if stop >= n {
+		stop = n - 1
+	}
+
+	if start > stop {
+		return 0, 0
+	}
+
+	return int(start), int(stop) + 1
+}
+
+func wrapError(command, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%s key failed: %w, key: %s", command, err, key)
+}
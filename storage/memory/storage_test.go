@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestStorageExpire(t *testing.T) {
+	ctx := context.TODO()
+	clock := &clock{now: time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)}
+	storage := NewStorageWithOptions(Options{Now: clock.Now})
+
+	require.NoError(t, storage.Set(ctx, "posts", "cached", time.Minute))
+	require.NoError(t, storage.Set(ctx, "settings", "forever", 0))
+	require.NoError(t, storage.SAdd(ctx, "published", "a"))
+
+	clock.Add(59 * time.Second)
+
+	value, err := storage.Get(ctx, "posts")
+	require.NoError(t, err)
+	require.Equal(t, "cached", value)
+
+	clock.Add(time.Second)
+
+	value, err = storage.Get(ctx, "posts")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	value, err = storage.Get(ctx, "settings")
+	require.NoError(t, err)
+	require.Equal(t, "forever", value)
+
+	// Set without expire removes previous one like in redis.
+	require.NoError(t, storage.Set(ctx, "posts", "cached", time.Minute))
+	require.NoError(t, storage.Set(ctx, "posts", "updated", 0))
+
+	clock.Add(time.Hour)
+
+	value, err = storage.Get(ctx, "posts")
+	require.NoError(t, err)
+	require.Equal(t, "updated", value)
+
+	ok, err := storage.SIsMember(ctx, "published", "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestStorageJanitor(t *testing.T) {
+	ctx := context.TODO()
+	clock := &clock{now: time.Date(2021, 5, 1, 12, 0, 0, 0, time.UTC)}
+	storage := NewStorageWithOptions(Options{Now: clock.Now, CleanupInterval: time.Millisecond})
+	defer storage.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, storage.Set(ctx, fmt.Sprint("posts:", i), "cached", time.Minute))
+	}
+
+	require.NoError(t, storage.Set(ctx, "settings", "forever", 0))
+	require.Equal(t, 11, storage.Len())
+
+	// Expired keys are removed without access.
+	clock.Add(time.Minute)
+
+	require.Eventually(t, func() bool { return storage.Len() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestStorageLRU(t *testing.T) {
+	ctx := context.TODO()
+	storage := NewStorageWithOptions(Options{MaxKeys: 3})
+
+	require.NoError(t, storage.Set(ctx, "a", "1", 0))
+	require.NoError(t, storage.Set(ctx, "b", "2", 0))
+	require.NoError(t, storage.SAdd(ctx, "c", "3"))
+
+	// Reading a makes b the least recently used key.
+	_, err := storage.Get(ctx, "a")
+	require.NoError(t, err)
+
+	require.NoError(t, storage.Set(ctx, "d", "4", 0))
+	require.Equal(t, 3, storage.Len())
+
+	for key, want := range map[string]string{"a": "1", "b": "", "d": "4"} {
+		value, err := storage.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, want, value, key)
+	}
+
+	// Now c is the least recently used key.
+	require.NoError(t, storage.Set(ctx, "e", "5", 0))
+
+	members, err := storage.SMembers(ctx, "c")
+	require.NoError(t, err)
+	require.Empty(t, members)
+}
+
+func TestStorageSets(t *testing.T) {
+	ctx := context.TODO()
+	storage := NewStorageWithOptions(Options{})
+
+	members, err := storage.SMembers(ctx, "published")
+	require.NoError(t, err)
+	require.Equal(t, []string{}, members)
+
+	require.NoError(t, storage.SAdd(ctx, "published", "a", "b"))
+	require.NoError(t, storage.SAdd(ctx, "published", "b", "c"))
+
+	members, err = storage.SMembers(ctx, "published")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b", "c"}, members)
+
+	ok, err := storage.SIsMember(ctx, "published", "d")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Strings and sets share keys like in redis.
+	require.NoError(t, storage.Set(ctx, "posts", "cached", 0))
+	require.ErrorIs(t, storage.SAdd(ctx, "posts", "a"), ErrWrongType)
+
+	_, err = storage.SIsMember(ctx, "posts", "a")
+	require.ErrorIs(t, err, ErrWrongType)
+
+	_, err = storage.SMembers(ctx, "posts")
+	require.ErrorIs(t, err, ErrWrongType)
+
+	_, err = storage.Get(ctx, "published")
+	require.ErrorIs(t, err, ErrWrongType)
+
+	require.NoError(t, storage.Set(ctx, "published", "replaced", 0))
+
+	value, err := storage.Get(ctx, "published")
+	require.NoError(t, err)
+	require.Equal(t, "replaced", value)
+
+	require.NoError(t, storage.Del(ctx, "published"))
+	require.NoError(t, storage.Del(ctx, "missing"))
+
+	value, err = storage.Get(ctx, "published")
+	require.NoError(t, err)
+	require.Empty(t, value)
+}
+
+func TestStorageConcurrency(t *testing.T) {
+	ctx := context.TODO()
+	storage := NewStorageWithOptions(Options{MaxKeys: 50, CleanupInterval: time.Millisecond})
+	defer storage.Close()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 200; j++ {
+				key := fmt.Sprint("key:", (i*200+j)%100)
+
+				require.NoError(t, storage.Set(ctx, key, "value", time.Millisecond))
+				_, err := storage.Get(ctx, key)
+				require.NoError(t, err)
+				require.NoError(t, storage.SAdd(ctx, fmt.Sprint("set:", i), key))
+				_, err = storage.SMembers(ctx, fmt.Sprint("set:", i))
+				require.NoError(t, err)
+				require.NoError(t, storage.Del(ctx, key))
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	require.LessOrEqual(t, storage.Len(), 50)
+}
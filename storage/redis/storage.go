@@ -6,8 +6,13 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v8"
+
+	"github.com/ndrewnee/lesswrong-bot/storage/backup"
 )
 
+// pingTimeout is how long NewStorage waits for redis to answer.
+const pingTimeout = 5 * time.Second
+
 type Storage struct {
 	client *redis.Client
 }
@@ -20,11 +25,24 @@ func NewStorage(url string) (*Storage, error) {
 
 	client := redis.NewClient(options)
 
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ping redis failed: %s", err)
+	}
+
 	return &Storage{
 		client: client,
 	}, nil
 }
 
+// Close closes connections to redis.
+func (s *Storage) Close() error {
+	return s.client.Close()
+}
+
 func (s *Storage) Get(ctx context.Context, key string) (string, error) {
 	value, err := s.client.Get(ctx, key).Result()
 	if err != nil {
@@ -49,3 +67,248 @@ func (s *Storage) Set(ctx context.Context, key, value string, expire time.Durati
 
 	return nil
 }
+
+func (s *Storage) SAdd(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	if err := s.client.SAdd(ctx, key, interfaces(members)...).Err(); err != nil {
+		return fmt.Errorf("sadd redis key failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+func (s *Storage) SIsMember(ctx context.Context, key, member string) (bool, error) {
+	ok, err := s.client.SIsMember(ctx, key, member).Result()
+	if err != nil {
+		return false, fmt.Errorf("sismember redis key failed: %s, key: %s", err, key)
+	}
+
+	return ok, nil
+}
+
+func (s *Storage) SMembers(ctx context.Context, key string) ([]string, error) {
+	members, err := s.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("smembers redis key failed: %s, key: %s", err, key)
+	}
+
+	return members, nil
+}
+
+func (s *Storage) Del(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("del redis key failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// SetNX sets value of key only if it doesn't exist. It returns true if value was set.
+func (s *Storage) SetNX(ctx context.Context, key, value string, expire time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(ctx, key, value, expire).Result()
+	if err != nil {
+		return false, fmt.Errorf("setnx redis key failed: %s, key: %s", err, key)
+	}
+
+	return ok, nil
+}
+
+func (s *Storage) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := s.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("exists redis key failed: %s, key: %s", err, key)
+	}
+
+	return n > 0, nil
+}
+
+func (s *Storage) Incr(ctx context.Context, key string) (int64, error) {
+	value, err := s.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("incr redis key failed: %s, key: %s", err, key)
+	}
+
+	return value, nil
+}
+
+func (s *Storage) HSet(ctx context.Context, key, field, value string) error {
+	if err := s.client.HSet(ctx, key, field, value).Err(); err != nil {
+		return fmt.Errorf("hset redis key failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+func (s *Storage) HGet(ctx context.Context, key, field string) (string, error) {
+	value, err := s.client.HGet(ctx, key, field).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("hget redis key failed: %s, key: %s", err, key)
+	}
+
+	return value, nil
+}
+
+func (s *Storage) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	hash, err := s.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("hgetall redis key failed: %s, key: %s", err, key)
+	}
+
+	return hash, nil
+}
+
+func (s *Storage) HDel(ctx context.Context, key string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	if err := s.client.HDel(ctx, key, fields...).Err(); err != nil {
+		return fmt.Errorf("hdel redis key failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+func (s *Storage) SRem(ctx context.Context, key string, members ...string) error {
+	if len(members) == 0 {
+		return nil
+	}
+
+	if err := s.client.SRem(ctx, key, interfaces(members)...).Err(); err != nil {
+		return fmt.Errorf("srem redis key failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+func (s *Storage) LPush(ctx context.Context, key string, values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	if err := s.client.LPush(ctx, key, interfaces(values)...).Err(); err != nil {
+		return fmt.Errorf("lpush redis key failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+func (s *Storage) RPush(ctx context.Context, key string, values ...string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	if err := s.client.RPush(ctx, key, interfaces(values)...).Err(); err != nil {
+		return fmt.Errorf("rpush redis key failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+func (s *Storage) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	values, err := s.client.LRange(ctx, key, start, stop).Result()
+	if err != nil {
+		return nil, fmt.Errorf("lrange redis key failed: %s, key: %s", err, key)
+	}
+
+	return values, nil
+}
+
+func (s *Storage) LTrim(ctx context.Context, key string, start, stop int64) error {
+	if err := s.client.LTrim(ctx, key, start, stop).Err(); err != nil {
+		return fmt.Errorf("ltrim redis key failed: %s, key: %s", err, key)
+	}
+
+	return nil
+}
+
+// Scan calls fn for each key of database. Keys changed during scan may be missed or returned twice like in SCAN command.
+func (s *Storage) Scan(ctx context.Context, fn func(record backup.Record) error) error {
+	iter := s.client.Scan(ctx, 0, "*", 100).Iterator()
+
+	for iter.Next(ctx) {
+		record, ok, err := s.record(ctx, iter.Val())
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("scan redis keys failed: %s", err)
+	}
+
+	return nil
+}
+
+// record returns value of key for backup. It returns false if key was removed.
+func (s *Storage) record(ctx context.Context, key string) (backup.Record, bool, error) {
+	record := backup.Record{Key: key}
+
+	keyType, err := s.client.Type(ctx, key).Result()
+	if err != nil {
+		return record, false, fmt.Errorf("type redis key failed: %s, key: %s", err, key)
+	}
+
+	switch keyType {
+	case "none":
+		return record, false, nil
+	case "string":
+		record.Type = backup.TypeString
+		record.Value, err = s.client.Get(ctx, key).Result()
+	case "set":
+		record.Type = backup.TypeSet
+		record.Members, err = s.client.SMembers(ctx, key).Result()
+	case "hash":
+		record.Type = backup.TypeHash
+		record.Hash, err = s.client.HGetAll(ctx, key).Result()
+	case "list":
+		record.Type = backup.TypeList
+		record.List, err = s.client.LRange(ctx, key, 0, -1).Result()
+	default:
+		return record, false, fmt.Errorf("unsupported redis key type: %s, key: %s", keyType, key)
+	}
+
+	if err == redis.Nil {
+		return record, false, nil
+	}
+
+	if err != nil {
+		return record, false, fmt.Errorf("get redis key failed: %s, key: %s", err, key)
+	}
+
+	ttl, err := s.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return record, false, fmt.Errorf("pttl redis key failed: %s, key: %s", err, key)
+	}
+
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		record.ExpiresAt = &expiresAt
+	}
+
+	return record, true, nil
+}
+
+func interfaces(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		result = append(result, value)
+	}
+
+	return result
+}
@@ -0,0 +1,33 @@
+package redis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot"
+	"github.com/ndrewnee/lesswrong-bot/storage/redis"
+	"github.com/ndrewnee/lesswrong-bot/storage/storagetest"
+)
+
+func TestStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) (bot.Storage, func(d time.Duration)) {
+		server := miniredis.RunT(t)
+
+		storage, err := redis.NewStorage("redis://" + server.Addr())
+		require.NoError(t, err)
+
+		return storage, server.FastForward
+	})
+}
+
+func TestNewStorageUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	_, err := redis.NewStorage("redis://" + addr)
+	require.ErrorContains(t, err, "ping redis failed")
+}
@@ -0,0 +1,331 @@
+// Package storagetest has conformance tests which every bot.Storage implementation must pass,
+// so memory storage behaves like redis.
+package storagetest
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ndrewnee/lesswrong-bot/bot"
+	"github.com/ndrewnee/lesswrong-bot/storage/backup"
+)
+
+// Factory returns empty storage and function moving its clock forward.
+type Factory func(t *testing.T) (storage bot.Storage, fastForward func(d time.Duration))
+
+// Run runs conformance tests against storages created by factory.
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, storage bot.Storage, fastForward func(d time.Duration))
+	}{
+		{name: "Strings", test: testStrings},
+		{name: "Expire", test: testExpire},
+		{name: "SetNX", test: testSetNX},
+		{name: "Incr", test: testIncr},
+		{name: "Hashes", test: testHashes},
+		{name: "Sets", test: testSets},
+		{name: "Lists", test: testLists},
+		{name: "WrongType", test: testWrongType},
+		{name: "Scan", test: testScan},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage, fastForward := factory(t)
+			tt.test(t, storage, fastForward)
+		})
+	}
+}
+
+func testStrings(t *testing.T, storage bot.Storage, _ func(d time.Duration)) {
+	ctx := context.TODO()
+
+	value, err := storage.Get(ctx, "posts")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	ok, err := storage.Exists(ctx, "posts")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, storage.Set(ctx, "posts", "cached", 0))
+
+	value, err = storage.Get(ctx, "posts")
+	require.NoError(t, err)
+	require.Equal(t, "cached", value)
+
+	ok, err = storage.Exists(ctx, "posts")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, storage.Del(ctx, "posts"))
+	require.NoError(t, storage.Del(ctx, "missing"))
+
+	ok, err = storage.Exists(ctx, "posts")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func testExpire(t *testing.T, storage bot.Storage, fastForward func(d time.Duration)) {
+	ctx := context.TODO()
+
+	require.NoError(t, storage.Set(ctx, "posts", "cached", time.Minute))
+	require.NoError(t, storage.Set(ctx, "settings", "forever", 0))
+	require.NoError(t, storage.Set(ctx, "replaced", "cached", time.Minute))
+	require.NoError(t, storage.Set(ctx, "replaced", "forever", 0))
+
+	fastForward(59 * time.Second)
+
+	value, err := storage.Get(ctx, "posts")
+	require.NoError(t, err)
+	require.Equal(t, "cached", value)
+
+	fastForward(time.Second)
+
+	for key, want := range map[string]string{"posts": "", "settings": "forever", "replaced": "forever"} {
+		value, err := storage.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, want, value, key)
+	}
+
+	ok, err := storage.Exists(ctx, "posts")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func testSetNX(t *testing.T, storage bot.Storage, fastForward func(d time.Duration)) {
+	ctx := context.TODO()
+
+	ok, err := storage.SetNX(ctx, "lock", "first", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = storage.SetNX(ctx, "lock", "second", time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	value, err := storage.Get(ctx, "lock")
+	require.NoError(t, err)
+	require.Equal(t, "first", value)
+
+	fastForward(time.Minute)
+
+	ok, err = storage.SetNX(ctx, "lock", "third", 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func testIncr(t *testing.T, storage bot.Storage, fastForward func(d time.Duration)) {
+	ctx := context.TODO()
+
+	for want := int64(1); want <= 3; want++ {
+		value, err := storage.Incr(ctx, "counter")
+		require.NoError(t, err)
+		require.Equal(t, want, value)
+	}
+
+	value, err := storage.Get(ctx, "counter")
+	require.NoError(t, err)
+	require.Equal(t, "3", value)
+
+	// Incr keeps expire of key.
+	require.NoError(t, storage.Set(ctx, "daily", "41", time.Minute))
+
+	n, err := storage.Incr(ctx, "daily")
+	require.NoError(t, err)
+	require.EqualValues(t, 42, n)
+
+	fastForward(time.Minute)
+
+	value, err = storage.Get(ctx, "daily")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	require.NoError(t, storage.Set(ctx, "title", "Meditations on Moloch", 0))
+
+	_, err = storage.Incr(ctx, "title")
+	require.Error(t, err)
+}
+
+func testHashes(t *testing.T, storage bot.Storage, _ func(d time.Duration)) {
+	ctx := context.TODO()
+
+	hash, err := storage.HGetAll(ctx, "settings")
+	require.NoError(t, err)
+	require.Empty(t, hash)
+
+	require.NoError(t, storage.HSet(ctx, "settings", "source", "2"))
+	require.NoError(t, storage.HSet(ctx, "settings", "instantview", "true"))
+	require.NoError(t, storage.HSet(ctx, "settings", "source", "3"))
+
+	value, err := storage.HGet(ctx, "settings", "source")
+	require.NoError(t, err)
+	require.Equal(t, "3", value)
+
+	value, err = storage.HGet(ctx, "settings", "missing")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	hash, err = storage.HGetAll(ctx, "settings")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"source": "3", "instantview": "true"}, hash)
+
+	require.NoError(t, storage.HDel(ctx, "settings", "source", "missing"))
+
+	hash, err = storage.HGetAll(ctx, "settings")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"instantview": "true"}, hash)
+
+	// Key of empty hash is removed.
+	require.NoError(t, storage.HDel(ctx, "settings", "instantview"))
+
+	ok, err := storage.Exists(ctx, "settings")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func testSets(t *testing.T, storage bot.Storage, _ func(d time.Duration)) {
+	ctx := context.TODO()
+
+	members, err := storage.SMembers(ctx, "published")
+	require.NoError(t, err)
+	require.Empty(t, members)
+
+	require.NoError(t, storage.SAdd(ctx, "published", "a", "b"))
+	require.NoError(t, storage.SAdd(ctx, "published", "b", "c"))
+
+	members, err = storage.SMembers(ctx, "published")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b", "c"}, members)
+
+	ok, err := storage.SIsMember(ctx, "published", "b")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, storage.SRem(ctx, "published", "b", "missing"))
+
+	ok, err = storage.SIsMember(ctx, "published", "b")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Key of empty set is removed.
+	require.NoError(t, storage.SRem(ctx, "published", "a", "c"))
+
+	ok, err = storage.Exists(ctx, "published")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func testLists(t *testing.T, storage bot.Storage, _ func(d time.Duration)) {
+	ctx := context.TODO()
+
+	values, err := storage.LRange(ctx, "history", 0, -1)
+	require.NoError(t, err)
+	require.Empty(t, values)
+
+	require.NoError(t, storage.RPush(ctx, "history", "c", "d"))
+	require.NoError(t, storage.LPush(ctx, "history", "b", "a"))
+
+	tests := []struct {
+		start, stop int64
+		want        []string
+	}{
+		{start: 0, stop: -1, want: []string{"a", "b", "c", "d"}},
+		{start: 1, stop: 2, want: []string{"b", "c"}},
+		{start: -2, stop: -1, want: []string{"c", "d"}},
+		{start: 2, stop: 100, want: []string{"c", "d"}},
+		{start: -100, stop: 0, want: []string{"a"}},
+		{start: 3, stop: 1, want: []string{}},
+		{start: 10, stop: 20, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		values, err := storage.LRange(ctx, "history", tt.start, tt.stop)
+		require.NoError(t, err)
+		require.Equal(t, tt.want, values, "%d..%d", tt.start, tt.stop)
+	}
+
+	require.NoError(t, storage.LTrim(ctx, "history", 0, 2))
+
+	values, err = storage.LRange(ctx, "history", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, values)
+
+	// Key of empty list is removed.
+	require.NoError(t, storage.LTrim(ctx, "history", 5, 10))
+
+	ok, err := storage.Exists(ctx, "history")
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func testWrongType(t *testing.T, storage bot.Storage, _ func(d time.Duration)) {
+	ctx := context.TODO()
+
+	require.NoError(t, storage.Set(ctx, "string", "value", 0))
+	require.NoError(t, storage.SAdd(ctx, "set", "member"))
+	require.NoError(t, storage.HSet(ctx, "hash", "field", "value"))
+	require.NoError(t, storage.RPush(ctx, "list", "value"))
+
+	_, err := storage.Get(ctx, "set")
+	require.Error(t, err)
+
+	require.Error(t, storage.SAdd(ctx, "string", "member"))
+	require.Error(t, storage.HSet(ctx, "list", "field", "value"))
+	require.Error(t, storage.RPush(ctx, "hash", "value"))
+
+	_, err = storage.SMembers(ctx, "hash")
+	require.Error(t, err)
+
+	_, err = storage.LRange(ctx, "string", 0, -1)
+	require.Error(t, err)
+
+	// Set replaces value of any type.
+	require.NoError(t, storage.Set(ctx, "set", "value", 0))
+
+	value, err := storage.Get(ctx, "set")
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+}
+
+func testScan(t *testing.T, storage bot.Storage, _ func(d time.Duration)) {
+	ctx := context.TODO()
+
+	scanner, ok := storage.(backup.Scanner)
+	require.True(t, ok, "storage must implement backup.Scanner")
+
+	require.NoError(t, storage.Set(ctx, "source:1", "2", 0))
+	require.NoError(t, storage.Set(ctx, "posts:astralcodexten", "[]", time.Hour))
+	require.NoError(t, storage.SAdd(ctx, "served:1:1", "b", "a"))
+	require.NoError(t, storage.HSet(ctx, "settings:1", "source", "2"))
+	require.NoError(t, storage.RPush(ctx, "history:1", "a", "b"))
+
+	var records []backup.Record
+
+	require.NoError(t, scanner.Scan(ctx, func(record backup.Record) error {
+		sort.Strings(record.Members)
+		records = append(records, record)
+
+		return nil
+	}))
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+
+	require.Len(t, records, 5)
+	require.NotNil(t, records[1].ExpiresAt)
+
+	records[1].ExpiresAt = nil
+
+	require.Equal(t, []backup.Record{
+		{Key: "history:1", Type: backup.TypeList, List: []string{"a", "b"}},
+		{Key: "posts:astralcodexten", Type: backup.TypeString, Value: "[]"},
+		{Key: "served:1:1", Type: backup.TypeSet, Members: []string{"a", "b"}},
+		{Key: "settings:1", Type: backup.TypeHash, Hash: map[string]string{"source": "2"}},
+		{Key: "source:1", Type: backup.TypeString, Value: "2"},
+	}, records)
+}